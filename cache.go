@@ -0,0 +1,85 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// GenerationCache stores formatted generated files keyed by signature of generation input. Layout of cache
+// directory follows GOCACHE: entries are grouped into subdirectories named by the first two characters of hash.
+type GenerationCache struct {
+	dir string
+}
+
+// DefaultCacheDir returns cache directory specified by GOBETTERCACHE environment variable or "gobetter"
+// subdirectory of user cache directory. Empty string is returned if cache is disabled (GOBETTERCACHE=off).
+func DefaultCacheDir() string {
+	if dir := os.Getenv("GOBETTERCACHE"); dir != "" {
+		if dir == "off" {
+			return ""
+		}
+		return dir
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "gobetter")
+}
+
+func NewGenerationCache(dir string) *GenerationCache {
+	if dir == "" || dir == "off" {
+		return nil
+	}
+	return &GenerationCache{dir: dir}
+}
+
+func (c *GenerationCache) entryPath(signature string) string {
+	_, hash, found := strings.Cut(signature, ":")
+	if !found {
+		hash = signature
+	}
+	return filepath.Join(c.dir, hash[:2], hash+"-d")
+}
+
+// Get returns cached content of generated file or nil if there is no entry for signature
+func (c *GenerationCache) Get(signature string) []byte {
+	if c == nil {
+		return nil
+	}
+	content, err := os.ReadFile(c.entryPath(signature))
+	if err != nil {
+		return nil
+	}
+	return content
+}
+
+// Put stores content of generated file. Errors are ignored, because cache is only an optimization.
+func (c *GenerationCache) Put(signature string, content []byte) {
+	if c == nil {
+		return
+	}
+	path := c.entryPath(signature)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return
+	}
+	// every process writes its own temporary file, so processes storing the same entry don't clobber each other
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(content)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0o644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+}
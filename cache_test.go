@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerationFromCacheReportsDiagnosticsAndStats(t *testing.T) {
+	dir := writeTestModule(t, map[string]string{
+		"go.mod": testModule,
+		"person.go": `package main
+
+type Person struct { //+gob:Constructor +gob:unknown
+	Name string
+}
+`,
+	})
+	args := []string{"-input", filepath.Join(dir, "person.go"), "-cache-dir", t.TempDir(), "-bench"}
+	generate := func() (*fileRun, string, string) {
+		var stdout, stderr bytes.Buffer
+		run := &fileRun{
+			opts:     parseCommandLineArgs(args),
+			args:     args,
+			packages: NewPackageCache(nil),
+			stdout:   &stdout,
+			stderr:   &stderr,
+		}
+		if code := run.generate(); code != 0 {
+			t.Fatalf("exit code %d:\n%s", code, stderr.String())
+		}
+		return run, stdout.String(), stderr.String()
+	}
+	_, _, diagnostics := generate()
+	generated := readTestFile(t, dir, "person_gob.go")
+	for _, name := range []string{"person_gob.go", "person_gob_bench_test.go"} {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	run, progress, cachedDiagnostics := generate()
+	if !strings.Contains(progress, "Output file is restored from cache") {
+		t.Errorf("output file is not restored from cache:\n%s", progress)
+	}
+	if readTestFile(t, dir, "person_gob.go") != generated {
+		t.Errorf("restored output file differs from generated one")
+	}
+	readTestFile(t, dir, "person_gob_bench_test.go")
+	if cachedDiagnostics != diagnostics || !strings.Contains(diagnostics, "unknown annotation +gob:unknown") {
+		t.Errorf("expected diagnostics:\n%s\ngot:\n%s", diagnostics, cachedDiagnostics)
+	}
+	if stats := run.stats; stats.Structs != 1 || stats.Builders != 1 || stats.Warnings != 1 ||
+		len(stats.Generated) != 1 {
+		t.Errorf("unexpected stats of generation from cache: %+v", stats)
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/token"
+	"os"
+	"sort"
+	"strings"
+)
+
+// defaultConfigFilename is name of configuration file checked by "gobetter config lint" when no file is passed
+const defaultConfigFilename = "gobetter.json"
+
+// configOption is option of configuration file, it sets value of command-line flag unless the flag is passed
+// explicitly
+type configOption struct {
+	// path is dot-separated path of option in configuration file, e.g. "builders.naming"
+	path string
+	flag string
+	// kind is "string", "bool", "integer" or "identifier" (string that must be valid Go identifier)
+	kind string
+	// values are allowed values of string option, any value is allowed if empty
+	values []string
+}
+
+// configOptions is schema of configuration file
+var configOptions = []configOption{
+	{path: "builders.naming", flag: "naming", kind: "string", values: []string{"legacy", "camel"}},
+	{path: "builders.setterStyle", flag: "setter-style", kind: "string", values: []string{"bare", "with", "set"}},
+	{path: "builders.finalizerName", flag: "finalizer-name", kind: "identifier"},
+	{path: "builders.buildName", flag: "build-name", kind: "identifier"},
+	{path: "builders.visibility", flag: "builder-visibility", kind: "string", values: []string{"exported", "package"}},
+	{path: "builders.sort", flag: "sort", kind: "string", values: []string{"seq", "abc", "type"}},
+	{path: "builders.required", flag: "required", kind: "string", values: []string{"all", "annotated"}},
+	{path: "builders.optionalFromTags", flag: "optional-from-tags", kind: "bool"},
+	{path: "builders.deprecatedShims", flag: "deprecated-shims", kind: "bool"},
+	{path: "builders.maxTypes", flag: "max-builder-types", kind: "integer"},
+	{path: "constructors.visibility", flag: "constructor", kind: "string",
+		values: []string{"exported", "package", "none"}},
+	{path: "constructors.receiver", flag: "receiver", kind: "string", values: []string{"value", "pointer"}},
+	{path: "getters.style", flag: "getter-style", kind: "string", values: []string{"bare", "get", "must"}},
+	{path: "getters.exported", flag: "exported-getter", kind: "string", values: []string{"error", "warn", "get"}},
+	{path: "structs.generateFor", flag: "generate-for", kind: "string",
+		values: []string{"all", "exported", "tagged", "annotated"}},
+	{path: "structs.only", flag: "only", kind: "string"},
+	{path: "structs.skip", flag: "skip-structs", kind: "string"},
+	{path: "output.split", flag: "split", kind: "string", values: []string{"file", "struct"}},
+	{path: "output.anyStyle", flag: "anystyle", kind: "string", values: []string{"any", "interface{}"}},
+	{path: "output.lang", flag: "lang", kind: "string"},
+	{path: "output.formatter", flag: "formatter", kind: "string", values: []string{"gofmt", "gofumpt", "none"}},
+	{path: "output.fixImports", flag: "fix-imports", kind: "bool"},
+	{path: "output.local", flag: "local", kind: "string"},
+	{path: "output.bench", flag: "bench", kind: "bool"},
+	{path: "output.perms", flag: "out-perms", kind: "string"},
+	{path: "output.readOnly", flag: "read-only", kind: "bool"},
+	{path: "integrations.mock", flag: "mock", kind: "string", values: []string{"none", "moq", "mockgen"}},
+	{path: "integrations.logMarshaler", flag: "log-marshaler", kind: "string",
+		values: []string{"none", "zap", "zerolog"}},
+	{path: "integrations.toml", flag: "toml", kind: "string", values: []string{"burntsushi", "pelletier"}},
+	{path: "integrations.webFramework", flag: "web-framework", kind: "string", values: []string{"gin", "echo"}},
+	{path: "diagnostics.format", flag: "diagnostics", kind: "string", values: []string{"text", "json"}},
+	{path: "diagnostics.strict", flag: "strict", kind: "bool"},
+}
+
+// Config holds values of options read from configuration file keyed by flag names
+type Config map[string]string
+
+// LoadConfig reads configuration file and validates it against schema, all violations are returned with
+// paths of options, e.g. `builders.naming: invalid value "Camel-case"`
+func LoadConfig(filename string) (Config, []string, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	config, problems := ParseConfig(content)
+	return config, problems, nil
+}
+
+// ParseConfig parses content of configuration file and validates it against schema
+func ParseConfig(content []byte) (Config, []string) {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	var document map[string]interface{}
+	if err := decoder.Decode(&document); err != nil {
+		return nil, []string{fmt.Sprintf("invalid JSON: %v", err)}
+	}
+	options := make(map[string]configOption)
+	sections := make(map[string]bool)
+	for _, option := range configOptions {
+		options[option.path] = option
+		section, _, _ := strings.Cut(option.path, ".")
+		sections[section] = true
+	}
+	config := make(Config)
+	problems := make([]string, 0)
+	for _, section := range sortedKeys(document) {
+		if !sections[section] {
+			problems = append(problems, fmt.Sprintf("%s: unknown section%s", section, configSuggestion(section, sections)))
+			continue
+		}
+		values, ok := document[section].(map[string]interface{})
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s: must be an object", section))
+			continue
+		}
+		names := make(map[string]bool)
+		for path := range options {
+			if strings.HasPrefix(path, section+".") {
+				names[strings.TrimPrefix(path, section+".")] = true
+			}
+		}
+		for _, name := range sortedKeys(values) {
+			path := section + "." + name
+			option, found := options[path]
+			if !found {
+				problems = append(problems, fmt.Sprintf("%s: unknown option%s", path, configSuggestion(name, names)))
+				continue
+			}
+			value, problem := option.validate(values[name])
+			if problem != "" {
+				problems = append(problems, fmt.Sprintf("%s: %s", path, problem))
+				continue
+			}
+			config[option.flag] = value
+		}
+	}
+	return config, problems
+}
+
+// validate checks value of option, returns value in form of command-line flag value or problem description
+func (o configOption) validate(value interface{}) (string, string) {
+	if o.kind == "bool" {
+		b, ok := value.(bool)
+		if !ok {
+			return "", fmt.Sprintf("invalid value %s, must be true or false", configValueText(value))
+		}
+		return fmt.Sprint(b), ""
+	}
+	if o.kind == "integer" {
+		n, ok := value.(json.Number)
+		if _, err := n.Int64(); !ok || err != nil {
+			return "", fmt.Sprintf("invalid value %s, must be integer", configValueText(value))
+		}
+		return n.String(), ""
+	}
+	s, ok := value.(string)
+	if !ok {
+		return "", fmt.Sprintf("invalid value %s, must be string", configValueText(value))
+	}
+	if o.kind == "identifier" && !token.IsIdentifier(s) {
+		return "", fmt.Sprintf("invalid value %q, must be valid Go identifier", s)
+	}
+	if len(o.values) == 0 {
+		return s, ""
+	}
+	for _, allowed := range o.values {
+		if s == allowed {
+			return s, ""
+		}
+	}
+	quoted := make([]string, 0, len(o.values))
+	for _, allowed := range o.values {
+		quoted = append(quoted, fmt.Sprintf("%q", allowed))
+	}
+	return "", fmt.Sprintf("invalid value %q, must be one of %s", s, strings.Join(quoted, ", "))
+}
+
+// Apply sets flags to values of configuration file, flags passed in command line take precedence
+func (c Config) Apply(flags *flag.FlagSet) error {
+	for name, value := range c {
+		if isFlagPassed(flags, name) {
+			continue
+		}
+		if err := flags.Set(name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ConfigSchema returns JSON schema of configuration file
+func ConfigSchema() string {
+	type property struct {
+		Schema      string               `json:"$schema,omitempty"`
+		Type        string               `json:"type"`
+		Enum        []string             `json:"enum,omitempty"`
+		Pattern     string               `json:"pattern,omitempty"`
+		Description string               `json:"description,omitempty"`
+		Properties  map[string]*property `json:"properties,omitempty"`
+		Additional  *bool                `json:"additionalProperties,omitempty"`
+	}
+	noAdditional := false
+	root := &property{Schema: "http://json-schema.org/draft-07/schema#", Type: "object",
+		Properties: make(map[string]*property), Additional: &noAdditional}
+	for _, option := range configOptions {
+		section, name, _ := strings.Cut(option.path, ".")
+		if root.Properties[section] == nil {
+			root.Properties[section] = &property{Type: "object", Properties: make(map[string]*property),
+				Additional: &noAdditional}
+		}
+		p := &property{Type: "string", Enum: option.values, Description: "-" + option.flag + " flag"}
+		switch option.kind {
+		case "bool":
+			p.Type = "boolean"
+		case "integer":
+			p.Type = "integer"
+		case "identifier":
+			p.Pattern = "^[A-Za-z_][A-Za-z0-9_]*$"
+		}
+		root.Properties[section].Properties[name] = p
+	}
+	content, _ := json.MarshalIndent(root, "", "  ")
+	return string(content)
+}
+
+// RunConfigCommand runs "gobetter config lint [file]" and "gobetter config schema" commands, returns exit code
+func RunConfigCommand(args []string) int {
+	if len(args) == 1 && args[0] == "schema" {
+		fmt.Println(ConfigSchema())
+		return 0
+	}
+	if len(args) == 0 || args[0] != "lint" || len(args) > 2 {
+		_, _ = fmt.Fprintln(os.Stderr, "Usage: gobetter config lint [file] | gobetter config schema")
+		return ExitUsage
+	}
+	filename := defaultConfigFilename
+	if len(args) == 2 {
+		filename = args[1]
+	}
+	_, problems, err := LoadConfig(filename)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return ExitUsage
+	}
+	for _, problem := range problems {
+		_, _ = fmt.Fprintf(os.Stderr, "%s: %s\n", filename, problem)
+	}
+	if len(problems) > 0 {
+		return ExitUsage
+	}
+	fmt.Printf("Configuration file %s is valid\n", filename)
+	return 0
+}
+
+func configSuggestion(name string, known map[string]bool) string {
+	if closest := closestName(name, known); closest != "" {
+		return ", did you mean " + closest + "?"
+	}
+	return ""
+}
+
+func configValueText(value interface{}) string {
+	content, _ := json.Marshal(value)
+	return string(content)
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseConfigReportsPathsOfProblems(t *testing.T) {
+	config, problems := ParseConfig([]byte(`{
+  "builders": {"naming": "Camel-case", "setterStyle": "with", "maxTypes": "8", "finalizerName": "1st"},
+  "getters": {"styel": "get"},
+  "output": {"bench": true},
+  "diagnostic": {}
+}`))
+
+	expected := []string{
+		`builders.finalizerName: invalid value "1st", must be valid Go identifier`,
+		`builders.maxTypes: invalid value "8", must be integer`,
+		`builders.naming: invalid value "Camel-case", must be one of "legacy", "camel"`,
+		"diagnostic: unknown section, did you mean diagnostics?",
+		"getters.styel: unknown option, did you mean style?",
+	}
+	if !reflect.DeepEqual(problems, expected) {
+		t.Errorf("expected problems:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(problems, "\n"))
+	}
+	if !reflect.DeepEqual(config, Config{"setter-style": "with", "bench": "true"}) {
+		t.Errorf("unexpected config %v", config)
+	}
+}
+
+func TestConfigLintCommand(t *testing.T) {
+	dir := writeTestModule(t, map[string]string{
+		"valid.json":   `{"builders": {"naming": "camel"}}`,
+		"invalid.json": `{"builders": {"naming": "Camel-case"}}`,
+	})
+
+	if code := RunConfigCommand([]string{"lint", filepath.Join(dir, "valid.json")}); code != 0 {
+		t.Errorf("valid configuration file: expected exit code 0, got %d", code)
+	}
+	if code := RunConfigCommand([]string{"lint", filepath.Join(dir, "invalid.json")}); code != ExitUsage {
+		t.Errorf("invalid configuration file: expected exit code %d, got %d", ExitUsage, code)
+	}
+	if code := RunConfigCommand([]string{"lint", filepath.Join(dir, "missing.json")}); code != ExitUsage {
+		t.Errorf("missing configuration file: expected exit code %d, got %d", ExitUsage, code)
+	}
+}
+
+func TestConfigFileSetsDefaultsOfFlags(t *testing.T) {
+	dir := writeTestModule(t, map[string]string{
+		"go.mod":      testModule,
+		"config.json": `{"builders": {"naming": "camel", "setterStyle": "with"}}`,
+		"person.go":   "package main\n\ntype Person struct { //+gob:Constructor\n\tName string\n}\n",
+	})
+
+	// flags passed in command line take precedence over configuration file
+	generateTestModuleFile(t, dir, "person.go", "-config", filepath.Join(dir, "config.json"), "-setter-style", "set")
+	generated := readTestFile(t, dir, "person_gob.go")
+	if !strings.Contains(generated, "type PersonBuilderName struct") || !strings.Contains(generated, ") SetName(") {
+		t.Errorf("generated file does not follow configuration:\n%s", generated)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"strings"
+)
+
+// customBeginMarker and customEndMarker enclose hand-written code in generated file that survives regeneration
+const (
+	customBeginMarker = "// gobetter:begin-custom"
+	customEndMarker   = "// gobetter:end-custom"
+)
+
+// splitCustomRegions splits content of generated file into generated code and custom regions (including their
+// markers). Regions cannot be nested and every region must be closed.
+func splitCustomRegions(content string) (generated string, regions string, err error) {
+	generatedBld, regionsBld := &strings.Builder{}, &strings.Builder{}
+	inRegion := false
+	for i, line := range strings.SplitAfter(content, "\n") {
+		marker := strings.TrimSpace(line)
+		switch {
+		case marker == customBeginMarker && inRegion:
+			return "", "", fmt.Errorf("line %d: custom region is not closed before the next one is opened", i+1)
+		case marker == customEndMarker && !inRegion:
+			return "", "", fmt.Errorf("line %d: custom region is closed without being opened", i+1)
+		case marker == customBeginMarker || marker == customEndMarker:
+			if !inRegion && regionsBld.Len() > 0 {
+				regionsBld.WriteString("\n")
+			}
+			inRegion = !inRegion
+			regionsBld.WriteString(marker + "\n")
+		case inRegion:
+			regionsBld.WriteString(line)
+		default:
+			generatedBld.WriteString(line)
+		}
+	}
+	if inRegion {
+		return "", "", fmt.Errorf("custom region is not closed")
+	}
+	return generatedBld.String(), regionsBld.String(), nil
+}
+
+// withCustomRegions appends custom regions to generated code, regions are always placed at the end of file
+func withCustomRegions(code string, regions string) string {
+	if regions == "" {
+		return code
+	}
+	return strings.TrimRight(code, "\n") + "\n\n" + regions
+}
+
+// readCustomRegions returns custom regions of previously generated file, or empty string if file does not exist
+func readCustomRegions(filename string) (string, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return "", nil
+	}
+	_, regions, err := splitCustomRegions(string(content))
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", filename, err)
+	}
+	return regions, nil
+}
+
+// readRegionImports returns imports of previously generated file, so packages referenced only by its custom
+// regions stay imported when file is regenerated. Imports that are not referenced by regions (nor by generated
+// code) are dropped the same way as unused imports of input file are.
+func readRegionImports(filename string) []importSpec {
+	file, err := parser.ParseFile(token.NewFileSet(), filename, nil, parser.ImportsOnly)
+	if err != nil {
+		return nil
+	}
+	return fileImportSpecs(file)
+}
+
+// removeGeneratedFile removes stale generated file, file with custom regions is not removed, because regions hold
+// hand-written code
+func removeGeneratedFile(out io.Writer, filename string) error {
+	if regions, err := readCustomRegions(filename); err != nil || regions != "" {
+		return fmt.Errorf("stale file %s has custom regions, move them and remove the file manually", filename)
+	}
+	_, _ = fmt.Fprintf(out, "Remove stale file %s\n", filename)
+	if err := makeWritable(filename); err != nil {
+		return err
+	}
+	return os.Remove(filename)
+}
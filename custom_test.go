@@ -0,0 +1,48 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCustomRegionsSurviveRegeneration(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod":    testModule,
+		"person.go": "package main\n\ntype Person struct { //+gob:Constructor\n\tName string\n}\n",
+		"main.go":   "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(NewPersonBuilder().Name(\"a\").Age(1).Build().Shout())\n}\n",
+	}, "person.go")
+	outFilename := filepath.Join(dir, "person_gob.go")
+	generated := readTestFile(t, dir, "person_gob.go")
+	generated = strings.Replace(generated, "package main\n", "package main\n\nimport \"strings\"\n", 1) + `
+// gobetter:begin-custom
+func (v *Person) Shout() string {
+	return strings.ToUpper(v.Name)
+}
+// gobetter:end-custom
+`
+	if err := os.WriteFile(outFilename, []byte(generated), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// field is added to input file, so file is regenerated with new builder chain
+	if err := os.WriteFile(filepath.Join(dir, "person.go"),
+		[]byte("package main\n\ntype Person struct { //+gob:Constructor\n\tName string\n\tAge  int\n}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	generateTestModuleFile(t, dir, "person.go")
+	assertOutput(t, runTestModule(t, dir), "A")
+}
+
+func TestMalformedCustomRegions(t *testing.T) {
+	for content, message := range map[string]string{
+		"a\n// gobetter:begin-custom\n// gobetter:begin-custom\n": "line 3: custom region is not closed before the next one is opened",
+		"a\n// gobetter:end-custom\n":                             "line 2: custom region is closed without being opened",
+		"// gobetter:begin-custom\nb\n":                           "custom region is not closed",
+	} {
+		if _, _, err := splitCustomRegions(content); err == nil || err.Error() != message {
+			t.Errorf("%q: expected error %q, got %v", content, message, err)
+		}
+	}
+}
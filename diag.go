@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"io"
+)
+
+// Severity of diagnostic
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Diagnostic is error or warning reported for position in source file
+type Diagnostic struct {
+	Filename string   `json:"file"`
+	Line     int      `json:"line"`
+	Column   int      `json:"column"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+}
+
+// String formats diagnostic as "path:line:col: message" the same way as go command does, so editors and
+// terminals recognize its position
+func (d Diagnostic) String() string {
+	if d.Severity == SeverityWarning {
+		return fmt.Sprintf("%s:%d:%d: warning: %s", d.Filename, d.Line, d.Column, d.Message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", d.Filename, d.Line, d.Column, d.Message)
+}
+
+// Diagnostics prints diagnostics to output (stderr or buffer of file of directory input) as soon as they are
+// reported, in "text" format as
+// "path:line:col: message" lines, in "json" format as one JSON object per line. In strict mode warnings
+// are reported as errors.
+type Diagnostics struct {
+	format   string
+	strict   bool
+	out      io.Writer
+	warnings int
+	errors   int
+}
+
+func NewDiagnostics(format string, strict bool, out io.Writer) *Diagnostics {
+	return &Diagnostics{format: format, strict: strict, out: out}
+}
+
+// Add reports diagnostic
+func (d *Diagnostics) Add(diagnostic Diagnostic) {
+	if diagnostic.Severity == SeverityWarning && d.strict {
+		diagnostic.Severity = SeverityError
+	}
+	if diagnostic.Severity == SeverityWarning {
+		d.warnings++
+	} else {
+		d.errors++
+	}
+	if d.format == "json" {
+		// messages quote annotations such as +gob:chunk=<n>, which must stay readable
+		encoder := json.NewEncoder(d.out)
+		encoder.SetEscapeHTML(false)
+		_ = encoder.Encode(diagnostic)
+		return
+	}
+	_, _ = fmt.Fprintln(d.out, diagnostic)
+}
+
+// Errorf reports error at position
+func (d *Diagnostics) Errorf(pos token.Position, format string, a ...interface{}) {
+	d.Add(newDiagnostic(pos, SeverityError, fmt.Sprintf(format, a...)))
+}
+
+// Warnf reports warning at position
+func (d *Diagnostics) Warnf(pos token.Position, format string, a ...interface{}) {
+	d.Add(newDiagnostic(pos, SeverityWarning, fmt.Sprintf(format, a...)))
+}
+
+// ReportError reports error returned by parser or generator. Syntax errors and errors of fields carry their
+// own positions, other errors are reported at position pos.
+func (d *Diagnostics) ReportError(fset *token.FileSet, err error, pos token.Pos) {
+	var syntaxErrors scanner.ErrorList
+	if errors.As(err, &syntaxErrors) {
+		for _, syntaxError := range syntaxErrors {
+			d.Errorf(syntaxError.Pos, "%s", syntaxError.Msg)
+		}
+		return
+	}
+	var posErr *PositionError
+	if errors.As(err, &posErr) && posErr.Pos.IsValid() {
+		pos = posErr.Pos
+	}
+	d.Errorf(fset.Position(pos), "%v", err)
+}
+
+// Warnings returns number of reported warnings
+func (d *Diagnostics) Warnings() int {
+	return d.warnings
+}
+
+// Errors returns number of reported errors, including warnings reported as errors in strict mode
+func (d *Diagnostics) Errors() int {
+	return d.errors
+}
+
+// Summary reports totals of run, in "text" format to stdout, in "json" format to output of diagnostics
+func (d *Diagnostics) Summary(stats RunStats) {
+	if d.format == "json" {
+		content, _ := json.Marshal(summaryReport{Summary: stats})
+		_, _ = fmt.Fprintln(d.out, string(content))
+		return
+	}
+	fmt.Println(stats)
+}
+
+// PositionError is error caused by declaration at position in input file (e.g. by structure field)
+type PositionError struct {
+	Pos token.Pos
+	Err error
+}
+
+func (e *PositionError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PositionError) Unwrap() error {
+	return e.Err
+}
+
+func newDiagnostic(pos token.Position, severity Severity, message string) Diagnostic {
+	return Diagnostic{
+		Filename: pos.Filename,
+		Line:     pos.Line,
+		Column:   pos.Column,
+		Severity: severity,
+		Message:  message,
+	}
+}
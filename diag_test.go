@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDiagnosticsCarryPositions(t *testing.T) {
+	dir := writeTestModule(t, map[string]string{
+		"go.mod": testModule,
+		"person.go": `package main
+
+type Person struct { //+gob:Constructor
+	Name string //+gob:groop=x
+	Age  int    //+gob:group=x
+	City string
+	Zip  int //+gob:group=x
+}
+`,
+	})
+	filename := filepath.Join(dir, "person.go")
+	expected := []Diagnostic{
+		{Filename: filename, Line: 4, Column: 16, Severity: SeverityWarning,
+			Message: "unknown annotation +gob:groop=x of field Name of struct Person, did you mean +gob:group=x?"},
+		{Filename: filename, Line: 7, Column: 2, Severity: SeverityError,
+			Message: `fields of group "x" in struct Person must be declared next to each other`},
+	}
+
+	t.Run("text", func(t *testing.T) {
+		code, diagnostics := generateTestFile(t, dir, "person.go")
+		lines := make([]string, 0, len(expected))
+		for _, diagnostic := range expected {
+			lines = append(lines, diagnostic.String())
+		}
+		if code != ExitAnnotation || strings.TrimSpace(diagnostics) != strings.Join(lines, "\n") {
+			t.Errorf("exit code %d:\n%s", code, diagnostics)
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		code, diagnostics := generateTestFile(t, dir, "person.go", "-diagnostics", "json")
+		decoder := json.NewDecoder(strings.NewReader(diagnostics))
+		for _, want := range expected {
+			var got Diagnostic
+			if err := decoder.Decode(&got); err != nil || got != want {
+				t.Errorf("expected %+v, got %+v (%v)", want, got, err)
+			}
+		}
+		if code != ExitAnnotation || decoder.More() {
+			t.Errorf("exit code %d:\n%s", code, diagnostics)
+		}
+	})
+}
+
+func TestStrictModeReportsWarningsAsErrors(t *testing.T) {
+	dir := writeTestModule(t, map[string]string{
+		"go.mod":    testModule,
+		"person.go": "package main\n\ntype Person struct { //+gob:Constructor\n\tName string //+gob:unknown\n}\n",
+	})
+
+	if code, diagnostics := generateTestFile(t, dir, "person.go"); code != 0 || !strings.Contains(diagnostics, ": warning: ") {
+		t.Errorf("exit code %d:\n%s", code, diagnostics)
+	}
+	code, diagnostics := generateTestFile(t, dir, "person.go", "-strict")
+	if code != ExitAnnotation || strings.Contains(diagnostics, "warning") ||
+		!strings.Contains(diagnostics, "unknown annotation +gob:unknown") {
+		t.Errorf("exit code %d:\n%s", code, diagnostics)
+	}
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// allowConstructionDirective allows construction of annotated structures without builder in a package, e.g.
+// in tests. It can be followed by names of structures it applies to, otherwise it applies to all structures.
+const allowConstructionDirective = "//gobetter:allow-construction"
+
+// enforcementFacts is written into vet facts file of package, so packages importing it know which structures
+// must be constructed with builders
+type enforcementFacts struct {
+	// Package is name of package, import path doesn't tell it (e.g. for "example.com/nats.go" or
+	// "example.com/lib/v2" paths)
+	Package string
+	Structs []string
+}
+
+type parsedFile struct {
+	filename string
+	fileSet  *token.FileSet
+	// packages is package cache the file is parsed with, it is shared by all files of package
+	packages  *PackageCache
+	astFile   *ast.File
+	content   []byte
+	generated bool
+}
+
+func parsePackageFiles(filenames []string) []*parsedFile {
+	packages := NewPackageCache(nil)
+	fset := packages.FileSet()
+	files := make([]*parsedFile, 0, len(filenames))
+	for _, filename := range filenames {
+		content, err := os.ReadFile(filename)
+		if err != nil {
+			continue
+		}
+		astFile, err := parser.ParseFile(fset, filename, content, parser.ParseComments)
+		if err != nil {
+			continue
+		}
+		_, _, generated := ReadSignature(filename)
+		files = append(files, &parsedFile{
+			filename:  filename,
+			fileSet:   fset,
+			packages:  packages,
+			astFile:   astFile,
+			content:   content,
+			generated: generated,
+		})
+	}
+	return files
+}
+
+// packageName returns name of package parsed files belong to
+func packageName(files []*parsedFile) string {
+	if len(files) == 0 {
+		return ""
+	}
+	return files[0].astFile.Name.Name
+}
+
+// annotatedStructs returns names of structures annotated with //+gob:Constructor or //+gob:constructor
+func annotatedStructs(files []*parsedFile) []string {
+	structs := make([]string, 0)
+	for _, file := range files {
+		if file.generated {
+			continue
+		}
+		sp := NewStructParser(file.packages, file.astFile, file.content, "any")
+		ast.Inspect(file.astFile, func(n ast.Node) bool {
+			ts, ok := n.(*ast.TypeSpec)
+			if !ok {
+				return true
+			}
+			switch ts.Type.(type) {
+			case *ast.StructType, *ast.SelectorExpr:
+			default:
+				return true
+			}
+			flags := sp.constructorFlags(ts)
+			if flags.ProcessStruct && !flags.Skip {
+				structs = append(structs, ts.Name.Name)
+			}
+			return true
+		})
+	}
+	return structs
+}
+
+// allowedConstructions returns names of structures allowed to be constructed without builder in a package,
+// "*" allows all structures
+func allowedConstructions(files []*parsedFile) map[string]bool {
+	allowed := make(map[string]bool)
+	for _, file := range files {
+		for _, group := range file.astFile.Comments {
+			for _, comment := range group.List {
+				if comment.Text != allowConstructionDirective &&
+					!strings.HasPrefix(comment.Text, allowConstructionDirective+" ") {
+					continue
+				}
+				names := strings.Fields(strings.TrimPrefix(comment.Text, allowConstructionDirective))
+				if len(names) == 0 {
+					allowed["*"] = true
+				}
+				for _, name := range names {
+					allowed[name] = true
+				}
+			}
+		}
+	}
+	return allowed
+}
+
+// importedStructs returns annotated structures of packages imported by file keyed by import name ("." for dot
+// imports), annotated structures and names of packages are read from vet facts files of imported packages
+func importedStructs(cfg vetConfig, astFile *ast.File) map[string]map[string]bool {
+	imported := make(map[string]map[string]bool)
+	for _, spec := range astFile.Imports {
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		if resolved, found := cfg.ImportMap[importPath]; found {
+			importPath = resolved
+		}
+		content, err := os.ReadFile(cfg.PackageVetx[importPath])
+		if err != nil || len(content) == 0 {
+			continue
+		}
+		var facts enforcementFacts
+		if json.Unmarshal(content, &facts) != nil || len(facts.Structs) == 0 {
+			continue
+		}
+		name := facts.Package
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		if imported[name] == nil {
+			imported[name] = make(map[string]bool)
+		}
+		for _, structName := range facts.Structs {
+			imported[name][structName] = true
+		}
+	}
+	return imported
+}
+
+// EnforceBuilders reports composite literals, new(T) calls and zero value variables of annotated structures
+// (declared in the package or in imported packages) outside of generated files. Composite literals with elided
+// types (e.g. elements of []Person{{...}}) are reported as well, their types are taken from enclosing literals.
+func EnforceBuilders(cfg vetConfig, files []*parsedFile) []string {
+	allowed := allowedConstructions(files)
+	if allowed["*"] {
+		return nil
+	}
+	local := make(map[string]bool)
+	for _, structName := range annotatedStructs(files) {
+		local[structName] = true
+	}
+	diagnostics := make([]string, 0)
+	for _, file := range files {
+		if file.generated {
+			continue
+		}
+		imported := importedStructs(cfg, file.astFile)
+		// annotatedName returns name of annotated structure denoted by type expression or empty string
+		annotatedName := func(expr ast.Expr) string {
+			switch t := expr.(type) {
+			case *ast.IndexExpr:
+				expr = t.X
+			case *ast.IndexListExpr:
+				expr = t.X
+			}
+			name := ""
+			switch t := expr.(type) {
+			case *ast.Ident:
+				if local[t.Name] || imported["."][t.Name] {
+					name = t.Name
+				}
+			case *ast.SelectorExpr:
+				if pkg, ok := t.X.(*ast.Ident); ok && imported[pkg.Name][t.Sel.Name] {
+					name = t.Sel.Name
+				}
+			}
+			if allowed[name] {
+				return ""
+			}
+			return name
+		}
+		report := func(pos token.Pos, format string, structName string) {
+			diagnostics = append(diagnostics,
+				fmt.Sprintf("%s: "+format, file.fileSet.Position(pos), structName))
+		}
+		ast.Inspect(file.astFile, func(n ast.Node) bool {
+			switch t := n.(type) {
+			case *ast.CompositeLit:
+				if t.Type == nil {
+					// literals with elided types are checked with literals enclosing them
+					return true
+				}
+				if name := annotatedName(t.Type); name != "" {
+					report(t.Pos(), "%s is constructed with composite literal, use its builder instead", name)
+				}
+				inspectElidedLits(t, t.Type, func(elided *ast.CompositeLit, typ ast.Expr) {
+					if name := annotatedName(typ); name != "" {
+						report(elided.Pos(), "%s is constructed with composite literal, use its builder instead", name)
+					}
+				})
+			case *ast.CallExpr:
+				if fun, ok := t.Fun.(*ast.Ident); ok && fun.Name == "new" && len(t.Args) == 1 {
+					if name := annotatedName(t.Args[0]); name != "" {
+						report(t.Pos(), "%s is constructed with new, use its builder instead", name)
+					}
+				}
+			case *ast.ValueSpec:
+				if name := annotatedName(t.Type); name != "" && len(t.Values) == 0 {
+					report(t.Pos(), "%s is declared with zero value, use its builder instead", name)
+				}
+			}
+			return true
+		})
+	}
+	return diagnostics
+}
+
+// inspectElidedLits calls f for every composite literal with elided type nested in composite literal of type typ
+// (e.g. elements of []Person{{...}}, keys and values of map[Key]Person{{...}: {...}}) with its implied type.
+// Elements of pointer types written as &{...} or {...} are passed with types they point to.
+func inspectElidedLits(lit *ast.CompositeLit, typ ast.Expr, f func(lit *ast.CompositeLit, typ ast.Expr)) {
+	var keyType, eltType ast.Expr
+	switch t := typ.(type) {
+	case *ast.ArrayType:
+		eltType = t.Elt
+	case *ast.MapType:
+		keyType, eltType = t.Key, t.Value
+	default:
+		return
+	}
+	inspect := func(expr ast.Expr, typ ast.Expr) {
+		if unary, ok := expr.(*ast.UnaryExpr); ok && unary.Op == token.AND {
+			expr = unary.X
+		}
+		if star, ok := typ.(*ast.StarExpr); ok {
+			typ = star.X
+		}
+		elided, ok := expr.(*ast.CompositeLit)
+		if !ok || elided.Type != nil {
+			return
+		}
+		f(elided, typ)
+		inspectElidedLits(elided, typ, f)
+	}
+	for _, elt := range lit.Elts {
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			if keyType != nil {
+				inspect(kv.Key, keyType)
+			}
+			elt = kv.Value
+		}
+		inspect(elt, eltType)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// enforceTestFile enforces builders in single file with facts of imported packages keyed by import paths
+func enforceTestFile(t *testing.T, content string, facts map[string]enforcementFacts) []string {
+	t.Helper()
+	dir := t.TempDir()
+	cfg := vetConfig{PackageVetx: make(map[string]string)}
+	for importPath, pkgFacts := range facts {
+		filename := filepath.Join(dir, strings.ReplaceAll(importPath, "/", "_")+".vetx")
+		data, _ := json.Marshal(pkgFacts)
+		if err := os.WriteFile(filename, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		cfg.PackageVetx[importPath] = filename
+	}
+	filename := filepath.Join(dir, "app.go")
+	if err := os.WriteFile(filename, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	diagnostics := EnforceBuilders(cfg, parsePackageFiles([]string{filename}))
+	for i, diagnostic := range diagnostics {
+		diagnostics[i] = strings.TrimPrefix(diagnostic, filename+":")
+	}
+	return diagnostics
+}
+
+func assertDiagnostics(t *testing.T, diagnostics []string, expected ...string) {
+	t.Helper()
+	if strings.Join(diagnostics, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected diagnostics:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(diagnostics, "\n"))
+	}
+}
+
+func TestEnforceBuildersReportsElidedLiterals(t *testing.T) {
+	diagnostics := enforceTestFile(t, `package app
+
+//+gob:Constructor
+type Person struct {
+	Name string
+}
+
+var people = []Person{{Name: "a"}}
+var pointers = []*Person{{Name: "b"}, &Person{Name: "c"}}
+var byName = map[string]Person{"d": {Name: "d"}}
+var byPerson = map[Person]bool{{Name: "e"}: true}
+var nested = [][]Person{{{Name: "f"}}}
+var names = []string{"g"}
+`, nil)
+
+	assertDiagnostics(t, diagnostics,
+		"8:23: Person is constructed with composite literal, use its builder instead",
+		"9:26: Person is constructed with composite literal, use its builder instead",
+		"9:40: Person is constructed with composite literal, use its builder instead",
+		"10:37: Person is constructed with composite literal, use its builder instead",
+		"11:32: Person is constructed with composite literal, use its builder instead",
+		"12:26: Person is constructed with composite literal, use its builder instead",
+	)
+}
+
+func TestEnforceBuildersUsesPackageNamesFromFacts(t *testing.T) {
+	diagnostics := enforceTestFile(t, `package app
+
+import (
+	"example.com/nats.go"
+	"example.com/client/v2"
+	. "example.com/model"
+)
+
+var conn = nats.Conn{}
+var clients = []client.Client{{}}
+var user = &User{}
+`, map[string]enforcementFacts{
+		"example.com/nats.go":   {Package: "nats", Structs: []string{"Conn"}},
+		"example.com/client/v2": {Package: "client", Structs: []string{"Client"}},
+		"example.com/model":     {Package: "model", Structs: []string{"User"}},
+	})
+
+	assertDiagnostics(t, diagnostics,
+		"9:12: Conn is constructed with composite literal, use its builder instead",
+		"10:31: Client is constructed with composite literal, use its builder instead",
+		"11:13: User is constructed with composite literal, use its builder instead",
+	)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime/debug"
+)
+
+// Exit codes of gobetter, so build scripts can branch on class of failure. Exit code 1 is reserved for
+// unexpected failures (e.g. panics of generator itself).
+const (
+	// ExitInternal means unexpected failure of generator itself, e.g. panic. Go runtime exits with code 2
+	// on panic, so panics are recovered to keep it distinct from ExitUsage.
+	ExitInternal = 1
+	// ExitUsage means invalid command-line flags or arguments, or missing required executable. Flag package
+	// uses the same exit code for unknown flags.
+	ExitUsage = 2
+	// ExitParse means input file cannot be read or parsed
+	ExitParse = 3
+	// ExitAnnotation means annotations are misused (or do not match structure), so code cannot be generated
+	ExitAnnotation = 4
+	// ExitWrite means generated files (or rewritten call sites) cannot be written, formatted or removed
+	ExitWrite = 5
+	// ExitCheck means checks of go vet mode failed: generated files are out of date or builders are bypassed
+	ExitCheck = 6
+)
+
+// exitWithError prints error to stderr and exits with exit code of its class
+func exitWithError(code int, err error) {
+	_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	os.Exit(code)
+}
+
+// exitOnPanic recovers panic of generator and exits with ExitInternal, it must be deferred by main
+func exitOnPanic() {
+	if r := recover(); r != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: internal error: %v\n%s", r, debug.Stack())
+		os.Exit(ExitInternal)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestExitCodesOfFailureClasses(t *testing.T) {
+	dir := writeTestModule(t, map[string]string{
+		"go.mod":    testModule,
+		"broken.go": "package main\n\ntype A struct { //+gob:Constructor\n\tName string\n",
+		"misused.go": "package main\n\ntype B struct { //+gob:Constructor\n\tName string //+gob:group=x\n\tAge  int\n" +
+			"\tCity string //+gob:group=x\n}\n",
+		"valid.go": "package main\n\ntype C struct { //+gob:Constructor\n\tName string\n}\n",
+	})
+
+	for _, test := range []struct {
+		name  string
+		input string
+		args  []string
+		code  int
+	}{
+		{name: "parse", input: "broken.go", code: ExitParse},
+		{name: "annotation", input: "misused.go", code: ExitAnnotation},
+		// output file cannot be created in place of regular file
+		{name: "write", input: "valid.go", args: []string{"-output", filepath.Join(dir, "go.mod", "c_gob.go")}, code: ExitWrite},
+		{name: "success", input: "valid.go", code: 0},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if code, diagnostics := generateTestFile(t, dir, test.input, test.args...); code != test.code {
+				t.Errorf("expected exit code %d, got %d:\n%s", test.code, code, diagnostics)
+			}
+		})
+	}
+}
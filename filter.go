@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// NamePatterns matches struct names against comma-separated list of glob patterns (e.g. "*Request") or
+// regular expressions enclosed in slashes (e.g. "/^(Create|Update).+Request$/")
+type NamePatterns struct {
+	globs   []string
+	regexps []*regexp.Regexp
+}
+
+func ParseNamePatterns(value string) (*NamePatterns, error) {
+	if value == "" {
+		return nil, nil
+	}
+	patterns := &NamePatterns{}
+	for _, pattern := range strings.Split(value, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if len(pattern) >= 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+			re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+			if err != nil {
+				return nil, err
+			}
+			patterns.regexps = append(patterns.regexps, re)
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %s: %v", pattern, err)
+		}
+		patterns.globs = append(patterns.globs, pattern)
+	}
+	return patterns, nil
+}
+
+// Match reports whether name matches at least one of patterns
+func (np *NamePatterns) Match(name string) bool {
+	for _, glob := range np.globs {
+		if matched, _ := path.Match(glob, name); matched {
+			return true
+		}
+	}
+	for _, re := range np.regexps {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNamePatternsMatchGlobsAndRegexps(t *testing.T) {
+	patterns, err := ParseNamePatterns("*Request, /^(Create|Update).+Response$/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, expected := range map[string]bool{
+		"GetRequest":         true,
+		"CreateUserResponse": true,
+		"DeleteResponse":     false,
+		"Request2":           false,
+	} {
+		if patterns.Match(name) != expected {
+			t.Errorf("expected match of %s to be %v", name, expected)
+		}
+	}
+	for _, invalid := range []string{"[", "/(/"} {
+		if _, err = ParseNamePatterns(invalid); err == nil {
+			t.Errorf("invalid pattern %s is accepted", invalid)
+		}
+	}
+}
+
+func TestOnlyAndSkipStructsFilterStructs(t *testing.T) {
+	files := map[string]string{
+		"go.mod": testModule,
+		"api.go": `package main
+
+type GetRequest struct {
+	ID string
+}
+
+type GetResponse struct {
+	Name string
+}
+
+type ListRequest struct {
+	Page int
+}
+`,
+	}
+	for _, test := range []struct {
+		args      []string
+		generated []string
+	}{
+		{args: []string{"-only", "*Request"}, generated: []string{"GetRequest", "ListRequest"}},
+		{args: []string{"-skip-structs", "/^List/"}, generated: []string{"GetRequest", "GetResponse"}},
+		{args: []string{"-only", "Get*", "-skip-structs", "*Response"}, generated: []string{"GetRequest"}},
+	} {
+		t.Run(strings.Join(test.args, " "), func(t *testing.T) {
+			args := append([]string{"-generate-for", "all"}, test.args...)
+			generated := readTestFile(t, generateTestModule(t, files, "api.go", args...), "api_gob.go")
+			for _, name := range []string{"GetRequest", "GetResponse", "ListRequest"} {
+				expected := false
+				for _, generatedName := range test.generated {
+					expected = expected || generatedName == name
+				}
+				if strings.Contains(generated, "func New"+name+"Builder()") != expected {
+					t.Errorf("expected builder of %s to be generated: %v\n%s", name, expected, generated)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// fixTarget describes builder chain of structure, composite literals of structure are rewritten into it
+type fixTarget struct {
+	root   *StructField
+	fields []*StructField
+	chain  []*StructField
+}
+
+// literalFix is composite literal (or address of composite literal) to be replaced with builder chain
+type literalFix struct {
+	node     ast.Expr
+	target   *fixTarget
+	typeName string
+	typeArgs string
+	args     []ast.Expr
+	// optional are values of fields which are not part of builder chain in order they are set by literal, they
+	// are assigned to structure built by builder chain
+	optional []fieldValue
+	pointer  bool
+	// multiline is set for literals spanning multiple lines, their builder chains are split into lines as well
+	multiline bool
+}
+
+// fieldValue is value of field set by composite literal
+type fieldValue struct {
+	name  string
+	value ast.Expr
+}
+
+// FixCompositeLiterals rewrites composite literals of structures in all non-generated files of package into
+// equivalent builder chains. Literals setting optional fields are rewritten into function literals assigning
+// optional fields to structure built by builder chain. Literals leaving fields of builder chain unset and
+// literals with elided types are reported and left intact.
+func FixCompositeLiterals(out io.Writer, dir string, packageName string, targets map[string]*fixTarget,
+	diagnostics *Diagnostics) error {
+	filenames, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return err
+	}
+	for _, file := range parsePackageFiles(filenames) {
+		if file.generated || file.astFile.Name.Name != packageName {
+			continue
+		}
+		fixes := findLiteralFixes(file, targets, diagnostics)
+		if len(fixes) == 0 {
+			continue
+		}
+		start := token.Pos(file.fileSet.File(file.astFile.Pos()).Base())
+		rewriter := literalRewriter{file: file, fixes: fixes}
+		formatted, err := format.Source([]byte(rewriter.render(start, start+token.Pos(len(file.content)))))
+		if err != nil {
+			return fmt.Errorf("failed to format rewritten file %s: %v", file.filename, err)
+		}
+		if err = os.WriteFile(file.filename, formatted, os.FileMode(0644)); err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(out, "Rewrite %d composite literal(s) in %s\n", len(fixes), file.filename)
+	}
+	return nil
+}
+
+func findLiteralFixes(file *parsedFile, targets map[string]*fixTarget, diagnostics *Diagnostics) []*literalFix {
+	fixes := make([]*literalFix, 0)
+	addressed := make(map[*ast.CompositeLit]bool)
+	ast.Inspect(file.astFile, func(n ast.Node) bool {
+		var lit *ast.CompositeLit
+		var node ast.Expr
+		switch t := n.(type) {
+		case *ast.UnaryExpr:
+			if l, ok := t.X.(*ast.CompositeLit); ok && t.Op == token.AND {
+				lit, node = l, t
+				addressed[l] = true
+			}
+		case *ast.CompositeLit:
+			if !addressed[t] {
+				lit, node = t, t
+			}
+			if t.Type != nil {
+				warnElidedLits(file, t, targets, diagnostics)
+			}
+		}
+		if lit == nil {
+			return true
+		}
+		fix, reason := matchLiteral(file, lit, targets)
+		if fix == nil {
+			if reason != "" {
+				diagnostics.Warnf(file.fileSet.Position(lit.Pos()), "literal is not rewritten: %s", reason)
+			}
+			return true
+		}
+		fix.node = node
+		fix.pointer = node != ast.Expr(lit)
+		if !fix.pointer && hasNoCopyField(fix.target.fields) {
+			diagnostics.Warnf(file.fileSet.Position(lit.Pos()),
+				"literal is not rewritten: %s contains lock which would be copied", fix.target.root.StructName)
+			return true
+		}
+		fix.multiline = file.fileSet.Position(lit.Lbrace).Line != file.fileSet.Position(lit.Rbrace).Line
+		fixes = append(fixes, fix)
+		return true
+	})
+	sort.SliceStable(fixes, func(i, j int) bool {
+		return fixes[i].node.Pos() < fixes[j].node.Pos()
+	})
+	return fixes
+}
+
+// matchLiteral matches composite literal with builder chain of structure, returns reason if literal of
+// structure cannot be rewritten, or neither fix nor reason if literal is not a literal of processed structure
+func matchLiteral(file *parsedFile, lit *ast.CompositeLit, targets map[string]*fixTarget) (*literalFix, string) {
+	typeExpr := lit.Type
+	typeArgs := ""
+	switch t := typeExpr.(type) {
+	case *ast.IndexExpr:
+		typeExpr, typeArgs = t.X, "["+file.source(t.Index.Pos(), t.Index.End())+"]"
+	case *ast.IndexListExpr:
+		typeExpr = t.X
+		indices := make([]string, 0, len(t.Indices))
+		for _, index := range t.Indices {
+			indices = append(indices, file.source(index.Pos(), index.End()))
+		}
+		typeArgs = "[" + strings.Join(indices, ", ") + "]"
+	}
+	ident, ok := typeExpr.(*ast.Ident)
+	if !ok || targets[ident.Name] == nil {
+		return nil, ""
+	}
+	target := targets[ident.Name]
+	set := make([]fieldValue, 0, len(lit.Elts))
+	values := make(map[string]ast.Expr)
+	for i, elt := range lit.Elts {
+		field := fieldValue{value: elt}
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			key, ok := kv.Key.(*ast.Ident)
+			if !ok {
+				continue
+			}
+			field = fieldValue{name: key.Name, value: kv.Value}
+		} else if len(lit.Elts) != len(target.fields) {
+			return nil, "unkeyed literal does not match fields of " + ident.Name
+		} else {
+			field.name = target.fields[i].FieldName
+		}
+		set = append(set, field)
+		values[field.name] = field.value
+	}
+	inChain := make(map[string]bool)
+	args := make([]ast.Expr, 0, len(target.chain))
+	for _, sf := range target.chain {
+		if sf.Group != "" {
+			return nil, fmt.Sprintf("field %s of builder chain is grouped", sf.FieldName)
+		}
+		value, found := values[sf.FieldName]
+		if !found {
+			return nil, fmt.Sprintf("field %s of builder chain is not set", sf.FieldName)
+		}
+		inChain[sf.FieldName] = true
+		args = append(args, value)
+	}
+	for _, sf := range target.fields {
+		// Build() sets fields with default values left zero, so literal leaving them zero would change meaning,
+		// while fields set explicitly (also to zero values) are assigned after Build() like other optional fields
+		if _, found := values[sf.FieldName]; sf.Default != "" && !found {
+			return nil, fmt.Sprintf("field %s with default value is not set, %s() would set it to %s",
+				sf.FieldName, target.root.StructFlags.BuildName, sf.Default)
+		}
+	}
+	optional := make([]fieldValue, 0)
+	for _, field := range set {
+		if !inChain[field.name] {
+			optional = append(optional, field)
+		}
+	}
+	return &literalFix{target: target, typeName: ident.Name, typeArgs: typeArgs, args: args, optional: optional}, ""
+}
+
+// warnElidedLits reports literals of structures with elided types nested in composite literal (e.g. elements of
+// []Person{{...}}), they are not rewritten
+func warnElidedLits(file *parsedFile, lit *ast.CompositeLit, targets map[string]*fixTarget,
+	diagnostics *Diagnostics) {
+	inspectElidedLits(lit, lit.Type, func(elided *ast.CompositeLit, typ ast.Expr) {
+		switch t := typ.(type) {
+		case *ast.IndexExpr:
+			typ = t.X
+		case *ast.IndexListExpr:
+			typ = t.X
+		}
+		if ident, ok := typ.(*ast.Ident); ok && targets[ident.Name] != nil {
+			diagnostics.Warnf(file.fileSet.Position(elided.Pos()),
+				"literal is not rewritten: type %s of literal is elided, write it explicitly to rewrite literal",
+				ident.Name)
+		}
+	})
+}
+
+func hasNoCopyField(fields []*StructField) bool {
+	for _, sf := range fields {
+		if sf.NoCopy {
+			return true
+		}
+	}
+	return false
+}
+
+func (file *parsedFile) offset(pos token.Pos) int {
+	return file.fileSet.Position(pos).Offset
+}
+
+func (file *parsedFile) source(start token.Pos, end token.Pos) string {
+	return string(file.content[file.offset(start):file.offset(end)])
+}
+
+type literalRewriter struct {
+	file  *parsedFile
+	fixes []*literalFix
+}
+
+// render renders source code between positions with all literals inside of it replaced by builder chains
+func (r *literalRewriter) render(start token.Pos, end token.Pos) string {
+	bld := &strings.Builder{}
+	cursor := start
+	for _, fix := range r.fixes {
+		// literals nested into already rendered literals are rendered as part of their arguments
+		if fix.node.Pos() < cursor || fix.node.End() > end {
+			continue
+		}
+		bld.WriteString(r.file.source(cursor, fix.node.Pos()))
+		bld.WriteString(r.replacement(fix))
+		cursor = fix.node.End()
+	}
+	bld.WriteString(r.file.source(cursor, end))
+	return bld.String()
+}
+
+func (r *literalRewriter) replacement(fix *literalFix) string {
+	separator := "."
+	if fix.multiline {
+		separator = ".\n"
+	}
+	bld := &strings.Builder{}
+	if !fix.pointer {
+		bld.WriteString("*")
+	}
+	bld.WriteString(fix.target.root.constructorName() + "Builder" + fix.typeArgs + "()")
+	for i, sf := range fix.target.chain {
+		bld.WriteString(separator + sf.setterName() + "(" + r.render(fix.args[i].Pos(), fix.args[i].End()) + ")")
+	}
+	bld.WriteString(separator + fix.target.root.StructFlags.BuildName + "()")
+	if len(fix.optional) == 0 {
+		return bld.String()
+	}
+
+	// optional fields are assigned to built structure inside of function literal, so literal stays expression
+	name := r.unusedName(fix.node)
+	typeName := fix.typeName + fix.typeArgs
+	if fix.pointer {
+		typeName = "*" + typeName
+	}
+	body := &strings.Builder{}
+	body.WriteString("func() " + typeName + " {\n")
+	body.WriteString(name + " := " + bld.String() + "\n")
+	for _, field := range fix.optional {
+		body.WriteString(name + "." + field.name + " = " + r.render(field.value.Pos(), field.value.End()) + "\n")
+	}
+	body.WriteString("return " + name + "\n}()")
+	return body.String()
+}
+
+// unusedName returns name of variable holding built structure which is not used by any identifier of literal,
+// so values of literal do not refer to it
+func (r *literalRewriter) unusedName(node ast.Node) string {
+	used := make(map[string]bool)
+	ast.Inspect(node, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Ident); ok {
+			used[ident.Name] = true
+		}
+		return true
+	})
+	name := "v"
+	for i := 2; used[name]; i++ {
+		name = "v" + strconv.Itoa(i)
+	}
+	return name
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files in testdata")
+
+// runGoldenTest copies files of testdata directory into module in temporary directory, runs gobetter with
+// person.go as input file and compares rewritten files with their .golden counterparts. Returns output of
+// diagnostics.
+func runGoldenTest(t *testing.T, testdata string, args []string, setup func(run *fileRun)) string {
+	t.Helper()
+	files := map[string]string{"go.mod": "module t9\n\ngo 1.18\n"}
+	filenames, err := filepath.Glob(filepath.Join(testdata, "*.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, filename := range filenames {
+		content, err := os.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[filepath.Base(filename)] = string(content)
+	}
+	dir := writeTestModule(t, files)
+
+	args = append([]string{"-input", filepath.Join(dir, "person.go"), "-cache-dir", "off"}, args...)
+	var stdout, stderr bytes.Buffer
+	run := &fileRun{
+		opts:     parseCommandLineArgs(args),
+		args:     args,
+		packages: NewPackageCache(nil),
+		stdout:   &stdout,
+		stderr:   &stderr,
+	}
+	run.opts.Force = true
+	setup(run)
+	if code := run.generate(); code != 0 {
+		t.Fatalf("exit code %d:\n%s", code, stderr.String())
+	}
+
+	goldens, err := filepath.Glob(filepath.Join(testdata, "*.golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, golden := range goldens {
+		got, err := os.ReadFile(filepath.Join(dir, strings.TrimSuffix(filepath.Base(golden), ".golden")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *update {
+			if err = os.WriteFile(golden, got, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		want, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s does not match golden file:\n%s", filepath.Base(golden), got)
+		}
+	}
+	return stderr.String()
+}
+
+func TestFixCompositeLiterals(t *testing.T) {
+	diagnostics := runGoldenTest(t, filepath.Join("testdata", "fix"), nil, func(run *fileRun) {
+		run.fix = true
+	})
+
+	for _, warning := range []string{
+		"use.go:14:13: warning: literal is not rewritten: field Age of builder chain is not set",
+		"use.go:15:21: warning: literal is not rewritten: type Person of literal is elided",
+		"use.go:21:4: warning: literal is not rewritten: field Port with default value is not set, Build() would " +
+			"set it to 8080",
+	} {
+		if !strings.Contains(diagnostics, warning) {
+			t.Errorf("warning %q is missing in:\n%s", warning, diagnostics)
+		}
+	}
+}
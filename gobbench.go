@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// makeBenchFilename returns name of file with benchmarks generated for input file (e.g. person_gob_bench_test.go),
+// _GOOS and _GOARCH suffixes of input file are kept before _test suffix (e.g. config_gob_bench_linux_test.go)
+func makeBenchFilename(outDir string, inFilename string) string {
+	name, platform := splitPlatformSuffix(fileNameWithoutExt(filepath.Base(inFilename)))
+	return filepath.Join(outDir, name+"_gob_bench"+platform+"_test.go")
+}
+
+// GenerateBenchHeader generates variable that benchmarks store constructed structures into, so compiler cannot
+// optimize construction away
+func GenerateBenchHeader(anyStyle string) string {
+	return fmt.Sprintf("\nvar gobBenchSink %s\n", anyStyle)
+}
+
+// GenerateBenchmarks generates benchmarks comparing construction of structure with builder chain and with
+// composite literal setting the same fields. Fields are set to zero values of their types (*new(T)), because
+// benchmarks measure overhead of builder rather than of field values. Benchmarks are not generated for generic
+// structures, because there are no type arguments to instantiate them with.
+func GenerateBenchmarks(chain []*StructField) (string, error) {
+	if len(chain) == 0 || chain[0].TypeParams != "" {
+		return "", nil
+	}
+	groups, err := GroupStructFields(chain)
+	if err != nil {
+		return "", err
+	}
+	groupOf := make(map[*StructField]*FieldGroup)
+	for _, group := range groups {
+		groupOf[group.Fields[0]] = group
+	}
+	root := chain[0]
+	builder := &strings.Builder{}
+	literal := &strings.Builder{}
+	builder.WriteString(root.constructorName() + "Builder()")
+	for i := 0; i < len(chain); i++ {
+		sf := chain[i]
+		if group := groupOf[sf]; group != nil {
+			values := make([]string, 0, len(group.Fields))
+			for _, gf := range group.Fields {
+				values = append(values, fmt.Sprintf("%s: *new(%s)", gf.exportName(), gf.FieldTypeText))
+				literal.WriteString(fmt.Sprintf("\t\t\t%s: *new(%s),\n", gf.FieldName, gf.FieldTypeText))
+			}
+			builder.WriteString(fmt.Sprintf(".\n\t\t\t%s(%s{%s})", sf.styledSetterName(strings.Title(group.Name)),
+				group.structName(), strings.Join(values, ", ")))
+			i += len(group.Fields) - 1
+			continue
+		}
+		builder.WriteString(fmt.Sprintf(".\n\t\t\t%s(*new(%s))", sf.setterName(), sf.FieldTypeText))
+		literal.WriteString(fmt.Sprintf("\t\t\t%s: *new(%s),\n", sf.FieldName, sf.FieldTypeText))
+	}
+	builder.WriteString(fmt.Sprintf(".\n\t\t\t%s()", root.StructFlags.BuildName))
+	return fmt.Sprintf(`
+func Benchmark%[1]sBuilder(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		gobBenchSink = %[2]s
+	}
+}
+
+func Benchmark%[1]sLiteral(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		gobBenchSink = &%[4]s{
+%[3]s		}
+	}
+}
+`, strings.Title(root.StructName), builder.String(), literal.String(), root.StructName), nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestBenchmarksCompareBuilderWithLiteral(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod": testModule,
+		"person.go": `package main
+
+type Person struct { //+gob:Constructor
+	Name   string
+	Street string //+gob:group=address
+	City   string //+gob:group=address
+	Nick   string //+gob:_
+}
+`,
+		"main.go": "package main\n\nfunc main() {}\n",
+	}, "person.go", "-bench")
+
+	cmd := exec.Command("go", "test", "-run", "^$", "-bench", ".", "-benchtime", "1x")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOWORK=off", "GOFLAGS=-mod=mod")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("go test failed: %v\n%s", err, output)
+	}
+	for _, name := range []string{"BenchmarkPersonBuilder", "BenchmarkPersonLiteral"} {
+		if !strings.Contains(string(output), name) {
+			t.Errorf("benchmark %s is not run:\n%s", name, output)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// csvName returns name of CSV column of field: name from "csv" tag or field name. Empty string is returned
+// for fields that are not stored in CSV records.
+func (sf *StructField) csvName() string {
+	if sf.NoCopy || sf.Lazy != "" || sf.Computed != "" {
+		return ""
+	}
+	name, _, _ := strings.Cut(reflect.StructTag(sf.Tag).Get("csv"), ",")
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return sf.FieldName
+	}
+	return name
+}
+
+// formatString returns expression formatting field value as string, so it can be parsed back by convertString
+func (sf *StructField) formatString(value string) (string, error) {
+	switch sf.FieldTypeText {
+	case "string":
+		return value, nil
+	case "bool":
+		return fmt.Sprintf("strconv.FormatBool(%s)", value), nil
+	case "int", "int8", "int16", "int32", "int64":
+		return fmt.Sprintf("strconv.FormatInt(int64(%s), 10)", value), nil
+	case "uint", "uint8", "uint16", "uint32", "uint64":
+		return fmt.Sprintf("strconv.FormatUint(uint64(%s), 10)", value), nil
+	case "float32":
+		return fmt.Sprintf("strconv.FormatFloat(float64(%s), 'g', -1, 32)", value), nil
+	case "float64":
+		return fmt.Sprintf("strconv.FormatFloat(%s, 'g', -1, 64)", value), nil
+	case "time.Duration":
+		return value + ".String()", nil
+	}
+	return "", sf.errorf("field %s of struct %s has type %s that cannot be formatted as string",
+		sf.FieldName, sf.StructName, sf.FieldTypeText)
+}
+
+// GenerateCSVHelpers generates <StructName>CSVHeader() function returning names of CSV columns, CSVRecord()
+// method converting structure to CSV record and New<StructName>FromCSVRecord() constructor populating structure
+// from CSV record through builder chain. Empty values of required fields are reported as errors. Values of
+// secret fields are masked in CSV records.
+func GenerateCSVHelpers(
+	root *StructField,
+	fields []*StructField,
+	chain []*StructField,
+	optional []*StructField,
+) (string, error) {
+	if !root.StructFlags.CSV {
+		return "", nil
+	}
+	index := make(map[string]int)
+	names := make([]string, 0, len(fields))
+	values := make([]string, 0, len(fields))
+	for _, sf := range fields {
+		name := sf.csvName()
+		if name == "" {
+			continue
+		}
+		value, err := sf.formatString("v." + sf.FieldName)
+		if err != nil {
+			return "", err
+		}
+		if sf.Secret {
+			value = strconv.Quote(secretMask)
+		}
+		index[name] = len(names)
+		names = append(names, strconv.Quote(name))
+		values = append(values, value)
+	}
+	bld := &strings.Builder{}
+	bld.WriteString(fmt.Sprintf(`
+func %s() []string {
+	return []string{%s}
+}
+
+func (v *%s) CSVRecord() []string {
+	return []string{%s}
+}
+
+`, root.helperName("", "CSVHeader"), strings.Join(names, ", "),
+		root.structType(), strings.Join(values, ", ")))
+	if root.StructFlags.Visibility == NoVisibility {
+		return bld.String(), nil
+	}
+	csvSource := fieldSource{
+		suffix: "FromCSVRecord",
+		params: func(root *StructField) string {
+			return "record []string"
+		},
+		description: "CSV column",
+		missing:     "is empty",
+		key: func(sf *StructField) string {
+			return sf.csvName()
+		},
+		lookup: func(key string) string {
+			return fmt.Sprintf(`len(record) > %d && record[%d] != ""`, index[key], index[key])
+		},
+		convert: func(bld *strings.Builder, sf *StructField, target string, errPrefix string) error {
+			bld.WriteString(fmt.Sprintf("        s := record[%d]\n", index[sf.csvName()]))
+			return convertString(bld, sf, target, errPrefix)
+		},
+	}
+	constructor, err := generateSourceConstructor(root, chain, optional, &csvSource)
+	if err != nil {
+		return "", err
+	}
+	bld.WriteString(constructor)
+	return bld.String(), nil
+}
@@ -0,0 +1,34 @@
+package main
+
+import "testing"
+
+func TestCSVRecordRoundTrip(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod": testModule,
+		"person.go": `package main
+
+type Person struct { //+gob:Constructor +gob:csv
+	Name  string ` + "`csv:\"full_name\"`" + `
+	Age   int
+	Token string //+gob:_ +gob:secret
+	Notes string ` + "`csv:\"-\"`" + ` //+gob:_
+}
+`,
+		"main.go": `package main
+
+import "fmt"
+
+func main() {
+	p := NewPersonBuilder().Name("a").Age(7).Build()
+	p.Token = "t"
+	record := p.CSVRecord()
+	q, err := NewPersonFromCSVRecord(record)
+	fmt.Println(PersonCSVHeader(), record, q.Name, q.Age, err)
+	_, err = NewPersonFromCSVRecord([]string{"", "1"})
+	fmt.Println(err != nil)
+}
+`,
+	}, "person.go")
+
+	assertOutput(t, runTestModule(t, dir), "[full_name Age Token] [a 7 ***] a 7 <nil>", "true")
+}
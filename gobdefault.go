@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// defaultFuncPrefix marks default value returned by function, e.g. "+gob:default=func:newUUID", so defaults
+// can be dynamic (UUIDs, timestamps, random ports etc.)
+const defaultFuncPrefix = "func:"
+
+// defaultFuncCall validates function returning default value of field and returns expression calling it.
+// Function must take no parameters and return single value of field type. Functions of package of input
+// file are looked up in its source files, functions of imported packages (e.g. "func:uuid.NewString") are
+// checked with type-checker.
+func (sp *StructParser) defaultFuncCall(astFile *ast.File, srcDir string, sf *StructField) (string, error) {
+	name := strings.TrimPrefix(sf.Default, defaultFuncPrefix)
+	pkgName, funcName, qualified := strings.Cut(name, ".")
+	if !qualified {
+		pkgName, funcName = "", name
+	}
+	if !token.IsIdentifier(funcName) || (qualified && !token.IsIdentifier(pkgName)) {
+		return "", sf.errorf("default value of field %s of struct %s must be function name, e.g. func:newID",
+			sf.FieldName, sf.StructName)
+	}
+	var resultType string
+	var err error
+	if qualified {
+		resultType, err = sp.importedFuncResult(astFile, srcDir, pkgName, funcName)
+	} else {
+		resultType, err = sp.localFuncResult(astFile, srcDir, funcName)
+	}
+	if err != nil {
+		return "", sf.errorf("default value of field %s of struct %s: %v", sf.FieldName, sf.StructName, err)
+	}
+	if resultType != typeExprString(sf.FieldTypeText) {
+		return "", sf.errorf("default value of field %s of struct %s: function %s returns %s, must return %s",
+			sf.FieldName, sf.StructName, name, resultType, sf.FieldTypeText)
+	}
+	return name + "()", nil
+}
+
+// localFuncResult returns type of single result of function without parameters declared in package of input
+// file. Files of package are parsed once per run by package cache, files generated by gobetter are not searched.
+func (sp *StructParser) localFuncResult(astFile *ast.File, srcDir string, name string) (string, error) {
+	files := []*ast.File{astFile}
+	for _, file := range sp.packages.DirFiles(srcDir) {
+		if file.Name.Name == astFile.Name.Name {
+			files = append(files, file)
+		}
+	}
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv != nil || fn.Name.Name != name {
+				continue
+			}
+			if fn.Type.Params.NumFields() != 0 || fn.Type.Results.NumFields() != 1 {
+				return "", fmt.Errorf("function %s must take no parameters and return single value", name)
+			}
+			return types.ExprString(fn.Type.Results.List[0].Type), nil
+		}
+	}
+	return "", fmt.Errorf("function %s is not found", name)
+}
+
+// importedFuncResult returns type of single result of function without parameters of imported package, type
+// is spelled with the name package is imported by
+func (sp *StructParser) importedFuncResult(astFile *ast.File, srcDir string, pkgName string,
+	name string) (string, error) {
+	srcDir, err := filepath.Abs(srcDir)
+	if err != nil {
+		return "", err
+	}
+	var pkg *types.Package
+	for _, i := range astFile.Imports {
+		path, _ := strconv.Unquote(i.Path.Value)
+		if i.Name != nil && i.Name.Name != pkgName {
+			continue
+		}
+		if i.Name == nil {
+			if name, err := sp.packages.PackageName(path, srcDir); err != nil || name != pkgName {
+				continue
+			}
+		}
+		if pkg, err = sp.importPackage(path, srcDir); err != nil {
+			return "", err
+		}
+		break
+	}
+	if pkg == nil {
+		return "", fmt.Errorf("package %s is not imported", pkgName)
+	}
+	fn, ok := pkg.Scope().Lookup(name).(*types.Func)
+	if !ok {
+		return "", fmt.Errorf("function %s.%s is not found", pkgName, name)
+	}
+	sig := fn.Type().(*types.Signature)
+	if sig.Params().Len() != 0 || sig.Results().Len() != 1 {
+		return "", fmt.Errorf("function %s.%s must take no parameters and return single value", pkgName, name)
+	}
+	return types.TypeString(sig.Results().At(0).Type(), func(p *types.Package) string {
+		if p == pkg {
+			return pkgName
+		}
+		return p.Name()
+	}), nil
+}
+
+// typeExprString returns canonical spelling of type, so types written with different spacing can be compared
+func typeExprString(typeText string) string {
+	if expr := parseFieldType(typeText); expr != nil {
+		return types.ExprString(expr)
+	}
+	return typeText
+}
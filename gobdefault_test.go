@@ -0,0 +1,45 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFunctionDefaultsAreCalledByBuild(t *testing.T) {
+	files := map[string]string{
+		"go.mod":     testModule,
+		"ids/ids.go": "package ids\n\nfunc Next() int64 {\n\treturn 42\n}\n",
+		"ports.go":   "package main\n\nfunc randomPort() int {\n\treturn 8080\n}\n",
+		"main.go":    "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(*NewServerBuilder().Host(\"a\").Build())\n}\n",
+	}
+	server := `package main
+
+import "t9/ids"
+
+var _ = ids.Next
+
+type Server struct { //+gob:Constructor
+	Host string
+	Port int   //+gob:default=func:randomPort
+	ID   int64 //+gob:default=func:ids.Next
+}
+`
+	dir := generateTestModule(t, withTestFile(files, "server.go", server), "server.go")
+	assertOutput(t, runTestModule(t, dir), "{a 8080 42}")
+
+	for _, test := range []struct {
+		from    string
+		to      string
+		message string
+	}{
+		{from: "func:randomPort", to: "func:missing", message: "function missing is not found"},
+		{from: "func:ids.Next", to: "func:ids.Prev", message: "function ids.Prev is not found"},
+		{from: "ID   int64", to: "ID   int32", message: "function ids.Next returns int64, must return int32"},
+	} {
+		dir := writeTestModule(t, withTestFile(files, "server.go", strings.Replace(server, test.from, test.to, 1)))
+		if code, diagnostics := generateTestFile(t, dir, "server.go"); code != ExitAnnotation ||
+			!strings.Contains(diagnostics, test.message) {
+			t.Errorf("%s: exit code %d:\n%s", test.to, code, diagnostics)
+		}
+	}
+}
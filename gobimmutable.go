@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"go/token"
+	"strings"
+)
+
+// immutableName returns name of read-only variant of structure (e.g. ImmutablePerson), it is package-level for
+// structures with package-level constructors
+func (sf *StructField) immutableName() string {
+	if strings.HasPrefix(sf.constructorName(), "new") {
+		return "immutable" + strings.Title(sf.StructName)
+	}
+	return "Immutable" + strings.Title(sf.StructName)
+}
+
+// immutableFields returns fields of read-only variant of structure: fields with names of fields of structure
+// unexported (e.g. firstName for FirstName) and without annotations except of +gob:secret, fields holding locks
+// are left out
+func immutableFields(root *StructField, fields []*StructField) []*StructField {
+	flags := *root.StructFlags
+	flags.Derived = nil
+	flags.Validated = nil
+	flags.Chunk = 0
+	flags.ConstructorDoc = ""
+	if flags.Visibility == NoVisibility {
+		flags.Visibility = ExportedVisibility
+	}
+	immutable := make([]*StructField, 0, len(fields))
+	for _, sf := range fields {
+		if sf.NoCopy {
+			continue
+		}
+		name := lowerFirst(sf.FieldName)
+		if token.IsKeyword(name) {
+			name += "_"
+		}
+		immutable = append(immutable, &StructField{
+			StructFlags:   &flags,
+			StructName:    root.immutableName(),
+			FieldName:     name,
+			FieldTypeText: sf.FieldTypeText,
+			Acronym:       strings.ToUpper(sf.FieldName) == sf.FieldName,
+			Secret:        sf.Secret,
+			NoGetter:      sf.NoGetter,
+			Zero:          sf.Zero,
+			TypeParams:    sf.TypeParams,
+			TypeArgs:      sf.TypeArgs,
+			Pos:           sf.Pos,
+		})
+	}
+	return immutable
+}
+
+// GenerateImmutable generates read-only variant of structure with exported fields (e.g. ImmutablePerson for
+// Person): structure with unexported fields, getters, builder and converters both ways, so mutable DTOs can
+// be replaced with encapsulated domain objects incrementally. All fields are required by builder of read-only
+// variant, because they cannot be changed after it is built. Values of secret fields are masked by String()
+// method of read-only variant the same way as they are masked for structure.
+func GenerateImmutable(root *StructField, fields []*StructField) string {
+	if !root.StructFlags.Immutable {
+		return ""
+	}
+	immutable := immutableFields(root, fields)
+	if len(immutable) == 0 {
+		return ""
+	}
+	immutableName := root.immutableName()
+	immutableType := immutableName + root.TypeArgs
+	bld := &strings.Builder{}
+	bld.WriteString(fmt.Sprintf(`
+// %s is read-only variant of %s
+type %s%s struct {
+`, immutableName, root.StructName, immutableName, root.TypeParams))
+	for _, sf := range immutable {
+		bld.WriteString(fmt.Sprintf("\t%s %s\n", sf.FieldName, sf.FieldTypeText))
+	}
+	bld.WriteString("}\n\n")
+	for _, sf := range immutable {
+		if sf.NoGetter {
+			continue
+		}
+		bld.WriteString(fmt.Sprintf(`
+func (v *%s) %s() %s {
+	return v.%s
+}
+
+`, immutableType, sf.exportName(), sf.FieldTypeText, sf.FieldName))
+	}
+	immutableRoot := &StructField{
+		StructFlags: immutable[0].StructFlags,
+		StructName:  immutableName,
+		TypeParams:  root.TypeParams,
+		TypeArgs:    root.TypeArgs,
+	}
+	bld.WriteString(GenerateStringer(immutableRoot, immutable))
+
+	immutable[0].StructFlags.ConstructorDoc = GenerateConstructorDoc(immutable, nil)
+	for i, sf := range immutable {
+		var prev *StructField
+		if i > 0 {
+			prev = immutable[i-1]
+		}
+		bld.WriteString(sf.GenerateSourceCodeForStructField(immutable[0], prev, i == len(immutable)-1))
+	}
+
+	bld.WriteString(fmt.Sprintf(`
+// ToImmutable returns read-only copy of %s
+func (v *%s) ToImmutable() *%s {
+	return &%s{
+`, root.StructName, root.structType(), immutableType, immutableType))
+	copied := 0
+	for _, sf := range fields {
+		if !sf.NoCopy {
+			bld.WriteString(fmt.Sprintf("\t\t%s: v.%s,\n", immutable[copied].FieldName, sf.FieldName))
+			copied++
+		}
+	}
+	bld.WriteString(fmt.Sprintf(`	}
+}
+
+// ToMutable returns mutable copy of %s
+func (v *%s) ToMutable() *%s {
+	return &%s{
+`, immutableName, immutableType, root.structType(), root.structType()))
+	copied = 0
+	for _, sf := range fields {
+		if !sf.NoCopy {
+			bld.WriteString(fmt.Sprintf("\t\t%s: v.%s,\n", sf.FieldName, immutable[copied].FieldName))
+			copied++
+		}
+	}
+	bld.WriteString("\t}\n}\n\n")
+	return bld.String()
+}
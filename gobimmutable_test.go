@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+func TestImmutableVariantOfStruct(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod": testModule,
+		"person.go": `package main
+
+type Person struct { //+gob:Constructor +gob:immutable
+	Name     string
+	Age      int
+	Password string //+gob:_ +gob:secret
+}
+`,
+		"main.go": `package main
+
+import "fmt"
+
+func main() {
+	p := NewPersonBuilder().Name("a").Age(1).Build()
+	p.Password = "p"
+	v := p.ToImmutable()
+	p.Name = "b"
+	fmt.Println(v.Name(), v.Age(), v.Password(), *v.ToMutable())
+	w := NewImmutablePersonBuilder().Name("c").Age(2).Password("q").Build()
+	fmt.Println(w)
+}
+`,
+	}, "person.go")
+
+	assertOutput(t, runTestModule(t, dir), "a 1 p {Name:a Age:1 Password:***}", "{name:c age:2 password:***}")
+}
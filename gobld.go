@@ -3,8 +3,15 @@ package main
 import (
 	"fmt"
 	"go/ast"
+	"go/build/constraint"
+	"go/parser"
+	"go/printer"
 	"go/token"
+	"go/types"
+	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"unicode"
 )
@@ -20,13 +27,50 @@ const (
 type StructParser struct {
 	fileSet                   *token.FileSet
 	fileContent               []byte
-	whitespaceRegexp          *regexp.Regexp
+	comments                  []*ast.CommentGroup
+	packages                  *PackageCache
+	anyStyle                  string
 	constructorExportedRegexp *regexp.Regexp
 	constructorPackageRegexp  *regexp.Regexp
 	constructorNoRegexp       *regexp.Regexp
 	flagOptionalRegexp        *regexp.Regexp
+	flagRequiredRegexp        *regexp.Regexp
+	flagSecretRegexp          *regexp.Regexp
 	flagGetterRegexp          *regexp.Regexp
 	flagAcronymRegex          *regexp.Regexp
+	flagGroupRegexp           *regexp.Regexp
+	flagProvideRegexp         *regexp.Regexp
+	flagFromRegexp            *regexp.Regexp
+	flagSliceRegexp           *regexp.Regexp
+	flagCollectorRegexp       *regexp.Regexp
+	flagMergeRegexp           *regexp.Regexp
+	flagPatchRegexp           *regexp.Regexp
+	flagImmutableRegexp       *regexp.Regexp
+	flagViewRegexp            *regexp.Regexp
+	flagLazyRegexp            *regexp.Regexp
+	flagComputedRegexp        *regexp.Regexp
+	flagDefaultRegexp         *regexp.Regexp
+	flagValidateRegexp        *regexp.Regexp
+	flagEnvRegexp             *regexp.Regexp
+	flagMapRegexp             *regexp.Regexp
+	flagFormRegexp            *regexp.Regexp
+	flagJSONRegexp            *regexp.Regexp
+	flagSlogRegexp            *regexp.Regexp
+	flagProtoRegexp           *regexp.Regexp
+	flagScanRegexp            *regexp.Regexp
+	flagColumnsRegexp         *regexp.Regexp
+	flagCSVRegexp             *regexp.Regexp
+	flagYAMLRegexp            *regexp.Regexp
+	flagTOMLRegexp            *regexp.Regexp
+	flagBindRegexp            *regexp.Regexp
+	flagSkipRegexp            *regexp.Regexp
+	flagChunkRegexp           *regexp.Regexp
+	annotationRegexp          *regexp.Regexp
+	annotationListRegexp      *regexp.Regexp
+
+	// typeDocs holds doc comments of type declarations, doc comment of "type X struct" declaration belongs
+	// to declaration rather than to type spec
+	typeDocs map[*ast.TypeSpec]*ast.CommentGroup
 }
 
 type StructField struct {
@@ -35,48 +79,413 @@ type StructField struct {
 	FieldName     string
 	FieldTypeText string
 	Acronym       bool
+	Group         string
+	Lazy          string
+	Computed      string
+	Env           string
+	Tag           string
+	TypeParams    string
+	TypeArgs      string
+	// NoCopy is set for fields holding locks (sync.Mutex etc.) by value, which must not be copied
+	NoCopy bool
+	// Secret is set for fields which values must be masked by generated stringers and serializers
+	Secret bool
+	// NoGetter is set for secret fields which getters must not be generated (+gob:secret=nogetter)
+	NoGetter bool
+	// Annotations are all +gob: annotations of field, e.g. "+gob:getter"
+	Annotations []string
+	// Pos is position of field name in input file, it is not set for fields of structures from other packages
+	Pos token.Pos
+	// Default is Go expression assigned to field by Build() function, field is excluded from builder chain
+	Default string
+	// Validations are checks of field value performed by BuildE() function
+	Validations []Validation
+	// Zero is zero value of field type that field is compared with (e.g. `""`, `0` or `nil`), it is empty if field
+	// type is not known to be comparable, then generated code checks whether field is zero with reflection
+	Zero string
+}
+
+type FieldGroup struct {
+	Name   string
+	Fields []*StructField
+	Next   *StructField
 }
 
 type StructFlags struct {
 	ProcessStruct bool
+	Skip          bool
 	PtrReceiver   bool
 	Visibility    Visibility
+	Provide       bool
+	// BuilderFrom enables generation of function creating builder from existing value of structure
+	BuilderFrom bool
+	// SliceBuilder enables generation of batch builder returning slice of structures
+	SliceBuilder bool
+	// Collector enables generation of collector gathering structures built in loop
+	Collector     bool
+	GetterStyle   string
+	SetterStyle   string
+	FinalizerName string
+	BuildName     string
+	// BuilderVisibility specifies whether builder chain types are exported or package-level
+	BuilderVisibility Visibility
+	// Naming specifies naming scheme of generated types: "legacy" (Person_Builder_FirstName) or
+	// "camel" (PersonBuilderFirstName)
+	Naming   string
+	AnyStyle string
+	FromMap  bool
+	FromForm bool
+	FromJSON bool
+	FromYAML bool
+	TOML     bool
+	Bind     bool
+	// WebFramework is web framework ("gin" or "echo") used by request binding helper
+	WebFramework string
+	Slog         bool
+	// Proto is protobuf message (e.g. "pb.Person") to generate converters for
+	Proto   string
+	Scan    bool
+	Columns bool
+	CSV     bool
+	Merge   bool
+	Patch   bool
+	// Immutable enables generation of read-only variant of structure
+	Immutable bool
+	// View holds names of fields of view structure
+	View []string
+	// Chunk is number of fields set by every section setter of chunked builder chain, 0 if chain is not chunked
+	Chunk int
+	// Derived holds fields populated by Build() function (lazy, computed and defaulted fields)
+	Derived []*StructField
+	// Validated holds fields with validations checked by BuildE() function
+	Validated []*StructField
+	// ConstructorDoc is doc comment of builder constructor
+	ConstructorDoc string
 }
 
-func GeneratePackage(astFile *ast.File) string {
+func GeneratePackage(astFile *ast.File, signature string, source string) string {
 	bld := &strings.Builder{}
-	bld.WriteString("// Code generated by gobetter; DO NOT EDIT.\n\n")
+	if constraints := buildConstraints(astFile); len(constraints) > 0 {
+		bld.WriteString(strings.Join(constraints, "\n") + "\n\n")
+	}
+	bld.WriteString("// Code generated by gobetter; DO NOT EDIT.\n")
+	bld.WriteString(signaturePrefix + signature + "\n")
+	if source != "" {
+		bld.WriteString(sourcePrefix + source + "\n")
+	}
+	bld.WriteString("\n")
 	bld.WriteString(fmt.Sprintf("package %s\n\n", astFile.Name.Name))
 	return bld.String()
 }
 
-func GenerateImports(astFile *ast.File) string {
+// buildConstraints returns build constraint lines (//go:build and legacy // +build) of input file, generated
+// file gets the same constraints, so code generated for platform-specific structures is platform-specific too
+func buildConstraints(astFile *ast.File) []string {
+	constraints := make([]string, 0)
+	for _, group := range astFile.Comments {
+		if group.Pos() >= astFile.Package {
+			break
+		}
+		for _, comment := range group.List {
+			if constraint.IsGoBuild(comment.Text) || constraint.IsPlusBuild(comment.Text) {
+				constraints = append(constraints, comment.Text)
+			}
+		}
+	}
+	return constraints
+}
+
+type importSpec struct {
+	name string // explicit import name, empty if package is imported by its default name
+	path string
+	// pkgName is name of package when it is known without resolving import path (e.g. for packages of fields
+	// of structures declared in other packages), empty otherwise
+	pkgName string
+	// group is used to separate imports into blocks, imports are not grouped if empty
+	group string
+}
+
+// importSpecs returns imports of input file (except side-effect imports) followed by imports of packages
+// referenced by fields of structures declared in other packages and by imports of custom regions that are not
+// imported yet (see readRegionImports)
+func importSpecs(astFile *ast.File, foreignImports map[string]string, regionImports []importSpec) []importSpec {
+	specs := fileImportSpecs(astFile)
+	foreignPaths := make([]string, 0, len(foreignImports))
+	for path := range foreignImports {
+		foreignPaths = append(foreignPaths, path)
+	}
+	sort.Strings(foreignPaths)
+	for _, path := range foreignPaths {
+		if name := foreignImports[path]; name != filepath.Base(path) {
+			specs = append(specs, importSpec{name: name, path: path, pkgName: name})
+		} else {
+			specs = append(specs, importSpec{path: path, pkgName: name})
+		}
+	}
+	imported := make(map[string]bool)
+	for _, spec := range specs {
+		imported[spec.path] = true
+	}
+	for _, spec := range regionImports {
+		if !imported[spec.path] {
+			specs = append(specs, spec)
+			imported[spec.path] = true
+		}
+	}
+	return specs
+}
+
+// fileImportSpecs returns imports of file except side-effect imports
+func fileImportSpecs(file *ast.File) []importSpec {
+	specs := make([]importSpec, 0)
+	for _, i := range file.Imports {
+		path, _ := strconv.Unquote(i.Path.Value)
+		switch {
+		case i.Name == nil:
+			specs = append(specs, importSpec{path: path})
+		case i.Name.Name == "_":
+			// side-effect imports are never referenced by generated code
+		default:
+			// keep import alias, because field types refer to the package by alias
+			specs = append(specs, importSpec{name: i.Name.Name, path: path})
+		}
+	}
+	return specs
+}
+
+func writeImports(specs []importSpec) string {
 	bld := &strings.Builder{}
 	bld.WriteString("import (\n")
-	for _, i := range astFile.Imports {
-		bld.WriteString(fmt.Sprintf("\t%s\n", i.Path.Value))
+	for i, spec := range specs {
+		if spec.group != "" && i > 0 && specs[i-1].group != spec.group {
+			bld.WriteString("\n")
+		}
+		if spec.name != "" {
+			bld.WriteString(fmt.Sprintf("\t%s %s\n", spec.name, strconv.Quote(spec.path)))
+		} else {
+			bld.WriteString(fmt.Sprintf("\t%s\n", strconv.Quote(spec.path)))
+		}
 	}
 	bld.WriteString(")\n\n")
 	return bld.String()
 }
 
+// GenerateImports generates import block with all imports of input file, unused imports are expected
+// to be removed by goimports
+func GenerateImports(astFile *ast.File, foreignImports map[string]string, regionImports []importSpec) string {
+	return writeImports(importSpecs(astFile, foreignImports, regionImports))
+}
+
+// generatedCodeImports are packages that can be referenced by generated code
+var generatedCodeImports = map[string]string{
+	"errors":  "errors",
+	"fmt":     "fmt",
+	"io":      "io",
+	"json":    "encoding/json",
+	"slog":    "log/slog",
+	"sql":     "database/sql",
+	"os":      "os",
+	"reflect": "reflect",
+	"strconv": "strconv",
+	"strings": "strings",
+	"time":    "time",
+	"url":     "net/url",
+	"yaml":    "gopkg.in/yaml.v3",
+	"zapcore": "go.uber.org/zap/zapcore",
+	"zerolog": "github.com/rs/zerolog",
+}
+
+// GenerateUsedImports generates import block with only those imports that are referenced by generated
+// code, so output file does not have to be processed by goimports. Names of imported packages are resolved
+// from source directory of input file rather than guessed from import paths, dot imports are kept when
+// generated code refers to declarations of their packages. Same as goimports, imports are grouped into
+// standard, third-party and local (with import path starting with one of comma-separated local prefixes)
+// packages.
+func GenerateUsedImports(packages *PackageCache, srcDir string, astFile *ast.File, foreignImports map[string]string,
+	regionImports []importSpec, code string, local string) (string, error) {
+	codeFile, err := parser.ParseFile(token.NewFileSet(), "", "package "+astFile.Name.Name+"\n\n"+code, 0)
+	if err != nil {
+		return "", err
+	}
+	// identifiers that are not declared by generated code refer to imported packages, to declarations of
+	// input package or to declarations of dot-imported packages
+	unresolved := make(map[*ast.Ident]bool)
+	for _, ident := range codeFile.Unresolved {
+		unresolved[ident] = true
+	}
+	used := make(map[string]bool)
+	ast.Inspect(codeFile, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if ident, ok := sel.X.(*ast.Ident); ok && unresolved[ident] {
+				used[ident.Name] = true
+			}
+		}
+		return true
+	})
+	specs := make([]importSpec, 0)
+	for _, spec := range importSpecs(astFile, foreignImports, regionImports) {
+		if spec.name == "." {
+			if dotUsed, err := isDotImportUsed(packages, srcDir, spec.path, codeFile.Unresolved); err != nil {
+				return "", err
+			} else if dotUsed {
+				specs = append(specs, spec)
+			}
+			continue
+		}
+		if len(used) == 0 {
+			continue
+		}
+		name := spec.name
+		if name == "" {
+			name = spec.pkgName
+		}
+		if name == "" {
+			if name, err = packages.PackageName(spec.path, srcDir); err != nil {
+				return "", fmt.Errorf("cannot resolve name of imported package %s (%v), import it with explicit "+
+					"name or pass -fix-imports", spec.path, err)
+			}
+		}
+		if used[name] {
+			specs = append(specs, spec)
+			delete(used, name)
+		}
+	}
+	names := make([]string, 0)
+	for name := range generatedCodeImports {
+		if used[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		specs = append(specs, importSpec{path: generatedCodeImports[name]})
+	}
+	if len(specs) == 0 {
+		return "", nil
+	}
+	for i := range specs {
+		specs[i].group = importGroup(specs[i].path, local)
+	}
+	sort.SliceStable(specs, func(i, j int) bool {
+		if specs[i].group != specs[j].group {
+			return specs[i].group < specs[j].group
+		}
+		return specs[i].path < specs[j].path
+	})
+	return writeImports(specs), nil
+}
+
+// isDotImportUsed reports whether generated code refers to exported declarations of dot-imported package by
+// unresolved identifiers of generated code
+func isDotImportUsed(packages *PackageCache, srcDir string, path string, unresolved []*ast.Ident) (bool, error) {
+	candidates := make([]string, 0)
+	for _, ident := range unresolved {
+		if ident.IsExported() {
+			candidates = append(candidates, ident.Name)
+		}
+	}
+	if len(candidates) == 0 {
+		return false, nil
+	}
+	srcDir, err := filepath.Abs(srcDir)
+	if err != nil {
+		return false, err
+	}
+	pkg, err := packages.Import(path, srcDir)
+	if err != nil {
+		return false, fmt.Errorf("cannot tell whether dot-imported package %s is used (%v), pass -fix-imports",
+			path, err)
+	}
+	for _, name := range candidates {
+		if obj := pkg.Scope().Lookup(name); obj != nil && obj.Exported() {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// importGroup returns sortable name of import group: "0std" for standard packages, "1ext" for third-party
+// packages and "2local" for packages matching local prefixes
+func importGroup(path string, local string) string {
+	for _, prefix := range strings.Split(local, ",") {
+		prefix = strings.TrimSpace(prefix)
+		if prefix != "" && (path == prefix || strings.HasPrefix(path, strings.TrimSuffix(prefix, "/")+"/")) {
+			return "2local"
+		}
+	}
+	if first, _, _ := strings.Cut(path, "/"); !strings.Contains(first, ".") {
+		return "0std"
+	}
+	return "1ext"
+}
+
+// GenerateMockDirective generates go:generate directive creating mocks (in _mock_test.go file) for
+// interfaces that are declared in input file and used by fields of processed structs
+func GenerateMockDirective(astFile *ast.File, inFilename string, mockTool string, interfaces []string) string {
+	if len(interfaces) == 0 {
+		return ""
+	}
+	inBase := filepath.Base(inFilename)
+	mockFilename := strings.TrimSuffix(inBase, filepath.Ext(inBase)) + "_mock_test.go"
+	switch mockTool {
+	case "moq":
+		return fmt.Sprintf("//go:generate moq -out %s . %s\n\n", mockFilename, strings.Join(interfaces, " "))
+	case "mockgen":
+		return fmt.Sprintf("//go:generate mockgen -source=%s -destination=%s -package=%s\n\n",
+			inBase, mockFilename, astFile.Name.Name)
+	}
+	return ""
+}
+
+// errorf returns error caused by field, so it is reported at position of the field
+func (sf *StructField) errorf(format string, a ...interface{}) error {
+	return &PositionError{Pos: sf.Pos, Err: fmt.Errorf(format, a...)}
+}
+
+// GenerateGetter generates getter of field, nothing is generated for secret fields with suppressed getters
 func (sf *StructField) GenerateGetter() string {
-	var addedFieldName string
-	if sf.Acronym {
-		addedFieldName = strings.ToUpper(sf.FieldName)
-	} else {
-		addedFieldName = strings.Title(sf.FieldName)
+	if sf.NoGetter {
+		return ""
 	}
-	return fmt.Sprintf(`
+	addedFieldName := sf.exportName()
+	if sf.StructFlags.GetterStyle == "get" {
+		addedFieldName = "Get" + addedFieldName
+	}
+	if sf.NoCopy {
+		// locks must not be copied, so getter returns pointer to the field
+		return fmt.Sprintf(`
+func (v *%s) %s() *%s {
+	return &v.%s
+}
+
+`, sf.structType(), addedFieldName, sf.FieldTypeText,
+			sf.FieldName)
+	}
+	getter := fmt.Sprintf(`
 func (v *%s) %s() %s {
 	return v.%s
 }
 
-`, sf.StructName, addedFieldName, sf.FieldTypeText,
+`, sf.structType(), addedFieldName, sf.FieldTypeText,
+		sf.FieldName)
+	if sf.StructFlags.GetterStyle != "must" {
+		return getter
+	}
+	return getter + fmt.Sprintf(`
+func (v *%s) Must%s() %s {
+	if %s {
+		panic("%s.%s is not set")
+	}
+	return v.%s
+}
+
+`, sf.structType(), addedFieldName, sf.FieldTypeText,
+		sf.zeroCheck("v", false),
+		sf.StructName, sf.FieldName,
 		sf.FieldName)
 }
 
-func (sf *StructField) GenerateSourceCodeForStructField(prev *StructField, last bool) string {
+func (sf *StructField) GenerateSourceCodeForStructField(first *StructField, prev *StructField, last bool) string {
 	bld := &strings.Builder{}
 	if prev == nil {
 		sf.generateConstructor(bld)
@@ -86,51 +495,264 @@ func (sf *StructField) GenerateSourceCodeForStructField(prev *StructField, last
 		sf.generateBuilderSetter(bld, prev)
 	}
 	if last {
-		finalSf := StructField{
-			StructFlags:   sf.StructFlags,
-			StructName:    sf.StructName,
-			FieldName:     "GobFinalizer",
-			FieldTypeText: "AAAAAA",
-			Acronym:       false,
-		}
+		finalSf := sf.finalizer()
 		finalSf.generateBuilderStruct(bld)
 		finalSf.generateBuilderSetter(bld, sf)
 		finalSf.generateBuildFunction(bld)
+		finalSf.generateBuildEFunction(bld)
+		finalSf.generateResetFunction(bld, first)
 	}
 	return bld.String()
 }
 
-func (sf *StructField) generateBuildFunction(bld *strings.Builder) {
-	builderStructName := sf.builderFieldStructName()
+// SortStructFields reorders builder chain fields according to "sort" command-line flag. Fields of the same group
+// are sorted as single unit ordered by the first field of group and keep their order within group, so sorting
+// never separates them.
+func SortStructFields(fields []*StructField, order string) {
+	var less func(a, b *StructField) bool
+	switch order {
+	case "abc":
+		less = func(a, b *StructField) bool {
+			return a.exportName() < b.exportName()
+		}
+	case "type":
+		less = func(a, b *StructField) bool {
+			if a.FieldTypeText != b.FieldTypeText {
+				return a.FieldTypeText < b.FieldTypeText
+			}
+			return a.exportName() < b.exportName()
+		}
+	default:
+		return
+	}
+	units := make([][]*StructField, 0, len(fields))
+	for i := 0; i < len(fields); {
+		j := i + 1
+		for fields[i].Group != "" && j < len(fields) && fields[j].Group == fields[i].Group {
+			j++
+		}
+		units = append(units, append([]*StructField(nil), fields[i:j]...))
+		i = j
+	}
+	sort.SliceStable(units, func(i, j int) bool {
+		return less(units[i][0], units[j][0])
+	})
+	fields = fields[:0]
+	for _, unit := range units {
+		fields = append(fields, unit...)
+	}
+}
+
+// CheckStructFieldGroups checks that fields of every group are declared next to each other, it must be called
+// before fields are sorted, because sorting keeps fields of group together
+func CheckStructFieldGroups(fields []*StructField) error {
+	seen := make(map[string]bool)
+	for i, sf := range fields {
+		if sf.Group == "" || i > 0 && fields[i-1].Group == sf.Group {
+			continue
+		}
+		if seen[sf.Group] {
+			return sf.errorf("fields of group \"%s\" in struct %s must be declared next to each other",
+				sf.Group, sf.StructName)
+		}
+		seen[sf.Group] = true
+	}
+	return nil
+}
+
+// GroupStructFields combines consecutive fields marked with the same group annotation
+func GroupStructFields(fields []*StructField) ([]*FieldGroup, error) {
+	if err := CheckStructFieldGroups(fields); err != nil {
+		return nil, err
+	}
+	groups := make([]*FieldGroup, 0)
+	for i := 0; i < len(fields); {
+		name := fields[i].Group
+		if name == "" {
+			i++
+			continue
+		}
+		j := i
+		for j < len(fields) && fields[j].Group == name {
+			j++
+		}
+		group := &FieldGroup{Name: name, Fields: fields[i:j]}
+		if j < len(fields) {
+			group.Next = fields[j]
+		} else {
+			group.Next = fields[i].finalizer()
+		}
+		groups = append(groups, group)
+		i = j
+	}
+	return groups, nil
+}
+
+// ChunkStructFields splits builder chain of structure annotated with +gob:chunk=<n> into sections of n fields.
+// Sections are groups named "section1", "section2" etc., but unlike regular groups, they replace setters of
+// individual fields, so builder chain has one type per section rather than one type per field.
+func ChunkStructFields(fields []*StructField) error {
+	for i, sf := range fields {
+		if sf.Group != "" {
+			return sf.errorf("field %s of struct %s cannot be grouped, because builder chain is chunked",
+				sf.FieldName, sf.StructName)
+		}
+		sf.Group = fmt.Sprintf("section%d", i/sf.StructFlags.Chunk+1)
+	}
+	return nil
+}
+
+// GenerateSourceCodeForChunks generates constructor and builder types of chunked builder chain, setters of
+// sections are generated as setters of groups
+func GenerateSourceCodeForChunks(fields []*StructField) string {
+	bld := &strings.Builder{}
+	first := fields[0]
+	first.generateConstructor(bld)
+	for i := 0; i < len(fields); i += first.StructFlags.Chunk {
+		fields[i].generateBuilderStruct(bld)
+	}
+	finalSf := first.finalizer()
+	finalSf.generateBuilderStruct(bld)
+	finalSf.generateBuildFunction(bld)
+	finalSf.generateBuildEFunction(bld)
+	finalSf.generateResetFunction(bld, first)
+	return bld.String()
+}
+
+// BuilderTypeCount returns number of types of builder chain, including finalizer
+func BuilderTypeCount(chain []*StructField) int {
+	if len(chain) == 0 {
+		return 0
+	}
+	if chunk := chain[0].StructFlags.Chunk; chunk > 0 {
+		return (len(chain)+chunk-1)/chunk + 1
+	}
+	return len(chain) + 1
+}
+
+// chainCalls returns calls of builder chain methods setting fields of chain to values, e.g. "FirstName(a)".
+// Fields of chunked chain are set with section setters, e.g. "Section1(Person_Group_Section1{FirstName: a})".
+func chainCalls(chain []*StructField, values []string) []string {
+	calls := make([]string, 0, len(chain))
+	for i := 0; i < len(chain); i++ {
+		sf := chain[i]
+		if sf.StructFlags.Chunk == 0 {
+			calls = append(calls, fmt.Sprintf("%s(%s)", sf.setterName(), values[i]))
+			continue
+		}
+		end := i + sf.StructFlags.Chunk
+		if end > len(chain) {
+			end = len(chain)
+		}
+		group := &FieldGroup{Name: sf.Group, Fields: chain[i:end]}
+		items := make([]string, 0, len(group.Fields))
+		for j, gf := range group.Fields {
+			items = append(items, fmt.Sprintf("%s: %s", gf.exportName(), values[i+j]))
+		}
+		calls = append(calls, fmt.Sprintf("%s(%s%s{%s})", sf.styledSetterName(strings.Title(group.Name)),
+			group.structName(), sf.TypeArgs, strings.Join(items, ", ")))
+		i = end - 1
+	}
+	return calls
+}
+
+func (fg *FieldGroup) structName() string {
+	first := fg.Fields[0]
+	if first.StructFlags.Naming == "camel" {
+		return first.StructName + "Group" + strings.Title(fg.Name)
+	}
+	return first.StructName + "_Group_" + strings.Title(fg.Name)
+}
+
+func (fg *FieldGroup) GenerateSourceCodeForGroup() string {
+	bld := &strings.Builder{}
+	first := fg.Fields[0]
+	groupStructName := fg.structName()
 	bld.WriteString(fmt.Sprintf(`
-func (b %s) Build() *%s {
+type %s%s struct {
+`, groupStructName, first.TypeParams))
+	for _, sf := range fg.Fields {
+		bld.WriteString(fmt.Sprintf("    %s %s\n", sf.exportName(), sf.FieldTypeText))
+	}
+	bld.WriteString("}\n\n")
+
+	nextBuilderStructName := fg.Next.builderFieldStructType()
+	bld.WriteString(fmt.Sprintf(`
+func (b %s) %s(arg %s%s) %s {
+`, first.builderFieldStructType(), first.styledSetterName(strings.Title(fg.Name)), groupStructName, first.TypeArgs, nextBuilderStructName))
+	for _, sf := range fg.Fields {
+		bld.WriteString(fmt.Sprintf("    b.root.%s = arg.%s\n", sf.FieldName, sf.exportName()))
+	}
+	bld.WriteString(fmt.Sprintf(`    return %s{root: b.root}
+}
+
+`, nextBuilderStructName))
+	return bld.String()
+}
+
+func (sf *StructField) generateBuildFunction(bld *strings.Builder) {
+	builderStructName := sf.builderFieldStructType()
+	if len(sf.StructFlags.Derived) == 0 {
+		bld.WriteString(fmt.Sprintf(`
+func (b %s) %s() *%s {
     return b.root
 }
 
-`, builderStructName, sf.StructName,
+`, builderStructName, sf.StructFlags.BuildName, sf.structType(),
+		))
+		return
+	}
+
+	bld.WriteString(fmt.Sprintf(`
+func (b %s) %s() *%s {
+    v := b.root
+`, builderStructName, sf.StructFlags.BuildName, sf.structType()))
+	for _, derived := range sf.StructFlags.Derived {
+		switch {
+		case derived.Lazy != "":
+			bld.WriteString(fmt.Sprintf("    v.%s = v.%s()\n", derived.FieldName, derived.Lazy))
+		case derived.Default != "":
+			// value copied from prototype (see GenerateBuilderFrom) is kept
+			bld.WriteString(fmt.Sprintf("    if %s {\n        v.%s = %s\n    }\n", derived.zeroCheck("v", false),
+				derived.FieldName, derived.Default))
+		default:
+			bld.WriteString(fmt.Sprintf("    v.%s = %s\n", derived.FieldName, derived.Computed))
+		}
+	}
+	bld.WriteString(`    return v
+}
+
+`)
+}
+
+func (sf *StructField) generateResetFunction(bld *strings.Builder, first *StructField) {
+	builderStructName := sf.builderFieldStructType()
+	firstBuilderStructName := first.builderFieldStructType()
+	bld.WriteString(fmt.Sprintf(`
+func (b %s) Reset() %s {
+    return %s{root: &%s{}}
+}
+
+`, builderStructName, firstBuilderStructName,
+		firstBuilderStructName, sf.structType(),
 	))
 }
 
 func (sf *StructField) generateBuilderStruct(bld *strings.Builder) {
 	builderStructName := sf.builderFieldStructName()
 	bld.WriteString(fmt.Sprintf(`
-type %s struct {
+type %s%s struct {
     root *%s
 }
 
-`, builderStructName, sf.StructName))
+`, builderStructName, sf.TypeParams, sf.structType()))
 }
 
 func (sf *StructField) generateBuilderSetter(bld *strings.Builder, prev *StructField) {
-	var setterName string
-	if prev.Acronym {
-		setterName = strings.ToUpper(prev.FieldName)
-	} else {
-		setterName = strings.Title(prev.FieldName)
-	}
+	setterName := prev.setterName()
 
-	prevBuilderStructName := prev.builderFieldStructName()
-	builderStructName := sf.builderFieldStructName()
+	prevBuilderStructName := prev.builderFieldStructType()
+	builderStructName := sf.builderFieldStructType()
 	bld.WriteString(fmt.Sprintf(`
 func (b %s) %s(arg %s) %s {
     b.root.%s = arg
@@ -144,72 +766,1208 @@ func (b %s) %s(arg %s) %s {
 }
 
 func (sf *StructField) generateConstructor(bld *strings.Builder) {
-	builderStructName := sf.builderFieldStructName()
-	var funcName string
-	firstChar := rune(sf.StructName[0])
-	if unicode.IsLower(firstChar) || sf.StructFlags.Visibility == PackageLevelVisibility {
-		funcName = "new" + strings.Title(sf.StructName)
-	} else {
-		funcName = "New" + strings.Title(sf.StructName)
-	}
-	bld.WriteString(fmt.Sprintf(`
-func %sBuilder() %s {
+	builderStructName := sf.builderFieldStructType()
+	funcName := sf.constructorName()
+	bld.WriteString("\n" + sf.StructFlags.ConstructorDoc)
+	bld.WriteString(fmt.Sprintf(`func %sBuilder%s() %s {
 	return %s{root: &%s{}}
 }
 
 `,
-		funcName, builderStructName,
-		builderStructName, sf.StructName,
+		funcName, sf.TypeParams, builderStructName,
+		builderStructName, sf.structType(),
 	))
 }
 
+// GenerateDeprecatedShims generates deprecated type aliases and methods with names that builder chain would have
+// with default naming flags (-naming, -setter-style, -finalizer-name, -build-name and -builder-visibility), so
+// code written against previous names keeps compiling while it is migrated to the new names. Type aliases
+// are not generated for generic structures, because generic type aliases are not supported by older Go versions.
+// Chunked builder chains have no setters of individual fields, so no shims are generated for them.
+func GenerateDeprecatedShims(chain []*StructField) (code string, aliases int) {
+	if len(chain) == 0 || chain[0].StructFlags.Chunk > 0 {
+		return "", 0
+	}
+	legacyFlags := *chain[0].StructFlags
+	legacyFlags.Naming = "legacy"
+	legacyFlags.SetterStyle = "bare"
+	legacyFlags.FinalizerName = "GobFinalizer"
+	legacyFlags.BuildName = "Build"
+	legacyFlags.BuilderVisibility = ExportedVisibility
+	legacy := func(sf *StructField) *StructField {
+		copied := *sf
+		copied.StructFlags = &legacyFlags
+		return &copied
+	}
+	finalizer := chain[0].finalizer()
+	bld := &strings.Builder{}
+	for i, sf := range append(chain, finalizer) {
+		legacySF := legacy(sf)
+		if sf == finalizer {
+			legacySF.FieldName = legacyFlags.FinalizerName
+		}
+		legacyName := legacySF.builderFieldStructName()
+		if sf.TypeParams == "" && legacyName != sf.builderFieldStructName() {
+			bld.WriteString(fmt.Sprintf(`
+// Deprecated: use %[2]s instead.
+type %[1]s = %[2]s
+`, legacyName, sf.builderFieldStructName()))
+			aliases++
+		}
+		if sf == finalizer {
+			continue
+		}
+		next := finalizer
+		if i+1 < len(chain) {
+			next = chain[i+1]
+		}
+		if legacySetter := legacySF.setterName(); legacySetter != sf.setterName() {
+			bld.WriteString(fmt.Sprintf(`
+// Deprecated: use %[5]s instead.
+func (b %[1]s) %[2]s(arg %[3]s) %[4]s {
+	return b.%[5]s(arg)
+}
+`, sf.builderFieldStructType(), legacySetter, sf.FieldTypeText, next.builderFieldStructType(), sf.setterName()))
+		}
+	}
+	if buildName := chain[0].StructFlags.BuildName; buildName != legacyFlags.BuildName {
+		bld.WriteString(fmt.Sprintf(`
+// Deprecated: use %[3]s instead.
+func (b %[1]s) Build() *%[2]s {
+	return b.%[3]s()
+}
+`, finalizer.builderFieldStructType(), chain[0].structType(), buildName))
+	}
+	return bld.String(), aliases
+}
+
+// GenerateConstructorDoc generates doc comment of builder constructor summarizing construction contract:
+// required fields in order of builder chain, optional fields and fields populated by Build() function
+func GenerateConstructorDoc(chain []*StructField, optional []*StructField) string {
+	if len(chain) == 0 {
+		return ""
+	}
+	first := chain[0]
+	bld := &strings.Builder{}
+	bld.WriteString(fmt.Sprintf("// %sBuilder creates builder of %s. Required fields are set in the following order:\n",
+		first.constructorName(), first.StructName))
+	writeFields := func(fields []*StructField, method bool) {
+		for _, sf := range fields {
+			if method {
+				bld.WriteString(fmt.Sprintf("//   - %s(%s)", sf.setterName(), docTypeText(sf.FieldTypeText)))
+			} else {
+				bld.WriteString(fmt.Sprintf("//   - %s %s", sf.FieldName, docTypeText(sf.FieldTypeText)))
+			}
+			if len(sf.Annotations) > 0 {
+				bld.WriteString(" (" + oneLine(strings.Join(sf.Annotations, " ")) + ")")
+			}
+			bld.WriteString("\n")
+		}
+	}
+	if first.StructFlags.Chunk > 0 {
+		groups, _ := GroupStructFields(chain)
+		for _, group := range groups {
+			names := make([]string, 0, len(group.Fields))
+			for _, sf := range group.Fields {
+				names = append(names, sf.FieldName)
+			}
+			bld.WriteString(fmt.Sprintf("//   - %s(%s): %s\n", first.styledSetterName(strings.Title(group.Name)),
+				group.structName(), strings.Join(names, ", ")))
+		}
+	} else {
+		writeFields(chain, true)
+	}
+	if len(optional) > 0 {
+		bld.WriteString("//\n// Optional fields:\n")
+		writeFields(optional, false)
+	}
+	if derived := first.StructFlags.Derived; len(derived) > 0 {
+		bld.WriteString(fmt.Sprintf("//\n// Fields populated by %s():\n", first.StructFlags.BuildName))
+		writeFields(derived, false)
+	}
+	return bld.String()
+}
+
+// oneLine collapses multi-line text (e.g. inner struct type) into a single line
+func oneLine(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// docTypeText renders field type for doc comments: comments of inner struct fields are dropped and fields are
+// separated by semicolons, e.g. "struct { val V; other []K }"
+func docTypeText(typeText string) string {
+	expr, err := parser.ParseExpr(typeText)
+	if err != nil {
+		return oneLine(typeText)
+	}
+	printed := &strings.Builder{}
+	if err = printer.Fprint(printed, token.NewFileSet(), expr); err != nil {
+		return oneLine(typeText)
+	}
+	bld := &strings.Builder{}
+	for _, line := range strings.Split(printed.String(), "\n") {
+		line = oneLine(line)
+		if bld.Len() > 0 {
+			if text := bld.String(); strings.HasSuffix(text, "{") || line == "}" || strings.HasPrefix(line, "}") {
+				bld.WriteString(" ")
+			} else {
+				bld.WriteString("; ")
+			}
+		}
+		bld.WriteString(line)
+	}
+	return bld.String()
+}
+
+// GenerateConstructorOf generates constructor for generic struct that accepts the first field of
+// builder chain, so type parameters can be inferred by compiler (e.g. NewBoxOf(10) instead of
+// NewBoxBuilder[int]().Value(10)). Nothing is generated if not all type parameters can be inferred.
+func (sf *StructField) GenerateConstructorOf(next *StructField) string {
+	if sf.TypeParams == "" || sf.StructFlags.Chunk > 0 {
+		return ""
+	}
+	for _, typeArg := range strings.Split(strings.Trim(sf.TypeArgs, "[]"), ", ") {
+		if !regexp.MustCompile(`\b` + typeArg + `\b`).MatchString(sf.FieldTypeText) {
+			return ""
+		}
+	}
+	return fmt.Sprintf(`
+func %sOf%s(arg %s) %s {
+	return %sBuilder%s().%s(arg)
+}
+
+`,
+		sf.constructorName(), sf.TypeParams, sf.FieldTypeText, next.builderFieldStructType(),
+		sf.constructorName(), sf.TypeArgs, sf.setterName(),
+	)
+}
+
+// GenerateProvider generates provider function (e.g. ProvidePerson) that accepts all fields of builder chain
+// as arguments, so struct can be registered in dependency injection frameworks such as google/wire or uber/fx
+func GenerateProvider(fields []*StructField) string {
+	if len(fields) == 0 || !fields[0].StructFlags.Provide {
+		return ""
+	}
+	first := fields[0]
+	funcName := "Provide" + strings.Title(first.StructName)
+	if strings.HasPrefix(first.constructorName(), "new") {
+		funcName = "provide" + strings.Title(first.StructName)
+	}
+	params := make([]string, 0, len(fields))
+	values := make([]string, 0, len(fields))
+	for _, sf := range fields {
+		paramName := sf.paramName()
+		params = append(params, paramName+" "+sf.FieldTypeText)
+		values = append(values, paramName)
+	}
+	return fmt.Sprintf(`
+func %s%s(%s) *%s {
+	return %sBuilder%s().%s.%s()
+}
+
+`,
+		funcName, first.TypeParams, strings.Join(params, ", "), first.structType(),
+		first.constructorName(), first.TypeArgs, strings.Join(chainCalls(fields, values), "."), first.StructFlags.BuildName,
+	)
+}
+
+// GenerateWithDefaults generates constructor (e.g. NewPersonWithDefaults) for structure with +gob:default
+// annotations that pre-applies all default values. It starts builder chain of remaining required fields, or
+// returns structure itself if all its fields have default values or are optional.
+func GenerateWithDefaults(root *StructField, chain []*StructField) string {
+	if root.StructFlags.Visibility == NoVisibility {
+		return ""
+	}
+	values := make([]string, 0)
+	for _, sf := range root.StructFlags.Derived {
+		if sf.Default != "" {
+			values = append(values, fmt.Sprintf("%s: %s", sf.FieldName, sf.Default))
+		}
+	}
+	if len(values) == 0 {
+		return ""
+	}
+	literal := fmt.Sprintf("&%s{%s}", root.structType(), strings.Join(values, ", "))
+	if len(chain) == 0 {
+		return fmt.Sprintf(`
+func %sWithDefaults%s() *%s {
+	return %s
+}
+
+`, root.constructorName(), root.TypeParams, root.structType(), literal)
+	}
+	return fmt.Sprintf(`
+func %sWithDefaults%s() %s {
+	return %s{root: %s}
+}
+
+`, root.constructorName(), root.TypeParams, chain[0].builderFieldStructType(), chain[0].builderFieldStructType(),
+		literal)
+}
+
+// GenerateBuilderFrom generates function (e.g. PersonBuilderFrom) creating builder with all fields copied from
+// existing value, so variations of prototype can be built without repeating the whole builder chain. Builder is
+// positioned at finalizer, fields are changed on built structure the same way as optional fields. Function is
+// not generated for structures holding locks, which must not be copied.
+func GenerateBuilderFrom(chain []*StructField, fields []*StructField) string {
+	if len(chain) == 0 || !chain[0].StructFlags.BuilderFrom {
+		return ""
+	}
+	for _, sf := range fields {
+		if sf.NoCopy {
+			return ""
+		}
+	}
+	first := chain[0]
+	funcName := strings.Title(first.StructName) + "BuilderFrom"
+	if strings.HasPrefix(first.constructorName(), "new") {
+		funcName = lowerFirst(first.StructName) + "BuilderFrom"
+	}
+	finalizerType := first.finalizer().builderFieldStructType()
+	return fmt.Sprintf(`
+func %s%s(p %s) %s {
+	return %s{root: &p}
+}
+
+`, funcName, first.TypeParams, first.structType(), finalizerType, finalizerType)
+}
+
+func (sf *StructField) constructorName() string {
+	firstChar := rune(sf.StructName[0])
+	if unicode.IsLower(firstChar) || sf.StructFlags.Visibility == PackageLevelVisibility {
+		return "new" + strings.Title(sf.StructName)
+	}
+	return "New" + strings.Title(sf.StructName)
+}
+
 func (sf *StructField) builderFieldStructName() string {
-	var title string
+	if sf.StructFlags.Naming == "camel" {
+		if sf.StructFlags.BuilderVisibility == PackageLevelVisibility {
+			return lowerFirst(sf.StructName) + "Builder" + sf.exportName()
+		}
+		return sf.StructName + "Builder" + sf.exportName()
+	}
+	if sf.StructFlags.BuilderVisibility == PackageLevelVisibility {
+		return lowerFirst(sf.StructName) + "_builder_" + lowerFirst(sf.exportName())
+	}
+	return sf.StructName + "_Builder_" + sf.exportName()
+}
+
+func (sf *StructField) builderFieldStructType() string {
+	return sf.builderFieldStructName() + sf.TypeArgs
+}
+
+func (sf *StructField) structType() string {
+	return sf.StructName + sf.TypeArgs
+}
+
+// paramName returns field name suitable for function parameter (lower-cased and not clashing with keywords)
+func (sf *StructField) paramName() string {
+	name := lowerFirst(sf.FieldName)
 	if sf.Acronym {
-		title = strings.ToUpper(sf.FieldName)
-	} else {
-		title = strings.Title(sf.FieldName)
+		name = strings.ToLower(sf.FieldName)
+	}
+	if token.IsKeyword(name) {
+		name += "_"
+	}
+	return name
+}
+
+// setterName returns name of builder chain method setting the field
+func (sf *StructField) setterName() string {
+	return sf.styledSetterName(sf.exportName())
+}
+
+func (sf *StructField) styledSetterName(name string) string {
+	switch sf.StructFlags.SetterStyle {
+	case "with":
+		return "With" + name
+	case "set":
+		return "Set" + name
+	}
+	return name
+}
+
+func (sf *StructField) exportName() string {
+	if sf.Acronym {
+		return strings.ToUpper(sf.FieldName)
+	}
+	return strings.Title(sf.FieldName)
+}
+
+func (sf *StructField) finalizer() *StructField {
+	return &StructField{
+		StructFlags:   sf.StructFlags,
+		StructName:    sf.StructName,
+		FieldName:     sf.StructFlags.FinalizerName,
+		FieldTypeText: "AAAAAA",
+		Acronym:       false,
+		TypeParams:    sf.TypeParams,
+		TypeArgs:      sf.TypeArgs,
+	}
+}
+
+// lowerFirst lower-cases the first character of name, or the whole name if it is all upper-cased (acronym)
+func lowerFirst(name string) string {
+	if strings.ToUpper(name) == name {
+		return strings.ToLower(name)
 	}
-	return sf.StructName + "_Builder_" + title
+	return strings.ToLower(name[:1]) + name[1:]
 }
 
-func NewStructParser(fileSet *token.FileSet, fileContent []byte) StructParser {
+// NewStructParser creates parser of structures of input file parsed with file set of package cache, packages
+// imported by input file are loaded through the cache, so every package is parsed and type-checked once per run
+// regardless of how many structures (and input files) reference it
+func NewStructParser(packages *PackageCache, astFile *ast.File, fileContent []byte, anyStyle string) StructParser {
 	return StructParser{
-		fileSet:                   fileSet,
+		fileSet:                   packages.FileSet(),
 		fileContent:               fileContent,
-		whitespaceRegexp:          regexp.MustCompile(`\s+`),
+		comments:                  astFile.Comments,
+		anyStyle:                  anyStyle,
+		packages:                  packages,
+		typeDocs:                  typeDocs(astFile),
 		constructorExportedRegexp: regexp.MustCompile(`\b+gob:Constructor\b`),
 		constructorPackageRegexp:  regexp.MustCompile(`\b+gob:constructor\b`),
 		constructorNoRegexp:       regexp.MustCompile(`\b+gob:_\b`),
 		flagOptionalRegexp:        regexp.MustCompile(`\b+gob:_\b`),
+		flagRequiredRegexp:        regexp.MustCompile(`\b+gob:required\b`),
+		flagSecretRegexp:          regexp.MustCompile(`\b+gob:secret(?:=(\w*))?\b`),
 		flagGetterRegexp:          regexp.MustCompile(`\b+gob:getter\b`),
 		flagAcronymRegex:          regexp.MustCompile(`\b+gob:acronym\b`),
+		flagGroupRegexp:           regexp.MustCompile(`\b+gob:group=(\w+)\b`),
+		flagProvideRegexp:         regexp.MustCompile(`\b+gob:provide\b`),
+		flagFromRegexp:            regexp.MustCompile(`\b+gob:from\b`),
+		flagSliceRegexp:           regexp.MustCompile(`\b+gob:slice\b`),
+		flagCollectorRegexp:       regexp.MustCompile(`\b+gob:collector\b`),
+		flagMergeRegexp:           regexp.MustCompile(`\b+gob:merge\b`),
+		flagPatchRegexp:           regexp.MustCompile(`\b+gob:patch\b`),
+		flagImmutableRegexp:       regexp.MustCompile(`\b+gob:immutable\b`),
+		flagViewRegexp:            regexp.MustCompile(`\b+gob:view=(\w+(?:,\w+)*)`),
+		flagLazyRegexp:            regexp.MustCompile(`\b+gob:lazy=(\w+)\b`),
+		flagComputedRegexp:        regexp.MustCompile(`(?m)\b+gob:computed=(.+)$`),
+		flagDefaultRegexp:         regexp.MustCompile(`(?m)\b+gob:default=(.+)$`),
+		flagValidateRegexp:        regexp.MustCompile(`(?m)\b+gob:validate=(.+)$`),
+		flagEnvRegexp:             regexp.MustCompile(`\b+gob:env=(\w+)\b`),
+		flagMapRegexp:             regexp.MustCompile(`\b+gob:map\b`),
+		flagFormRegexp:            regexp.MustCompile(`\b+gob:form\b`),
+		flagJSONRegexp:            regexp.MustCompile(`\b+gob:json\b`),
+		flagSlogRegexp:            regexp.MustCompile(`\b+gob:slog\b`),
+		flagProtoRegexp:           regexp.MustCompile(`\b+gob:proto=(\w+\.\w+)\b`),
+		flagScanRegexp:            regexp.MustCompile(`\b+gob:scan\b`),
+		flagColumnsRegexp:         regexp.MustCompile(`\b+gob:columns\b`),
+		flagCSVRegexp:             regexp.MustCompile(`\b+gob:csv\b`),
+		flagYAMLRegexp:            regexp.MustCompile(`\b+gob:yaml\b`),
+		flagTOMLRegexp:            regexp.MustCompile(`\b+gob:toml\b`),
+		flagBindRegexp:            regexp.MustCompile(`\b+gob:bind\b`),
+		flagSkipRegexp:            regexp.MustCompile(`\b+gob:skip\b`),
+		flagChunkRegexp:           regexp.MustCompile(`\b+gob:chunk=(\d+)\b`),
+		annotationRegexp:          regexp.MustCompile(`\+gob:`),
+		annotationListRegexp:      regexp.MustCompile(`\+gob:(?:(?:computed|default|validate)=.*\S|\S+)`),
 	}
 }
 
+// fieldTypeText renders field type with go/printer rather than copying it from the source, because
+// multi-line types (such as inner anonymous structs) cannot simply be joined into a single line.
+// Comments inside of inner anonymous structs are preserved, except of comment on the opening line
+// which belongs to the field itself. Empty interfaces are spelled according to selected style.
 func (sp *StructParser) fieldTypeText(field *ast.Field) string {
-	begin := sp.fileSet.Position(field.Type.Pos()).Offset
-	end := sp.fileSet.Position(field.Type.End()).Offset
-	return sp.whitespaceRegexp.ReplaceAllString(string(sp.fileContent[begin:end]), " ")
+	bld := &strings.Builder{}
+	field.Type = sp.rewriteAny(field.Type)
+	var node any = field.Type
+	if comments := sp.innerComments(field.Type); len(comments) > 0 {
+		node = &printer.CommentedNode{Node: field.Type, Comments: comments}
+	}
+	if err := printer.Fprint(bld, sp.fileSet, node); err != nil {
+		panic(err)
+	}
+	return bld.String()
+}
+
+// rewriteAny replaces "interface{}" with "any" or vice versa (depending on style) in type expression
+func (sp *StructParser) rewriteAny(expr ast.Expr) ast.Expr {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		// unresolved "any" identifier refers to predeclared type rather than to a type declared in file
+		if sp.anyStyle == "interface{}" && t.Name == "any" && t.Obj == nil {
+			return &ast.InterfaceType{
+				Interface: t.Pos(),
+				Methods:   &ast.FieldList{Opening: t.Pos(), Closing: t.Pos()},
+			}
+		}
+	case *ast.InterfaceType:
+		if sp.anyStyle == "any" && (t.Methods == nil || len(t.Methods.List) == 0) {
+			return &ast.Ident{Name: "any", NamePos: t.Pos()}
+		}
+		sp.rewriteAnyFields(t.Methods)
+	case *ast.StarExpr:
+		t.X = sp.rewriteAny(t.X)
+	case *ast.ParenExpr:
+		t.X = sp.rewriteAny(t.X)
+	case *ast.UnaryExpr:
+		t.X = sp.rewriteAny(t.X)
+	case *ast.BinaryExpr:
+		t.X = sp.rewriteAny(t.X)
+		t.Y = sp.rewriteAny(t.Y)
+	case *ast.ArrayType:
+		t.Elt = sp.rewriteAny(t.Elt)
+	case *ast.Ellipsis:
+		t.Elt = sp.rewriteAny(t.Elt)
+	case *ast.MapType:
+		t.Key = sp.rewriteAny(t.Key)
+		t.Value = sp.rewriteAny(t.Value)
+	case *ast.ChanType:
+		t.Value = sp.rewriteAny(t.Value)
+	case *ast.IndexExpr:
+		t.Index = sp.rewriteAny(t.Index)
+	case *ast.IndexListExpr:
+		for i := range t.Indices {
+			t.Indices[i] = sp.rewriteAny(t.Indices[i])
+		}
+	case *ast.FuncType:
+		sp.rewriteAnyFields(t.TypeParams)
+		sp.rewriteAnyFields(t.Params)
+		sp.rewriteAnyFields(t.Results)
+	case *ast.StructType:
+		sp.rewriteAnyFields(t.Fields)
+	}
+	return expr
+}
+
+func (sp *StructParser) rewriteAnyFields(fields *ast.FieldList) {
+	if fields == nil {
+		return
+	}
+	for _, field := range fields.List {
+		field.Type = sp.rewriteAny(field.Type)
+	}
+}
+
+func (sp *StructParser) innerComments(node ast.Node) []*ast.CommentGroup {
+	line := sp.fileSet.Position(node.Pos()).Line
+	comments := make([]*ast.CommentGroup, 0)
+	for _, cg := range sp.comments {
+		if cg.Pos() > node.Pos() && cg.End() < node.End() && sp.fileSet.Position(cg.Pos()).Line != line {
+			comments = append(comments, cg)
+		}
+	}
+	return comments
+}
+
+// typeParams returns type parameters declaration (e.g. "[K comparable, V ~int | ~string]") and
+// type arguments (e.g. "[K, V]") of generic struct, or empty strings for non-generic struct
+func (sp *StructParser) typeParams(ts *ast.TypeSpec) (params string, args string) {
+	if ts.TypeParams == nil || len(ts.TypeParams.List) == 0 {
+		return "", ""
+	}
+	paramList := make([]string, 0)
+	argList := make([]string, 0)
+	for _, field := range ts.TypeParams.List {
+		names := make([]string, 0)
+		for _, name := range field.Names {
+			names = append(names, name.Name)
+		}
+		paramList = append(paramList, strings.Join(names, ", ")+" "+sp.fieldTypeText(field))
+		argList = append(argList, names...)
+	}
+	return "[" + strings.Join(paramList, ", ") + "]", "[" + strings.Join(argList, ", ") + "]"
+}
+
+// foreignStructFields type-checks package referenced by selector expression (e.g. pkg.Order) and returns
+// exported fields of its struct type. Packages of field types are collected into foreignImports.
+func (sp *StructParser) foreignStructFields(
+	astFile *ast.File,
+	srcDir string,
+	sel *ast.SelectorExpr,
+	foreignImports map[string]string,
+) ([]*StructField, error) {
+	pkgIdent, ok := sel.X.(*ast.Ident)
+	if !ok {
+		return nil, fmt.Errorf("unsupported type expression")
+	}
+	srcDir, err := filepath.Abs(srcDir)
+	if err != nil {
+		return nil, err
+	}
+	var pkg *types.Package
+	var importErr error
+	for _, i := range astFile.Imports {
+		if i.Name != nil && i.Name.Name != pkgIdent.Name {
+			continue
+		}
+		path, _ := strconv.Unquote(i.Path.Value)
+		candidate, err := sp.importPackage(path, srcDir)
+		if err != nil {
+			importErr = err
+			continue
+		}
+		if i.Name != nil || candidate.Name() == pkgIdent.Name {
+			pkg = candidate
+			break
+		}
+	}
+	if pkg == nil {
+		if importErr != nil {
+			return nil, importErr
+		}
+		return nil, fmt.Errorf("package %s is not imported", pkgIdent.Name)
+	}
+	obj := pkg.Scope().Lookup(sel.Sel.Name)
+	if obj == nil {
+		return nil, fmt.Errorf("type %s.%s is not found", pkgIdent.Name, sel.Sel.Name)
+	}
+	st, ok := obj.Type().Underlying().(*types.Struct)
+	if !ok {
+		return nil, fmt.Errorf("type %s.%s is not a struct", pkgIdent.Name, sel.Sel.Name)
+	}
+	localNames := make(map[string]string)
+	for _, i := range astFile.Imports {
+		path, _ := strconv.Unquote(i.Path.Value)
+		if i.Name == nil {
+			localNames[path] = ""
+		} else {
+			localNames[path] = i.Name.Name
+		}
+	}
+	qualifier := func(p *types.Package) string {
+		if name, ok := localNames[p.Path()]; ok && name != "_" && name != "." {
+			if name == "" {
+				return p.Name()
+			}
+			return name
+		}
+		foreignImports[p.Path()] = p.Name()
+		return p.Name()
+	}
+	fields := make([]*StructField, 0)
+	for i := 0; i < st.NumFields(); i++ {
+		field := st.Field(i)
+		if !field.Exported() {
+			continue
+		}
+		fields = append(fields, &StructField{
+			FieldName:     field.Name(),
+			FieldTypeText: types.TypeString(field.Type(), qualifier),
+			Zero:          typeZero(field.Type()),
+		})
+	}
+	return fields, nil
+}
+
+// importPackage imports package (from overlay if it has overlaid files) through package cache
+func (sp *StructParser) importPackage(path string, srcDir string) (*types.Package, error) {
+	return sp.packages.Import(path, srcDir)
+}
+
+// syncLockTypes are types of sync and sync/atomic packages that must not be copied after first use
+var syncLockTypes = map[string]map[string]bool{
+	"sync": {
+		"Mutex": true, "RWMutex": true, "WaitGroup": true, "Once": true, "Cond": true, "Map": true, "Pool": true,
+	},
+	"sync/atomic": {
+		"Bool": true, "Int32": true, "Int64": true, "Uint32": true, "Uint64": true, "Uintptr": true, "Pointer": true,
+	},
+}
+
+// lockTypes returns names of types declared in file that must not be copied: types with Lock method
+// (such as noCopy guards recognized by "go vet") and structs holding such types or sync locks by value
+func (sp *StructParser) lockTypes(astFile *ast.File) map[string]bool {
+	result := make(map[string]bool)
+	for _, decl := range astFile.Decls {
+		if fd, ok := decl.(*ast.FuncDecl); ok && fd.Recv != nil && fd.Name.Name == "Lock" {
+			recv := fd.Recv.List[0].Type
+			if star, ok := recv.(*ast.StarExpr); ok {
+				recv = star.X
+			}
+			if ident, ok := recv.(*ast.Ident); ok {
+				result[ident.Name] = true
+			}
+		}
+	}
+	specs := make([]*ast.TypeSpec, 0)
+	ast.Inspect(astFile, func(n ast.Node) bool {
+		if ts, ok := n.(*ast.TypeSpec); ok {
+			specs = append(specs, ts)
+		}
+		return true
+	})
+	// repeat until no more types are found, because structs can hold other structs declared below them
+	for changed := true; changed; {
+		changed = false
+		for _, ts := range specs {
+			if !result[ts.Name.Name] && sp.isLockType(astFile, ts.Type, result) {
+				result[ts.Name.Name] = true
+				changed = true
+			}
+		}
+	}
+	return result
+}
+
+// isLockType reports whether value of type must not be copied
+func (sp *StructParser) isLockType(astFile *ast.File, expr ast.Expr, lockTypes map[string]bool) bool {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return lockTypes[t.Name]
+	case *ast.ParenExpr:
+		return sp.isLockType(astFile, t.X, lockTypes)
+	case *ast.ArrayType:
+		return t.Len != nil && sp.isLockType(astFile, t.Elt, lockTypes)
+	case *ast.IndexExpr:
+		return sp.isLockType(astFile, t.X, lockTypes)
+	case *ast.StructType:
+		for _, field := range t.Fields.List {
+			if sp.isLockType(astFile, field.Type, lockTypes) {
+				return true
+			}
+		}
+	case *ast.SelectorExpr:
+		pkg, ok := t.X.(*ast.Ident)
+		if !ok {
+			return false
+		}
+		for _, i := range astFile.Imports {
+			path, _ := strconv.Unquote(i.Path.Value)
+			name := filepath.Base(path)
+			if i.Name != nil {
+				name = i.Name.Name
+			}
+			if name == pkg.Name && syncLockTypes[path][t.Sel.Name] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// predeclaredZeros are zero values of predeclared types
+var predeclaredZeros = map[string]string{
+	"bool": "false", "string": `""`, "error": "nil", "any": "nil",
+	"int": "0", "int8": "0", "int16": "0", "int32": "0", "int64": "0", "rune": "0",
+	"uint": "0", "uint8": "0", "uint16": "0", "uint32": "0", "uint64": "0", "uintptr": "0", "byte": "0",
+	"float32": "0", "float64": "0", "complex64": "0", "complex128": "0",
+}
+
+// fieldZero returns zero value of type that values of type are compared with, or empty string if type is not
+// known to be comparable. Types declared in file are resolved to their underlying types, while types declared
+// elsewhere (in other files or packages) and type parameters are not.
+func (sp *StructParser) fieldZero(expr ast.Expr) string {
+	// invalid cyclic declarations (e.g. "type A B; type B A") are resolved at most once per type
+	resolved := make(map[*ast.TypeSpec]bool)
+	for {
+		if paren, ok := expr.(*ast.ParenExpr); ok {
+			expr = paren.X
+			continue
+		}
+		ident, ok := expr.(*ast.Ident)
+		if !ok || ident.Obj == nil {
+			break
+		}
+		ts, ok := ident.Obj.Decl.(*ast.TypeSpec)
+		if !ok || ts.TypeParams != nil || resolved[ts] {
+			return ""
+		}
+		resolved[ts] = true
+		expr = ts.Type
+	}
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return predeclaredZeros[t.Name]
+	case *ast.StarExpr, *ast.MapType, *ast.FuncType, *ast.ChanType, *ast.InterfaceType:
+		return "nil"
+	case *ast.ArrayType:
+		if t.Len == nil {
+			return "nil"
+		}
+	}
+	return ""
+}
+
+// typeZero returns zero value of type of another package that values of type are compared with, or empty
+// string if type is not known to be comparable (see StructParser.fieldZero)
+func typeZero(typ types.Type) string {
+	if _, ok := typ.(*types.TypeParam); ok {
+		return ""
+	}
+	switch t := typ.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case t.Info()&types.IsBoolean != 0:
+			return "false"
+		case t.Info()&types.IsString != 0:
+			return `""`
+		case t.Info()&types.IsNumeric != 0:
+			return "0"
+		}
+	case *types.Pointer, *types.Slice, *types.Map, *types.Signature, *types.Chan, *types.Interface:
+		return "nil"
+	}
+	return ""
+}
+
+// zeroCheck returns condition checking whether field of value (e.g. "v") has zero value or, if nonZero is set,
+// whether it has not. Fields of types not known to be comparable are checked with reflection.
+func (sf *StructField) zeroCheck(value string, nonZero bool) string {
+	field := value + "." + sf.FieldName
+	switch {
+	case sf.Zero == "false" && nonZero:
+		return field
+	case sf.Zero == "false":
+		return "!" + field
+	case sf.Zero != "" && nonZero:
+		return field + " != " + sf.Zero
+	case sf.Zero != "":
+		return field + " == " + sf.Zero
+	case nonZero:
+		return "!reflect.ValueOf(&" + field + ").Elem().IsZero()"
+	}
+	return "reflect.ValueOf(&" + field + ").Elem().IsZero()"
+}
+
+// interfaceTypes returns names of non-generic interface types declared in file
+func (sp *StructParser) interfaceTypes(astFile *ast.File) map[string]bool {
+	result := make(map[string]bool)
+	ast.Inspect(astFile, func(n ast.Node) bool {
+		if ts, ok := n.(*ast.TypeSpec); ok && ts.TypeParams == nil {
+			if _, ok := ts.Type.(*ast.InterfaceType); ok {
+				result[ts.Name.Name] = true
+			}
+		}
+		return true
+	})
+	return result
+}
+
+// fieldComment returns comment with field annotations. Annotations of fields with multi-line types (such as
+// inner anonymous structs) can be placed on the opening line of the type, e.g. "Server struct { //+gob:getter".
+// Annotations can be placed in doc comment above field as well.
+func (sp *StructParser) fieldComment(field *ast.Field) string {
+	text := field.Comment.Text() + field.Doc.Text()
+	line := sp.fileSet.Position(field.Type.Pos()).Line
+	if sp.fileSet.Position(field.Type.End()).Line == line {
+		return text
+	}
+	for _, cg := range sp.comments {
+		if cg.Pos() > field.Type.Pos() && cg.End() < field.Type.End() && sp.fileSet.Position(cg.Pos()).Line == line {
+			text += cg.Text()
+		}
+	}
+	return text
+}
+
+// nameComment returns comment with annotations of name of field. Annotations can target individual names of
+// multi-name field declaration, e.g. "firstName, lastName string //+gob:getter(firstName)", such annotations
+// are removed from comments of other names and their targets are stripped from comments of targeted names.
+func (sp *StructParser) nameComment(field *ast.Field, name string) string {
+	return sp.annotationListRegexp.ReplaceAllStringFunc(sp.fieldComment(field), func(annotation string) string {
+		base, targets, targeted := annotationTargets(annotation)
+		if !targeted {
+			return annotation
+		}
+		for _, target := range targets {
+			if target == name {
+				return base
+			}
+		}
+		return ""
+	})
+}
+
+// annotationTargets splits annotation targeting individual names of field (e.g. "+gob:getter(firstName)") into
+// annotation itself and target names. Expressions of computed fields, default values and validations are never
+// split, because they may end with function call.
+func annotationTargets(annotation string) (base string, targets []string, targeted bool) {
+	if strings.HasPrefix(annotation, "+gob:computed=") || strings.HasPrefix(annotation, "+gob:default=") ||
+		strings.HasPrefix(annotation, "+gob:validate=") || !strings.HasSuffix(annotation, ")") {
+		return annotation, nil, false
+	}
+	open := strings.Index(annotation, "(")
+	if open < 0 {
+		return annotation, nil, false
+	}
+	return annotation[:open], strings.Split(annotation[open+1:len(annotation)-1], ","), true
+}
+
+func (sp *StructParser) fieldOptional(field *ast.Field, name string) bool {
+	return sp.flagOptionalRegexp.MatchString(sp.nameComment(field, name))
+}
+
+func (sp *StructParser) fieldRequired(field *ast.Field, name string) bool {
+	return sp.flagRequiredRegexp.MatchString(sp.nameComment(field, name))
+}
+
+func (sp *StructParser) fieldAnnotations(field *ast.Field, name string) []string {
+	return sp.annotationListRegexp.FindAllString(sp.nameComment(field, name), -1)
+}
+
+// allFieldAnnotations returns all +gob: annotations of field including annotations targeting its individual names
+func (sp *StructParser) allFieldAnnotations(field *ast.Field) []string {
+	return sp.annotationListRegexp.FindAllString(sp.fieldComment(field), -1)
+}
+
+// structAnnotations returns all +gob: annotations of structure
+func (sp *StructParser) structAnnotations(ts *ast.TypeSpec) []string {
+	return sp.annotationListRegexp.FindAllString(sp.structComment(ts), -1)
+}
+
+// annotationPos returns position of annotation on the line starting at position, or the position itself if
+// annotation is not found there (e.g. annotation of field with multi-line type)
+func (sp *StructParser) annotationPos(begin token.Pos, annotation string) token.Pos {
+	if index := annotationIndex(sp.lineText(begin), annotation); index >= 0 {
+		return begin + token.Pos(index)
+	}
+	return begin
+}
+
+// fieldAnnotationPos returns position of field annotation in doc comment of field or on the line of field
+func (sp *StructParser) fieldAnnotationPos(field *ast.Field, annotation string) token.Pos {
+	return sp.docAnnotationPos(field.Doc, field.Pos(), annotation)
+}
+
+// structAnnotationPos returns position of structure annotation in doc comment of type or on the line where
+// type of structure begins
+func (sp *StructParser) structAnnotationPos(ts *ast.TypeSpec, annotation string) token.Pos {
+	return sp.docAnnotationPos(sp.typeDocs[ts], ts.Type.Pos(), annotation)
+}
+
+func (sp *StructParser) docAnnotationPos(doc *ast.CommentGroup, begin token.Pos, annotation string) token.Pos {
+	if doc != nil {
+		for _, c := range doc.List {
+			if index := annotationIndex(c.Text, annotation); index >= 0 {
+				return c.Pos() + token.Pos(index)
+			}
+		}
+	}
+	return sp.annotationPos(begin, annotation)
+}
+
+// annotationIndex returns index of annotation in text or -1 if text doesn't contain it. Annotation must not be
+// a prefix of longer annotation (e.g. "+gob:get" of "+gob:getter").
+func annotationIndex(text string, annotation string) int {
+	for offset := 0; ; {
+		index := strings.Index(text[offset:], annotation)
+		if index < 0 {
+			return -1
+		}
+		end := offset + index + len(annotation)
+		if end == len(text) || unicode.IsSpace(rune(text[end])) {
+			return offset + index
+		}
+		offset = end
+	}
+}
+
+// fieldAnnotationNames and structAnnotationNames are names of known annotations of fields and structures,
+// without "+gob:" prefix and "=<value>" suffix
+var (
+	fieldAnnotationNames = map[string]bool{
+		"_": true, "required": true, "secret": true, "getter": true, "acronym": true, "group": true,
+		"lazy": true, "computed": true, "default": true, "env": true, "validate": true,
+	}
+	structAnnotationNames = map[string]bool{
+		"Constructor": true, "constructor": true, "_": true, "skip": true, "provide": true, "map": true,
+		"form": true, "json": true, "yaml": true, "toml": true, "bind": true, "slog": true, "scan": true,
+		"columns": true, "csv": true, "proto": true, "chunk": true, "from": true,
+		"merge": true, "patch": true, "immutable": true, "view": true, "slice": true,
+		"collector": true,
+	}
+)
+
+// annotationSuggestion returns hint naming known annotation that unknown annotation is likely misspelling of,
+// e.g. ", did you mean +gob:getter?", or empty string
+func annotationSuggestion(annotation string, known map[string]bool) string {
+	base, _, _ := annotationTargets(annotation)
+	name, value, hasValue := strings.Cut(strings.TrimPrefix(base, "+gob:"), "=")
+	closest := closestName(name, known)
+	if closest == "" {
+		return ""
+	}
+	if hasValue {
+		closest += "=" + value
+	}
+	return ", did you mean +gob:" + closest + strings.TrimPrefix(annotation, base) + "?"
+}
+
+// closestName returns known name that differs from name by 1 or 2 edits, or empty string if there is none
+func closestName(name string, known map[string]bool) string {
+	best, bestDistance := "", 3
+	for candidate := range known {
+		distance := editDistance(name, candidate)
+		// short names are within 2 edits of almost anything
+		if distance >= len(candidate) {
+			continue
+		}
+		if distance < bestDistance || (distance == bestDistance && candidate < best) {
+			best, bestDistance = candidate, distance
+		}
+	}
+	return best
+}
+
+// editDistance returns Levenshtein distance between strings
+func editDistance(a string, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = prev[j-1] + cost
+			if prev[j]+1 < curr[j] {
+				curr[j] = prev[j] + 1
+			}
+			if curr[j-1]+1 < curr[j] {
+				curr[j] = curr[j-1] + 1
+			}
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// conflictingFieldAnnotations are pairs of field annotations contradicting each other, e.g. field cannot be
+// both required and optional, or populated by builder chain and by Build() function
+var conflictingFieldAnnotations = [][2]string{
+	{"required", "_"},
+	{"required", "lazy"},
+	{"required", "computed"},
+	{"lazy", "computed"},
+	{"default", "required"},
+	{"default", "_"},
+	{"default", "lazy"},
+	{"default", "computed"},
+	{"group", "_"},
+	{"group", "lazy"},
+	{"group", "computed"},
+	{"group", "default"},
+}
+
+// annotationConflict returns the first pair of annotations contradicting each other, annotation repeated with
+// different values (e.g. "+gob:group=a +gob:group=b") contradicts itself, except for validations that can be
+// repeated
+func annotationConflict(annotations []string) (first string, second string, found bool) {
+	byName := make(map[string]string)
+	for _, annotation := range annotations {
+		name := annotationName(annotation)
+		if previous, ok := byName[name]; ok && previous != annotation && name != "validate" {
+			return previous, annotation, true
+		}
+		byName[name] = annotation
+		for _, pair := range conflictingFieldAnnotations {
+			other := ""
+			switch name {
+			case pair[0]:
+				other = pair[1]
+			case pair[1]:
+				other = pair[0]
+			default:
+				continue
+			}
+			if previous, ok := byName[other]; ok {
+				return previous, annotation, true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// annotationName returns name of annotation without "+gob:" prefix, "=<value>" suffix and target names
+func annotationName(annotation string) string {
+	base, _, _ := annotationTargets(annotation)
+	name, _, _ := strings.Cut(strings.TrimPrefix(base, "+gob:"), "=")
+	return name
+}
+
+// unknownAnnotations returns annotations which names are not among known names
+func unknownAnnotations(annotations []string, known map[string]bool) []string {
+	unknown := make([]string, 0)
+	for _, annotation := range annotations {
+		if !known[annotationName(annotation)] {
+			unknown = append(unknown, annotation)
+		}
+	}
+	return unknown
+}
+
+// fieldSecret returns whether field is secret and whether its getters are suppressed with +gob:secret=nogetter
+func (sp *StructParser) fieldSecret(field *ast.Field, name string) (secret bool, noGetter bool, err error) {
+	match := sp.flagSecretRegexp.FindStringSubmatch(sp.nameComment(field, name))
+	if match == nil {
+		return false, false, nil
+	}
+	switch match[1] {
+	case "":
+		return true, false, nil
+	case "nogetter":
+		return true, true, nil
+	}
+	return false, false, fmt.Errorf("+gob:secret annotation must have no value or \"nogetter\" value, got %q",
+		match[1])
+}
+
+func (sp *StructParser) fieldGetter(field *ast.Field, name string) bool {
+	return sp.flagGetterRegexp.MatchString(sp.nameComment(field, name))
+}
+
+func (sp *StructParser) fieldAcronym(field *ast.Field, name string) bool {
+	return sp.flagAcronymRegex.MatchString(sp.nameComment(field, name))
+}
+
+func (sp *StructParser) fieldGroup(field *ast.Field, name string) string {
+	match := sp.flagGroupRegexp.FindStringSubmatch(sp.nameComment(field, name))
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
+
+func (sp *StructParser) fieldLazy(field *ast.Field, name string) string {
+	match := sp.flagLazyRegexp.FindStringSubmatch(sp.nameComment(field, name))
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
+
+func (sp *StructParser) fieldTag(field *ast.Field) string {
+	if field.Tag == nil {
+		return ""
+	}
+	tag, _ := strconv.Unquote(field.Tag.Value)
+	return tag
+}
+
+func (sp *StructParser) fieldEnv(field *ast.Field, name string) string {
+	match := sp.flagEnvRegexp.FindStringSubmatch(sp.nameComment(field, name))
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
+
+// fieldComputed returns expression of computed field. Expression takes the rest of the comment line,
+// so it must be the last annotation in the comment.
+func (sp *StructParser) fieldComputed(field *ast.Field, name string) string {
+	match := sp.flagComputedRegexp.FindStringSubmatch(strings.TrimSpace(sp.nameComment(field, name)))
+	if match == nil {
+		return ""
+	}
+	return strings.TrimSpace(match[1])
 }
 
-func (sp *StructParser) fieldOptional(field *ast.Field) bool {
-	return sp.flagOptionalRegexp.MatchString(field.Comment.Text())
+// fieldDefault returns default value of field. Like expression of computed field, it takes the rest of the
+// comment line.
+func (sp *StructParser) fieldDefault(field *ast.Field, name string) string {
+	match := sp.flagDefaultRegexp.FindStringSubmatch(strings.TrimSpace(sp.nameComment(field, name)))
+	if match == nil {
+		return ""
+	}
+	return strings.TrimSpace(match[1])
 }
 
-func (sp *StructParser) fieldGetter(field *ast.Field) bool {
-	return sp.flagGetterRegexp.MatchString(field.Comment.Text())
+// fieldNames returns names of field, embedded field is named after its type without package and pointer,
+// e.g. "Base" for "*config.Base"
+func fieldNames(field *ast.Field) []*ast.Ident {
+	if len(field.Names) > 0 {
+		return field.Names
+	}
+	expr := field.Type
+	for {
+		switch t := expr.(type) {
+		case *ast.StarExpr:
+			expr = t.X
+		case *ast.SelectorExpr:
+			expr = t.Sel
+		case *ast.IndexExpr:
+			expr = t.X
+		case *ast.IndexListExpr:
+			expr = t.X
+		case *ast.Ident:
+			return []*ast.Ident{{NamePos: field.Type.Pos(), Name: t.Name}}
+		default:
+			return nil
+		}
+	}
 }
 
-func (sp *StructParser) fieldAcronym(field *ast.Field) bool {
-	return sp.flagAcronymRegex.MatchString(field.Comment.Text())
+// embeddedFieldAnnotations are annotations of embedded field that make gobetter populate it, embedded fields
+// without them are skipped, so builders of existing structures don't get new steps
+var embeddedFieldAnnotations = map[string]bool{
+	"required": true, "_": true, "default": true, "lazy": true, "computed": true, "group": true,
 }
 
-func (sp *StructParser) constructorFlags(st *ast.StructType) StructFlags {
-	begin := st.Struct
-	endLine := sp.fileSet.File(begin).Line(begin) + 1
-	end := sp.fileSet.File(begin).LineStart(endLine)
-	result := string(sp.fileContent[sp.fileSet.Position(begin).Offset:sp.fileSet.Position(end).Offset])
+// embeddedFieldIncluded reports whether embedded field is annotated to be populated by gobetter
+func (sp *StructParser) embeddedFieldIncluded(field *ast.Field) bool {
+	for _, annotation := range sp.allFieldAnnotations(field) {
+		if embeddedFieldAnnotations[annotationName(annotation)] {
+			return true
+		}
+	}
+	return false
+}
+
+// hasAnnotations reports whether struct has at least one +gob: annotation in its own comment or in
+// comments of its fields
+func (sp *StructParser) hasAnnotations(ts *ast.TypeSpec) bool {
+	st, ok := ts.Type.(*ast.StructType)
+	if !ok {
+		return false
+	}
+	if sp.annotationRegexp.MatchString(sp.structComment(ts)) {
+		return true
+	}
+	for _, field := range st.Fields.List {
+		if sp.annotationRegexp.MatchString(sp.fieldComment(field)) {
+			return true
+		}
+	}
+	return false
+}
+
+// lineText returns source text from specified position till the end of the line
+func (sp *StructParser) lineText(begin token.Pos) string {
+	file := sp.fileSet.File(begin)
+	endOffset := file.Size()
+	if endLine := file.Line(begin) + 1; endLine <= file.LineCount() {
+		endOffset = file.Offset(file.LineStart(endLine))
+	}
+	return string(sp.fileContent[file.Offset(begin):endOffset])
+}
+
+// structComment returns comment with structure annotations: the rest of the line where type of structure
+// begins (e.g. "struct { //+gob:Constructor") and doc comment of the type
+func (sp *StructParser) structComment(ts *ast.TypeSpec) string {
+	return sp.lineText(ts.Type.Pos()) + sp.typeDocs[ts].Text()
+}
+
+// typeDocs returns doc comments of type specs of file
+func typeDocs(astFile *ast.File) map[*ast.TypeSpec]*ast.CommentGroup {
+	docs := make(map[*ast.TypeSpec]*ast.CommentGroup)
+	for _, decl := range astFile.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			ts := spec.(*ast.TypeSpec)
+			switch {
+			case ts.Doc != nil:
+				docs[ts] = ts.Doc
+			case !gd.Lparen.IsValid():
+				docs[ts] = gd.Doc
+			}
+		}
+	}
+	return docs
+}
+
+func (sp *StructParser) constructorFlags(ts *ast.TypeSpec) StructFlags {
+	result := sp.structComment(ts)
 	flags := StructFlags{
 		ProcessStruct: false,
 		PtrReceiver:   false,
@@ -225,6 +1983,35 @@ func (sp *StructParser) constructorFlags(st *ast.StructType) StructFlags {
 		flags.ProcessStruct = true
 		flags.Visibility = NoVisibility
 	}
+	flags.Skip = sp.flagSkipRegexp.MatchString(result)
+	flags.Provide = sp.flagProvideRegexp.MatchString(result)
+	flags.BuilderFrom = sp.flagFromRegexp.MatchString(result)
+	flags.SliceBuilder = sp.flagSliceRegexp.MatchString(result)
+	flags.Collector = sp.flagCollectorRegexp.MatchString(result)
+	flags.FromMap = sp.flagMapRegexp.MatchString(result)
+	flags.FromForm = sp.flagFormRegexp.MatchString(result)
+	flags.FromJSON = sp.flagJSONRegexp.MatchString(result)
+	flags.FromYAML = sp.flagYAMLRegexp.MatchString(result)
+	flags.TOML = sp.flagTOMLRegexp.MatchString(result)
+	flags.Bind = sp.flagBindRegexp.MatchString(result)
+	// binding helper decodes request body with JSON decoder
+	flags.FromJSON = flags.FromJSON || flags.Bind
+	flags.Slog = sp.flagSlogRegexp.MatchString(result)
+	flags.Scan = sp.flagScanRegexp.MatchString(result)
+	flags.Columns = sp.flagColumnsRegexp.MatchString(result)
+	flags.CSV = sp.flagCSVRegexp.MatchString(result)
+	flags.Merge = sp.flagMergeRegexp.MatchString(result)
+	flags.Patch = sp.flagPatchRegexp.MatchString(result)
+	flags.Immutable = sp.flagImmutableRegexp.MatchString(result)
+	if match := sp.flagViewRegexp.FindStringSubmatch(result); match != nil {
+		flags.View = strings.Split(match[1], ",")
+	}
+	if match := sp.flagProtoRegexp.FindStringSubmatch(result); match != nil {
+		flags.Proto = match[1]
+	}
+	if match := sp.flagChunkRegexp.FindStringSubmatch(result); match != nil {
+		flags.Chunk, _ = strconv.Atoi(match[1])
+	}
 
 	return flags
 }
@@ -0,0 +1,1234 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestModule writes files of module into temporary directory and returns the directory
+func writeTestModule(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filename, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func parseTestFile(t *testing.T, packages *PackageCache, filename string) *ast.File {
+	t.Helper()
+	astFile, err := parser.ParseFile(packages.FileSet(), filename, nil, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return astFile
+}
+
+func TestGenerateUsedImportsResolvesPackageNames(t *testing.T) {
+	dir := writeTestModule(t, map[string]string{
+		"go.mod":              "module t9\n\ngo 1.18\n",
+		"lib/nats.go/conn.go": "package nats\n\ntype Conn struct{}\n",
+		"lib/v2/client.go":    "package client\n\ntype Client struct{}\n",
+		"app/app.go": `package app
+
+import (
+	"t9/lib/nats.go"
+	"t9/lib/v2"
+)
+
+type Service struct {
+	Conn   *nats.Conn
+	Client *client.Client
+}
+`,
+	})
+	packages := NewPackageCache(nil)
+	srcDir := filepath.Join(dir, "app")
+	astFile := parseTestFile(t, packages, filepath.Join(srcDir, "app.go"))
+	code := "func (s *Service) Conns() (*nats.Conn, *client.Client) {\n\treturn s.Conn, s.Client\n}\n"
+
+	imports, err := GenerateUsedImports(packages, srcDir, astFile, nil, nil, code, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{`"t9/lib/nats.go"`, `"t9/lib/v2"`} {
+		if !strings.Contains(imports, path) {
+			t.Errorf("import %s is missing in:\n%s", path, imports)
+		}
+	}
+}
+
+func TestGenerateUsedImportsKeepsDotImports(t *testing.T) {
+	dir := writeTestModule(t, map[string]string{
+		"go.mod": "module t9\n\ngo 1.18\n",
+		"app.go": `package app
+
+import (
+	. "time"
+	. "strings"
+)
+
+type Service struct {
+	Timeout Duration
+	Name    *Builder
+}
+`,
+	})
+	packages := NewPackageCache(nil)
+	astFile := parseTestFile(t, packages, filepath.Join(dir, "app.go"))
+	code := "func (s *Service) GetTimeout() Duration {\n\treturn s.Timeout\n}\n"
+
+	imports, err := GenerateUsedImports(packages, dir, astFile, nil, nil, code, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(imports, `. "time"`) {
+		t.Errorf("dot import of time is missing in:\n%s", imports)
+	}
+	if strings.Contains(imports, `"strings"`) {
+		t.Errorf("unused dot import of strings is kept in:\n%s", imports)
+	}
+}
+
+func TestGenerateUsedImportsFailsOnUnresolvedPackage(t *testing.T) {
+	dir := writeTestModule(t, map[string]string{
+		"go.mod": "module t9\n\ngo 1.18\n",
+		"app.go": `package app
+
+import "t9/missing"
+
+type Service struct {
+	Conn *missing.Conn
+}
+`,
+	})
+	packages := NewPackageCache(nil)
+	astFile := parseTestFile(t, packages, filepath.Join(dir, "app.go"))
+	code := "func (s *Service) GetConn() *missing.Conn {\n\treturn s.Conn\n}\n"
+
+	_, err := GenerateUsedImports(packages, dir, astFile, nil, nil, code, "")
+	if err == nil || !strings.Contains(err.Error(), "t9/missing") {
+		t.Errorf("expected error resolving t9/missing, got %v", err)
+	}
+}
+
+func TestResetStartsNewStructure(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod": testModule,
+		"person.go": `package main
+
+type Person struct { //+gob:Constructor
+	Name string
+	Age  int
+}
+`,
+		"main.go": `package main
+
+import "fmt"
+
+func main() {
+	b := NewPersonBuilder()
+	persons := make([]*Person, 0)
+	for i, name := range []string{"a", "b"} {
+		last := b.Name(name).Age(i)
+		persons = append(persons, last.Build())
+		b = last.Reset()
+	}
+	fmt.Println(*persons[0], *persons[1], persons[0] != persons[1])
+}
+`,
+	}, "person.go")
+
+	assertOutput(t, runTestModule(t, dir), "{a 0} {b 1} true")
+}
+
+func TestGroupSetterSetsAllFieldsOfGroup(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod": testModule,
+		"person.go": `package main
+
+type Person struct { //+gob:Constructor
+	Name   string
+	Street string //+gob:group=address
+	City   string //+gob:group=address
+	Age    int
+}
+`,
+		"main.go": `package main
+
+import "fmt"
+
+func main() {
+	grouped := NewPersonBuilder().Name("a").Address(Person_Group_Address{Street: "s", City: "c"}).Age(1).Build()
+	separate := NewPersonBuilder().Name("a").Street("s").City("c").Age(1).Build()
+	fmt.Println(*grouped, *grouped == *separate)
+}
+`,
+	}, "person.go")
+
+	assertOutput(t, runTestModule(t, dir), "{a s c 1} true")
+}
+
+func TestGroupFieldsMustBeDeclaredNextToEachOther(t *testing.T) {
+	dir := writeTestModule(t, map[string]string{
+		"go.mod": testModule,
+		"person.go": `package main
+
+type Person struct { //+gob:Constructor
+	Street string //+gob:group=address
+	Name   string
+	City   string //+gob:group=address
+}
+`,
+	})
+	if code, diagnostics := generateTestFile(t, dir, "person.go"); code != ExitAnnotation {
+		t.Errorf("expected exit code %d, got %d:\n%s", ExitAnnotation, code, diagnostics)
+	}
+}
+
+func TestSortOrdersBuilderChain(t *testing.T) {
+	files := map[string]string{
+		"go.mod": testModule,
+		"person.go": `package main
+
+type Person struct { //+gob:Constructor
+	Name    string
+	Age     int
+	City    string
+	Country string //+gob:group=place
+	Zip     int    //+gob:group=place
+	Score   float64
+	Nick    string //+gob:_
+}
+`,
+	}
+	for _, test := range []struct {
+		order string
+		chain string
+	}{
+		{order: "seq", chain: `NewPersonBuilder().Name("n").Age(1).City("c").Country("d").Zip(2).Score(3).Build()`},
+		{order: "abc", chain: `NewPersonBuilder().Age(1).City("c").Country("d").Zip(2).Name("n").Score(3).Build()`},
+		{order: "type", chain: `NewPersonBuilder().Score(3).Age(1).City("c").Country("d").Zip(2).Name("n").Build()`},
+	} {
+		t.Run(test.order, func(t *testing.T) {
+			moduleFiles := map[string]string{"main.go": "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(*" +
+				test.chain + ")\n}\n"}
+			for name, content := range files {
+				moduleFiles[name] = content
+			}
+			dir := generateTestModule(t, moduleFiles, "person.go", "-sort", test.order)
+			assertOutput(t, runTestModule(t, dir), "{n 1 c d 2 3 }")
+		})
+	}
+}
+
+func TestConstructorOfInfersTypeParameters(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod": testModule,
+		"box.go": `package main
+
+type Box[T any] struct { //+gob:Constructor
+	Value T
+	Count int
+}
+
+type Pair[K comparable, V any] struct { //+gob:Constructor
+	Key   K
+	Value V
+}
+`,
+		"main.go": `package main
+
+import "fmt"
+
+func main() {
+	b := NewBoxOf("a").Count(1).Build()
+	fmt.Printf("%T %v\n", b, *b)
+}
+`,
+	}, "box.go")
+
+	assertOutput(t, runTestModule(t, dir), "*main.Box[string] {a 1}")
+	// type parameter V cannot be inferred from the first field
+	if generated := readTestFile(t, dir, "box_gob.go"); strings.Contains(generated, "func NewPairOf") {
+		t.Errorf("NewPairOf is generated, though V cannot be inferred:\n%s", generated)
+	}
+}
+
+func TestImportAliasesOfGenericFieldTypesArePreserved(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod":        testModule,
+		"option/opt.go": "package option\n\ntype Opt[T any] struct {\n\tValue T\n}\n",
+		"result/opt.go": "package result\n\ntype Opt[T any] struct {\n\tErr T\n}\n",
+		"request.go": `package main
+
+import (
+	opt "t9/option"
+	res "t9/result"
+)
+
+type Request struct { //+gob:Constructor
+	Limit  opt.Opt[int]
+	Status res.Opt[map[string]opt.Opt[string]]
+}
+`,
+		"main.go": `package main
+
+import (
+	"fmt"
+
+	"t9/option"
+	"t9/result"
+)
+
+func main() {
+	r := NewRequestBuilder().Limit(option.Opt[int]{Value: 1}).Status(result.Opt[map[string]option.Opt[string]]{}).Build()
+	fmt.Println(r.Limit.Value)
+}
+`,
+	}, "request.go")
+
+	assertOutput(t, runTestModule(t, dir), "1")
+	generated := readTestFile(t, dir, "request_gob.go")
+	for _, text := range []string{`opt "t9/option"`, `res "t9/result"`, "Status(arg res.Opt[map[string]opt.Opt[string]])"} {
+		if !strings.Contains(generated, text) {
+			t.Errorf("generated file lacks %q:\n%s", text, generated)
+		}
+	}
+}
+
+func TestDefinedTypeOverStructOfOtherPackage(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod":         testModule,
+		"model/order.go": "package model\n\ntype Order struct {\n\tID     string\n\tAmount int\n\tnote   string\n}\n",
+		"order.go": `package main
+
+import "t9/model"
+
+type Order model.Order //+gob:Constructor
+`,
+		"main.go": `package main
+
+import "fmt"
+
+func main() {
+	o := NewOrderBuilder().ID("a").Amount(1).Build()
+	fmt.Println(o.ID, o.Amount)
+}
+`,
+	}, "order.go")
+
+	assertOutput(t, runTestModule(t, dir), "a 1")
+}
+
+func TestDefinedTypeOverNonStructTypeFails(t *testing.T) {
+	dir := writeTestModule(t, map[string]string{
+		"go.mod":         testModule,
+		"model/order.go": "package model\n\ntype Order []string\n",
+		"order.go":       "package main\n\nimport \"t9/model\"\n\ntype Order model.Order //+gob:Constructor\n",
+	})
+	code, diagnostics := generateTestFile(t, dir, "order.go")
+	if code != ExitAnnotation || !strings.Contains(diagnostics, "type model.Order is not a struct") {
+		t.Errorf("expected error of non-struct type, got exit code %d:\n%s", code, diagnostics)
+	}
+}
+
+func TestMockDirectiveOfInterfaceFields(t *testing.T) {
+	files := map[string]string{
+		"go.mod": testModule,
+		"service.go": `package main
+
+import "io"
+
+type Repository interface {
+	Find(id string) (string, error)
+}
+
+type Clock interface {
+	Now() int64
+}
+
+type Service struct { //+gob:Constructor
+	Repo   Repository
+	Clock  Clock
+	Reader io.Reader
+}
+`,
+	}
+	for mock, directive := range map[string]string{
+		"moq":     "//go:generate moq -out service_mock_test.go . Repository Clock\n",
+		"mockgen": "//go:generate mockgen -source=service.go -destination=service_mock_test.go -package=main\n",
+		"none":    "",
+	} {
+		t.Run(mock, func(t *testing.T) {
+			generated := readTestFile(t, generateTestModule(t, files, "service.go", "-mock", mock), "service_gob.go")
+			if directive != "" && !strings.Contains(generated, directive) {
+				t.Errorf("generated file lacks directive %q:\n%s", directive, generated)
+			}
+			if directive == "" && strings.Contains(generated, "//go:generate") {
+				t.Errorf("generated file has go:generate directive:\n%s", generated)
+			}
+		})
+	}
+}
+
+func TestProviderAcceptsRequiredFields(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod": testModule,
+		"person.go": `package main
+
+type Person struct { //+gob:Constructor +gob:provide
+	Name string
+	Age  int
+	Nick string //+gob:_
+}
+
+type address struct { //+gob:constructor +gob:provide
+	city string
+}
+`,
+		"main.go": `package main
+
+import "fmt"
+
+func main() {
+	var provide func(string, int) *Person = ProvidePerson
+	fmt.Println(*provide("a", 1), *provideAddress("c"))
+}
+`,
+	}, "person.go")
+
+	assertOutput(t, runTestModule(t, dir), "{a 1 } {c}")
+}
+
+func TestLazyAndComputedFieldsArePopulatedByBuild(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod": testModule,
+		"person.go": `package main
+
+type Person struct { //+gob:Constructor
+	first    string
+	last     string
+	fullName string //+gob:lazy=makeFullName
+	initials string //+gob:computed=v.first[:1] + v.last[:1]
+}
+
+func (v *Person) makeFullName() string {
+	return v.first + " " + v.last
+}
+`,
+		"main.go": `package main
+
+import "fmt"
+
+func main() {
+	p := NewPersonBuilder().First("Joe").Last("Doe").Build()
+	fmt.Println(p.fullName, p.initials)
+}
+`,
+	}, "person.go")
+
+	assertOutput(t, runTestModule(t, dir), "Joe Doe JD")
+}
+
+func TestLazyAndComputedAnnotationsContradict(t *testing.T) {
+	dir := writeTestModule(t, map[string]string{
+		"go.mod": testModule,
+		"person.go": `package main
+
+type Person struct { //+gob:Constructor
+	name string //+gob:lazy=makeName +gob:computed="a"
+}
+`,
+	})
+	code, diagnostics := generateTestFile(t, dir, "person.go")
+	if code != ExitAnnotation || !strings.Contains(diagnostics, "contradict each other") {
+		t.Errorf("expected contradicting annotations to be reported, got exit code %d:\n%s", code, diagnostics)
+	}
+}
+
+func TestSkippedStructsAreNotProcessed(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod": testModule,
+		"model.go": `package main
+
+type Person struct {
+	Name string
+}
+
+// Point is built with composite literals
+//+gob:skip
+type Point struct {
+	X, Y int
+}
+
+type Size struct { //+gob:skip
+	W, H int
+}
+`,
+	}, "model.go", "-generate-for", "all")
+
+	generated := readTestFile(t, dir, "model_gob.go")
+	if !strings.Contains(generated, "func NewPersonBuilder()") {
+		t.Errorf("builder of Person is not generated:\n%s", generated)
+	}
+	if strings.Contains(generated, "Point") || strings.Contains(generated, "Size") {
+		t.Errorf("skipped structures are processed:\n%s", generated)
+	}
+}
+
+func TestTaggedModeProcessesStructsWithAnnotatedFields(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod": testModule,
+		"model.go": `package main
+
+type Person struct {
+	name string //+gob:getter
+	Age  int
+}
+
+type Point struct {
+	X, Y int
+}
+`,
+	}, "model.go", "-generate-for", "tagged")
+
+	generated := readTestFile(t, dir, "model_gob.go")
+	if !strings.Contains(generated, "func (v *Person) Name() string") {
+		t.Errorf("getter of tagged structure is not generated:\n%s", generated)
+	}
+	if strings.Contains(generated, "Point") {
+		t.Errorf("structure without annotations is processed:\n%s", generated)
+	}
+}
+
+func TestGetterStyleNamesGetters(t *testing.T) {
+	files := map[string]string{
+		"go.mod": testModule,
+		"person.go": `package main
+
+type Person struct { //+gob:Constructor
+	name string //+gob:getter
+	dob  string //+gob:getter +gob:acronym
+}
+`,
+	}
+	for style, getters := range map[string]string{"bare": "p.Name(), p.DOB()", "get": "p.GetName(), p.GetDOB()"} {
+		t.Run(style, func(t *testing.T) {
+			dir := generateTestModule(t, withTestFile(files, "main.go", "package main\n\nimport \"fmt\"\n\nfunc main() {\n"+
+				"\tp := NewPersonBuilder().Name(\"a\").DOB(\"b\").Build()\n\tfmt.Println("+getters+")\n}\n"),
+				"person.go", "-getter-style", style)
+			assertOutput(t, runTestModule(t, dir), "a b")
+		})
+	}
+}
+
+func TestSetterStylePrefixesSetters(t *testing.T) {
+	files := map[string]string{
+		"go.mod": testModule,
+		"person.go": `package main
+
+type Person struct { //+gob:Constructor
+	name string //+gob:getter
+	Age  int
+}
+`,
+	}
+	for style, chain := range map[string]string{
+		"bare": `NewPersonBuilder().Name("a").Age(1)`,
+		"with": `NewPersonBuilder().WithName("a").WithAge(1)`,
+		"set":  `NewPersonBuilder().SetName("a").SetAge(1)`,
+	} {
+		t.Run(style, func(t *testing.T) {
+			dir := generateTestModule(t, withTestFile(files, "main.go", "package main\n\nimport \"fmt\"\n\nfunc main() {\n"+
+				"\tp := "+chain+".Build()\n\tfmt.Println(p.Name(), p.Age)\n}\n"), "person.go", "-setter-style", style)
+			assertOutput(t, runTestModule(t, dir), "a 1")
+		})
+	}
+}
+
+func TestFinalizerAndBuildNamesAreConfigurable(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod": testModule,
+		"person.go": `package main
+
+type Person struct { //+gob:Constructor
+	Name string
+	Port int //+gob:default=80
+}
+`,
+		"main.go": `package main
+
+import "fmt"
+
+func main() {
+	var last Person_Builder_Complete = NewPersonBuilder().Name("a")
+	fmt.Println(*last.Create(), *last.Reset().Name("b").Create())
+}
+`,
+	}, "person.go", "-finalizer-name=Complete", "-build-name=Create")
+
+	assertOutput(t, runTestModule(t, dir), "{a 80} {b 80}")
+}
+
+func TestPackageLevelBuilderTypesAreUsableFromOtherPackages(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod":          testModule,
+		"model/person.go": "package model\n\ntype Person struct { //+gob:Constructor\n\tName string\n\tAge  int\n}\n",
+		"main.go": `package main
+
+import (
+	"fmt"
+
+	"t9/model"
+)
+
+func main() {
+	fmt.Println(*model.NewPersonBuilder().Name("a").Age(1).Build())
+}
+`,
+	}, "model/person.go", "-builder-visibility", "package")
+
+	assertOutput(t, runTestModule(t, dir), "{a 1}")
+	generated := readTestFile(t, dir, "model/person_gob.go")
+	if !strings.Contains(generated, "type person_builder_name struct") ||
+		strings.Contains(generated, "type Person_Builder_Name struct") {
+		t.Errorf("builder types are not package-level:\n%s", generated)
+	}
+}
+
+func TestCamelNamingOfBuilderTypes(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod": testModule,
+		"person.go": `package main
+
+type Person struct { //+gob:Constructor
+	Name   string
+	Street string //+gob:group=address
+	City   string //+gob:group=address
+}
+`,
+		"main.go": `package main
+
+import "fmt"
+
+func main() {
+	var b PersonBuilderName = NewPersonBuilder()
+	var last PersonBuilderGobFinalizer = b.Name("a").Address(PersonGroupAddress{Street: "s", City: "c"})
+	fmt.Println(*last.Build())
+}
+`,
+	}, "person.go", "-naming", "camel")
+
+	assertOutput(t, runTestModule(t, dir), "{a s c}")
+	if generated := readTestFile(t, dir, "person_gob.go"); strings.Contains(generated, "Person_") {
+		t.Errorf("generated file has legacy names:\n%s", generated)
+	}
+}
+
+func TestGeneratedImportsAreLimitedToReferencedPackages(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod": testModule,
+		"event.go": `package main
+
+import (
+	"fmt"
+	tm "time"
+)
+
+type Event struct { //+gob:Constructor
+	At   tm.Time
+	Name string
+}
+
+func (e *Event) String() string {
+	return fmt.Sprint(e.Name)
+}
+`,
+		"main.go": `package main
+
+import (
+	"fmt"
+	"time"
+)
+
+func main() {
+	fmt.Println(NewEventBuilder().At(time.Unix(0, 0)).Name("a").Build())
+}
+`,
+	}, "event.go")
+
+	assertOutput(t, runTestModule(t, dir), "a")
+	generated := readTestFile(t, dir, "event_gob.go")
+	if !strings.Contains(generated, `tm "time"`) || strings.Contains(generated, `"fmt"`) {
+		t.Errorf("generated file must import time only:\n%s", generated)
+	}
+}
+
+func TestLocalImportsAreGroupedSeparately(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod":         testModule,
+		"model/model.go": "package model\n\ntype ID string\n",
+		"event.go": `package main
+
+import (
+	"t9/model"
+	"time"
+)
+
+type Event struct { //+gob:Constructor
+	ID model.ID
+	At time.Time
+}
+`,
+	}, "event.go", "-local", "t9")
+
+	generated := readTestFile(t, dir, "event_gob.go")
+	if !strings.Contains(generated, "import (\n\t\"time\"\n\n\t\"t9/model\"\n)") {
+		t.Errorf("local imports are not grouped separately:\n%s", generated)
+	}
+}
+
+func TestCommentsOfInnerStructFieldsArePreserved(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod": testModule,
+		"server.go": `package main
+
+type Server struct { //+gob:Constructor
+	Limits struct {
+		// Max is maximum number of connections
+		Max int
+
+		Idle int ` + "`json:\"idle\"`" + ` // idle connections
+	}
+}
+`,
+	}, "server.go")
+
+	generated := readTestFile(t, dir, "server_gob.go")
+	for _, text := range []string{"// Max is maximum number of connections\n", "Max int\n\n", "`json:\"idle\"` // idle connections\n"} {
+		if !strings.Contains(generated, text) {
+			t.Errorf("generated file lacks %q:\n%s", text, generated)
+		}
+	}
+}
+
+func TestAnnotationsOfInnerStructFieldsOnOpeningLine(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod": testModule,
+		"config.go": `package main
+
+type Config struct { //+gob:Constructor
+	name   string
+	server struct { //+gob:getter
+		host string
+	}
+	limits struct { //+gob:_
+		max int
+	}
+}
+`,
+		"main.go": `package main
+
+import "fmt"
+
+func main() {
+	c := NewConfigBuilder().Name("a").Server(struct{ host string }{"h"}).Build()
+	fmt.Println(c.Server().host)
+}
+`,
+	}, "config.go")
+
+	assertOutput(t, runTestModule(t, dir), "h")
+}
+
+func TestMutuallyRecursiveStructs(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod": testModule,
+		"tree.go": `package main
+
+type Node struct { //+gob:Constructor
+	Name string
+	Edge *Edge //+gob:_
+}
+
+type Edge struct { //+gob:Constructor
+	Weight int
+	To     *Node //+gob:_
+}
+`,
+		"main.go": `package main
+
+import "fmt"
+
+func main() {
+	n := NewNodeBuilder().Name("a").Build()
+	e := NewEdgeBuilder().Weight(3).Build()
+	n.Edge, e.To = e, n
+	fmt.Println(n.Edge.To.Name, e.To.Edge.Weight)
+}
+`,
+	}, "tree.go")
+
+	assertOutput(t, runTestModule(t, dir), "a 3")
+}
+
+func TestAnyStyleOfEmptyInterfaces(t *testing.T) {
+	files := map[string]string{
+		"go.mod": testModule,
+		"bag.go": `package main
+
+type Bag struct { //+gob:Constructor
+	First  any
+	Second interface{}
+}
+`,
+	}
+	for _, style := range []string{"any", "interface{}"} {
+		t.Run(style, func(t *testing.T) {
+			dir := generateTestModule(t, files, "bag.go", "-anystyle", style)
+			generated := readTestFile(t, dir, "bag_gob.go")
+			other := "any"
+			if style == "any" {
+				other = "interface{}"
+			}
+			if strings.Count(generated, "(arg "+style+")") != 2 || strings.Contains(generated, "(arg "+other+")") {
+				t.Errorf("generated file does not use %s style:\n%s", style, generated)
+			}
+		})
+	}
+}
+
+func TestLockFieldsAreExcludedFromBuilders(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod": testModule,
+		"counter.go": `package main
+
+import "sync"
+
+type noCopy struct{}
+
+func (*noCopy) Lock()   {}
+func (*noCopy) Unlock() {}
+
+type Counter struct { //+gob:Constructor
+	name  string //+gob:getter
+	mu    sync.Mutex //+gob:getter
+	guard noCopy
+	count int
+}
+`,
+		"main.go": `package main
+
+import "fmt"
+
+func main() {
+	c := NewCounterBuilder().Name("a").Count(1).Build()
+	c.Mu().Lock()
+	c.count++
+	c.Mu().Unlock()
+	fmt.Println(c.Name(), c.count)
+}
+`,
+	}, "counter.go")
+
+	assertOutput(t, runTestModule(t, dir), "a 2")
+	vet := exec.Command("go", "vet", ".")
+	vet.Dir = dir
+	vet.Env = append(os.Environ(), "GOWORK=off", "GOFLAGS=-mod=mod")
+	if out, err := vet.CombinedOutput(); err != nil {
+		t.Errorf("go vet failed: %v\n%s", err, out)
+	}
+}
+
+func TestOptionalFieldsFromTags(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod": testModule,
+		"payload.go": `package main
+
+type Payload struct { //+gob:Constructor
+	Name   string   ` + "`json:\"name\"`" + `
+	Nick   string   ` + "`json:\"nick,omitempty\"`" + `
+	Parent *Payload ` + "`json:\"parent\"`" + `
+	Size   int
+}
+`,
+		"main.go": `package main
+
+import "fmt"
+
+func main() {
+	p := NewPayloadBuilder().Name("a").Size(1).Build()
+	p.Nick = "b"
+	fmt.Println(p.Name, p.Nick, p.Parent == nil, p.Size)
+}
+`,
+	}, "payload.go", "-optional-from-tags")
+
+	assertOutput(t, runTestModule(t, dir), "a b true 1")
+}
+
+func TestOnlyAnnotatedFieldsAreRequired(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod": testModule,
+		"query.go": `package main
+
+type Query struct { //+gob:Constructor
+	Table  string //+gob:required
+	Limit  int
+	Offset int
+}
+`,
+		"main.go": `package main
+
+import "fmt"
+
+func main() {
+	q := NewQueryBuilder().Table("t").Build()
+	q.Limit = 10
+	fmt.Println(q.Table, q.Limit, q.Offset)
+}
+`,
+	}, "query.go", "-required", "annotated")
+
+	assertOutput(t, runTestModule(t, dir), "t 10 0")
+}
+
+func TestDeprecatedShimsKeepLegacyNames(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod": testModule,
+		"person.go": `package main
+
+type Person struct { //+gob:Constructor
+	Name string
+	Age  int
+}
+`,
+		"main.go": `package main
+
+import "fmt"
+
+func main() {
+	var b Person_Builder_Name = NewPersonBuilder()
+	var last Person_Builder_GobFinalizer = b.Name("a").Age(1)
+	fmt.Println(*last.Build(), *NewPersonBuilder().WithName("b").WithAge(2).Make())
+}
+`,
+	}, "person.go", "-naming", "camel", "-setter-style", "with", "-finalizer-name", "Done", "-build-name", "Make",
+		"-deprecated-shims")
+
+	assertOutput(t, runTestModule(t, dir), "{a 1} {b 2}")
+	if generated := readTestFile(t, dir, "person_gob.go"); !strings.Contains(generated, "// Deprecated: use PersonBuilderDone instead.") {
+		t.Errorf("generated file lacks deprecation notice:\n%s", generated)
+	}
+}
+
+func TestTaggedModeSkipsFilesWithoutAnnotations(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod":   testModule,
+		"model.go": "package main\n\ntype Person struct {\n\tname string //+gob:getter\n}\n",
+	}, "model.go", "-generate-for", "tagged")
+	if err := os.WriteFile(filepath.Join(dir, "model.go"), []byte("package main\n\ntype Person struct {\n\tname string\n}\n"),
+		0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	generateTestModuleFile(t, dir, "model.go", "-generate-for", "tagged")
+	if _, err := os.Stat(filepath.Join(dir, "model_gob.go")); !os.IsNotExist(err) {
+		t.Errorf("file generated from file without annotations is not removed: %v", err)
+	}
+}
+
+func TestGettersOfExportedFields(t *testing.T) {
+	files := map[string]string{
+		"go.mod":    testModule,
+		"person.go": "package main\n\ntype Person struct { //+gob:Constructor\n\tName string //+gob:getter\n}\n",
+	}
+
+	t.Run("error", func(t *testing.T) {
+		dir := writeTestModule(t, files)
+		code, diagnostics := generateTestFile(t, dir, "person.go")
+		if code != ExitAnnotation || !strings.Contains(diagnostics, "getter of exported field Name of struct Person would have the same name") {
+			t.Errorf("exit code %d:\n%s", code, diagnostics)
+		}
+	})
+
+	t.Run("warn", func(t *testing.T) {
+		dir := writeTestModule(t, files)
+		code, diagnostics := generateTestFile(t, dir, "person.go", "-exported-getter", "warn")
+		if code != 0 || !strings.Contains(diagnostics, "warning: getter of exported field Name") {
+			t.Errorf("exit code %d:\n%s", code, diagnostics)
+		}
+		if generated := readTestFile(t, dir, "person_gob.go"); strings.Contains(generated, ") Name() string") {
+			t.Errorf("getter is generated:\n%s", generated)
+		}
+	})
+
+	t.Run("get", func(t *testing.T) {
+		dir := generateTestModule(t, withTestFile(files, "main.go",
+			"package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(NewPersonBuilder().Name(\"a\").Build().GetName())\n}\n"),
+			"person.go", "-exported-getter", "get")
+		assertOutput(t, runTestModule(t, dir), "a")
+	})
+}
+
+func TestContradictingAnnotationsAreReported(t *testing.T) {
+	for _, test := range []struct {
+		annotations string
+		conflict    string
+	}{
+		{annotations: "+gob:required +gob:_", conflict: "+gob:required +gob:_"},
+		{annotations: "+gob:group=a +gob:getter +gob:group=b", conflict: "+gob:group=a +gob:group=b"},
+		{annotations: "+gob:default=1 +gob:lazy=f", conflict: "+gob:default=1 +gob:lazy=f"},
+		{annotations: "+gob:validate=min=1 +gob:validate=max=2", conflict: ""},
+		{annotations: "+gob:getter +gob:required", conflict: ""},
+	} {
+		first, second, found := annotationConflict(strings.Fields(test.annotations))
+		if conflict := strings.TrimSpace(first + " " + second); conflict != test.conflict || found != (conflict != "") {
+			t.Errorf("%s: expected conflict %q, got %q", test.annotations, test.conflict, conflict)
+		}
+	}
+
+	dir := writeTestModule(t, map[string]string{
+		"go.mod":    testModule,
+		"person.go": "package main\n\ntype Person struct { //+gob:Constructor\n\tName string //+gob:required +gob:_\n}\n",
+	})
+	code, diagnostics := generateTestFile(t, dir, "person.go")
+	if code != ExitAnnotation || !strings.Contains(diagnostics, "person.go:4:30: annotations +gob:required and +gob:_ "+
+		"of field Name of struct Person contradict each other") {
+		t.Errorf("exit code %d:\n%s", code, diagnostics)
+	}
+}
+
+func TestSuggestionsOfMisspelledAnnotations(t *testing.T) {
+	for _, test := range []struct {
+		annotation string
+		known      map[string]bool
+		suggestion string
+	}{
+		{annotation: "+gob:getr", known: fieldAnnotationNames, suggestion: ", did you mean +gob:getter?"},
+		{annotation: "+gob:grup=address", known: fieldAnnotationNames, suggestion: ", did you mean +gob:group=address?"},
+		{annotation: "+gob:constrctor", known: structAnnotationNames, suggestion: ", did you mean +gob:constructor?"},
+		{annotation: "+gob:x", known: fieldAnnotationNames, suggestion: ""},
+		{annotation: "+gob:unrelated", known: fieldAnnotationNames, suggestion: ""},
+	} {
+		if suggestion := annotationSuggestion(test.annotation, test.known); suggestion != test.suggestion {
+			t.Errorf("%s: expected %q, got %q", test.annotation, test.suggestion, suggestion)
+		}
+	}
+}
+
+func TestAnnotationsTargetingNamesOfField(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod": testModule,
+		"person.go": `package main
+
+type Person struct { //+gob:Constructor
+	firstName, lastName, nick string //+gob:getter(firstName,nick) +gob:_(nick)
+}
+`,
+		"main.go": `package main
+
+import "fmt"
+
+func main() {
+	p := NewPersonBuilder().FirstName("a").LastName("b").Build()
+	p.nick = "c"
+	fmt.Println(p.FirstName(), p.lastName, p.Nick())
+}
+`,
+	}, "person.go")
+
+	assertOutput(t, runTestModule(t, dir), "a b c")
+	if generated := readTestFile(t, dir, "person_gob.go"); strings.Contains(generated, ") LastName() string") {
+		t.Errorf("getter of lastName is generated:\n%s", generated)
+	}
+}
+
+func TestAnnotationsInDocCommentsOfFields(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod": testModule,
+		"person.go": `package main
+
+type Person struct { //+gob:Constructor
+	// name of person
+	//+gob:getter
+	name string
+
+	// nick is optional
+	// +gob:_
+	nick string
+}
+`,
+		"main.go": `package main
+
+import "fmt"
+
+func main() {
+	p := NewPersonBuilder().Name("a").Build()
+	fmt.Println(p.Name(), p.nick == "")
+}
+`,
+	}, "person.go")
+
+	assertOutput(t, runTestModule(t, dir), "a true")
+}
+
+func TestAnnotationsInDocCommentsOfStructs(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod": testModule,
+		"person.go": `package main
+
+// Person is annotated in doc comment
+//
+//+gob:Constructor
+type Person struct {
+	Name string
+}
+
+type (
+	// Address is declared in group of types
+	// +gob:Constructor
+	Address struct {
+		City string
+	}
+)
+`,
+		"main.go": `package main
+
+import "fmt"
+
+func main() {
+	fmt.Println(NewPersonBuilder().Name("a").Build().Name, NewAddressBuilder().City("c").Build().City)
+}
+`,
+	}, "person.go")
+
+	assertOutput(t, runTestModule(t, dir), "a c")
+}
+
+func TestEmbeddedFieldsCanBeOptionalOrDefault(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod": testModule,
+		"server.go": `package main
+
+type Base struct {
+	ID string
+}
+
+type Limits struct {
+	Max int
+}
+
+type Server struct { //+gob:Constructor
+	Base   //+gob:_
+	Limits //+gob:default=Limits{Max: 8}
+	*Owner //+gob:required
+	Host   string
+}
+
+type Owner struct {
+	Name string
+}
+`,
+		"main.go": `package main
+
+import "fmt"
+
+func main() {
+	s := NewServerBuilder().Owner(&Owner{Name: "o"}).Host("h").Build()
+	fmt.Println(s.ID == "", s.Max, s.Name, s.Host)
+}
+`,
+	}, "server.go")
+
+	assertOutput(t, runTestModule(t, dir), "true 8 o h")
+}
+
+func TestChunkedBuilderChain(t *testing.T) {
+	files := map[string]string{
+		"go.mod": testModule,
+		"record.go": `package main
+
+type Record struct { //+gob:Constructor +gob:chunk=2
+	A    string
+	B    int
+	C    string
+	D    int
+	E    string
+	Note string //+gob:_
+}
+`,
+		"main.go": `package main
+
+import "fmt"
+
+func main() {
+	r := NewRecordBuilder().
+		Section1(Record_Group_Section1{A: "a", B: 1}).
+		Section2(Record_Group_Section2{C: "c", D: 2}).
+		Section3(Record_Group_Section3{E: "e"}).
+		Build()
+	fmt.Println(*r)
+}
+`,
+	}
+	dir := generateTestModule(t, files, "record.go")
+	assertOutput(t, runTestModule(t, dir), "{a 1 c 2 e }")
+
+	dir = writeTestModule(t, withTestFile(files, "record.go",
+		"package main\n\ntype Record struct { //+gob:Constructor +gob:chunk=2\n\tA string //+gob:group=x\n\tB int\n}\n"))
+	code, diagnostics := generateTestFile(t, dir, "record.go")
+	if code != ExitAnnotation || !strings.Contains(diagnostics, "field A of struct Record cannot be grouped, because builder chain is chunked") {
+		t.Errorf("exit code %d:\n%s", code, diagnostics)
+	}
+}
+
+func TestBuilderFromCopiesPrototype(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod": testModule,
+		"person.go": `package main
+
+import "sync"
+
+type Person struct { //+gob:Constructor +gob:from
+	Name string
+	Age  int
+	Nick string //+gob:_
+}
+
+type Counter struct { //+gob:Constructor +gob:from
+	mu    sync.Mutex
+	Count int
+}
+`,
+		"main.go": `package main
+
+import "fmt"
+
+func main() {
+	proto := NewPersonBuilder().Name("a").Age(1).Build()
+	proto.Nick = "n"
+	p := PersonBuilderFrom(*proto).Build()
+	p.Name = "b"
+	fmt.Println(*proto, *p)
+}
+`,
+	}, "person.go")
+
+	assertOutput(t, runTestModule(t, dir), "{a 1 n} {b 1 n}")
+	if generated := readTestFile(t, dir, "person_gob.go"); strings.Contains(generated, "CounterBuilderFrom") {
+		t.Errorf("builder from struct holding lock is generated:\n%s", generated)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"strings"
+)
+
+// GenerateMapBuilders generates Put<Field>() methods of finalizer of builder chain for map fields which values
+// are structures with builder chains (e.g. "endpoints map[string]Endpoint"), so keyed sections can be
+// assembled fluently: PutEndpoints("api", func(b Endpoint_Builder_URL) Endpoint_Builder_GobFinalizer {...}).
+// Targets are all structures of input file with builder chains.
+func GenerateMapBuilders(target *fixTarget, targets map[string]*fixTarget) string {
+	if len(target.chain) == 0 {
+		return ""
+	}
+	finalizerType := target.chain[0].finalizer().builderFieldStructType()
+	bld := &strings.Builder{}
+	for _, sf := range target.fields {
+		mapType, ok := parseFieldType(sf.FieldTypeText).(*ast.MapType)
+		if !ok {
+			continue
+		}
+		valueType, pointer := mapType.Value, false
+		if star, ok := valueType.(*ast.StarExpr); ok {
+			valueType, pointer = star.X, true
+		}
+		ident, ok := valueType.(*ast.Ident)
+		if !ok {
+			continue
+		}
+		value := targets[ident.Name]
+		if value == nil || len(value.chain) == 0 || value.root.TypeParams != "" {
+			continue
+		}
+		if !pointer && holdsLocks(value.fields) {
+			// map values are copies of built structures
+			continue
+		}
+		first := value.chain[0]
+		built := "*build(" + first.constructorName() + "Builder())." + first.StructFlags.BuildName + "()"
+		if pointer {
+			built = built[1:]
+		}
+		bld.WriteString(fmt.Sprintf(`
+// Put%[2]s builds value with builder chain started by build function and puts it into %[3]s under key
+func (b %[1]s) Put%[2]s(key %[4]s, build func(%[5]s) %[6]s) %[1]s {
+	if b.root.%[3]s == nil {
+		b.root.%[3]s = make(%[7]s)
+	}
+	b.root.%[3]s[key] = %[8]s
+	return b
+}
+
+`, finalizerType, sf.exportName(), sf.FieldName, sf.FieldTypeText[mapType.Key.Pos()-1:mapType.Key.End()-1],
+			first.builderFieldStructType(), first.finalizer().builderFieldStructType(), sf.FieldTypeText, built))
+	}
+	return bld.String()
+}
+
+// parseFieldType parses type of field, nil is returned for types that cannot be parsed
+func parseFieldType(typeText string) ast.Expr {
+	expr, err := parser.ParseExpr(typeText)
+	if err != nil {
+		return nil
+	}
+	return expr
+}
+
+// holdsLocks reports whether any of fields holds lock by value
+func holdsLocks(fields []*StructField) bool {
+	for _, sf := range fields {
+		if sf.NoCopy {
+			return true
+		}
+	}
+	return false
+}
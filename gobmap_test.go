@@ -0,0 +1,36 @@
+package main
+
+import "testing"
+
+func TestPutBuildsValuesOfMapFields(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod": testModule,
+		"config.go": `package main
+
+type Endpoint struct { //+gob:Constructor
+	URL string
+}
+
+type Config struct { //+gob:Constructor
+	Name      string
+	Endpoints map[string]Endpoint  //+gob:_
+	Backups   map[string]*Endpoint //+gob:_
+}
+`,
+		"main.go": `package main
+
+import "fmt"
+
+func main() {
+	c := NewConfigBuilder().Name("a").
+		PutEndpoints("api", func(b Endpoint_Builder_URL) Endpoint_Builder_GobFinalizer { return b.URL("u1") }).
+		PutEndpoints("web", func(b Endpoint_Builder_URL) Endpoint_Builder_GobFinalizer { return b.URL("u2") }).
+		PutBackups("api", func(b Endpoint_Builder_URL) Endpoint_Builder_GobFinalizer { return b.URL("u3") }).
+		Build()
+	fmt.Println(c.Name, c.Endpoints, c.Backups["api"].URL)
+}
+`,
+	}, "config.go")
+
+	assertOutput(t, runTestModule(t, dir), "a map[api:{u1} web:{u2}] u3")
+}
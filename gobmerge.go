@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// GenerateMerge generates Merge(other) method overlaying fields of other structure that are set (have non-zero
+// values) onto the receiver, so layered configuration (e.g. defaults, then file, then environment) can be
+// combined from structures built with builders. Fields holding locks are never merged.
+func GenerateMerge(root *StructField, fields []*StructField) string {
+	if !root.StructFlags.Merge {
+		return ""
+	}
+	bld := &strings.Builder{}
+	bld.WriteString(fmt.Sprintf(`
+func (v *%[1]s) Merge(other *%[1]s) *%[1]s {
+	if other == nil {
+		return v
+	}
+`, root.structType()))
+	for _, sf := range fields {
+		if sf.NoCopy {
+			continue
+		}
+		bld.WriteString(fmt.Sprintf(`	if %[2]s {
+		v.%[1]s = other.%[1]s
+	}
+`, sf.FieldName, sf.zeroCheck("other", true)))
+	}
+	bld.WriteString(`	return v
+}
+
+`)
+	return bld.String()
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMergeOverlaysNonZeroFields(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod": testModule,
+		"config.go": `package main
+
+type Level int
+
+type Limits struct {
+	Max int
+}
+
+type Config struct { //+gob:Constructor +gob:merge
+	Host    string
+	Port    int
+	Debug   bool
+	Level   Level
+	Tags    []string
+	Headers map[string]string
+	Parent  *Config
+	Limits  Limits
+}
+`,
+		"main.go": `package main
+
+import "fmt"
+
+func main() {
+	base := NewConfigBuilder().Host("a").Port(1).Debug(true).Level(2).Tags([]string{"x"}).
+		Headers(map[string]string{"k": "v"}).Parent(nil).Limits(Limits{Max: 3}).Build()
+	base.Merge(&Config{Host: "b", Limits: Limits{Max: 4}}).Merge(&Config{Port: 5}).Merge(nil)
+	fmt.Println(base.Host, base.Port, base.Debug, base.Level, base.Tags, base.Headers, base.Limits.Max)
+}
+`,
+	}, "config.go")
+
+	assertOutput(t, runTestModule(t, dir), "b 5 true 2 [x] map[k:v] 4")
+	generated := readTestFile(t, dir, "config_gob.go")
+	for _, check := range []string{
+		`if other.Host != "" {`,
+		"if other.Port != 0 {",
+		"if other.Debug {",
+		"if other.Level != 0 {",
+		"if other.Tags != nil {",
+		"if other.Headers != nil {",
+		"if other.Parent != nil {",
+		// structures are not known to be comparable
+		"if !reflect.ValueOf(&other.Limits).Elem().IsZero() {",
+	} {
+		if !strings.Contains(generated, check) {
+			t.Errorf("generated Merge lacks check %q:\n%s", check, generated)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// patchName returns name of patch structure (e.g. PersonPatch), it is package-level for structures with
+// package-level constructors
+func (sf *StructField) patchName() string {
+	if strings.HasPrefix(sf.constructorName(), "new") {
+		return lowerFirst(sf.StructName) + "Patch"
+	}
+	return strings.Title(sf.StructName) + "Patch"
+}
+
+// patchFields returns fields that can be updated by patch. Fields populated by Build() function from other
+// fields and fields holding locks are not patched.
+func patchFields(fields []*StructField) []*StructField {
+	patched := make([]*StructField, 0, len(fields))
+	for _, sf := range fields {
+		if sf.NoCopy || sf.Lazy != "" || sf.Computed != "" {
+			continue
+		}
+		patched = append(patched, sf)
+	}
+	return patched
+}
+
+// GeneratePatch generates patch structure (e.g. PersonPatch) holding pointers to values of fields, its builder
+// and function applying it (e.g. ApplyPersonPatch), so PATCH-style partial updates change only fields that are
+// set in patch. Patch fields have the same JSON keys as fields of structure and are omitted when nil, values of
+// secret fields are masked when patch is encoded to JSON.
+func GeneratePatch(root *StructField, fields []*StructField) string {
+	if !root.StructFlags.Patch {
+		return ""
+	}
+	patchName := root.patchName()
+	patched := patchFields(fields)
+	bld := &strings.Builder{}
+	bld.WriteString(fmt.Sprintf(`
+// %s holds fields of %s to update, nil fields are left unchanged
+type %s%s struct {
+`, patchName, root.StructName, patchName, root.TypeParams))
+	for _, sf := range patched {
+		tag := "-"
+		if key := jsonKey(sf); key != "" {
+			tag = key + ",omitempty"
+		}
+		bld.WriteString(fmt.Sprintf("\t%s *%s `json:%s`\n", sf.exportName(), sf.FieldTypeText, strconv.Quote(tag)))
+	}
+	bld.WriteString("}\n\n")
+
+	funcName := "Apply" + strings.Title(patchName)
+	if strings.HasPrefix(root.constructorName(), "new") {
+		funcName = "apply" + strings.Title(patchName)
+	}
+	bld.WriteString(fmt.Sprintf(`
+func %s%s(p *%s, patch %s%s) {
+`, funcName, root.TypeParams, root.structType(), patchName, root.TypeArgs))
+	for _, sf := range patched {
+		bld.WriteString(fmt.Sprintf(`	if patch.%s != nil {
+		p.%s = *patch.%s
+	}
+`, sf.exportName(), sf.FieldName, sf.exportName()))
+	}
+	bld.WriteString("}\n\n")
+	generatePatchMarshaler(bld, root, patched)
+	generatePatchBuilder(bld, root, patched)
+	return bld.String()
+}
+
+// generatePatchMarshaler generates MarshalJSON() method of patch structure masking values of secret fields, so
+// they don't leak when patches are logged or audited. Nothing is generated if patch has no secret fields.
+func generatePatchMarshaler(bld *strings.Builder, root *StructField, patched []*StructField) {
+	hasSecrets := false
+	for _, sf := range patched {
+		hasSecrets = hasSecrets || sf.Secret && jsonKey(sf) != ""
+	}
+	if !hasSecrets {
+		return
+	}
+	bld.WriteString(fmt.Sprintf(`
+func (p %s%s) MarshalJSON() ([]byte, error) {
+	fields := make(map[string]%s, %d)
+`, root.patchName(), root.TypeArgs, root.StructFlags.AnyStyle, len(patched)))
+	for _, sf := range patched {
+		key := jsonKey(sf)
+		if key == "" {
+			continue
+		}
+		value := "*p." + sf.exportName()
+		if sf.Secret {
+			value = strconv.Quote(secretMask)
+		}
+		bld.WriteString(fmt.Sprintf(`	if p.%s != nil {
+		fields[%s] = %s
+	}
+`, sf.exportName(), strconv.Quote(key), value))
+	}
+	bld.WriteString("\treturn json.Marshal(fields)\n}\n\n")
+}
+
+// patchBuilderName returns name of builder of patch structure, it follows naming scheme and visibility of
+// builder chain types
+func (sf *StructField) patchBuilderName() string {
+	name := sf.patchName() + "_Builder"
+	if sf.StructFlags.Naming == "camel" {
+		name = sf.patchName() + "Builder"
+	}
+	if sf.StructFlags.BuilderVisibility == PackageLevelVisibility {
+		return lowerFirst(name)
+	}
+	return name
+}
+
+// generatePatchBuilder generates builder of patch structure. All fields of patch are optional, so its builder
+// is a single type with setters named the same way as setters of builder chain of structure.
+func generatePatchBuilder(bld *strings.Builder, root *StructField, patched []*StructField) {
+	patchType := root.patchName() + root.TypeArgs
+	builderName := root.patchBuilderName()
+	builderType := builderName + root.TypeArgs
+	constructorName := "New" + strings.Title(root.patchName()) + "Builder"
+	if strings.HasPrefix(root.constructorName(), "new") {
+		constructorName = "new" + strings.Title(root.patchName()) + "Builder"
+	}
+	bld.WriteString(fmt.Sprintf(`
+type %s%s struct {
+	patch %s
+}
+
+func %s%s() %s {
+	return %s{}
+}
+
+`, builderName, root.TypeParams, patchType, constructorName, root.TypeParams, builderType, builderType))
+	for _, sf := range patched {
+		bld.WriteString(fmt.Sprintf(`
+func (b %s) %s(arg %s) %s {
+	b.patch.%s = &arg
+	return b
+}
+
+`, builderType, sf.setterName(), sf.FieldTypeText, builderType, sf.exportName()))
+	}
+	bld.WriteString(fmt.Sprintf(`
+func (b %s) %s() %s {
+	return b.patch
+}
+
+`, builderType, root.StructFlags.BuildName, patchType))
+}
@@ -0,0 +1,62 @@
+package main
+
+import "testing"
+
+func TestApplyPatchChangesSetFieldsOnly(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod": testModule,
+		"person.go": `package main
+
+type Person struct { //+gob:Constructor +gob:patch
+	Name string
+	Age  int
+	Nick string //+gob:_
+}
+`,
+		"main.go": `package main
+
+import "fmt"
+
+func main() {
+	p := NewPersonBuilder().Name("a").Age(1).Build()
+	age := 2
+	ApplyPersonPatch(p, PersonPatch{Age: &age})
+	fmt.Println(*p)
+}
+`,
+	}, "person.go")
+
+	assertOutput(t, runTestModule(t, dir), "{a 2 }")
+}
+
+func TestPatchBuilderAndJSON(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod": testModule,
+		"account.go": `package main
+
+type Account struct { //+gob:Constructor +gob:patch
+	Login    string ` + "`json:\"login\"`" + `
+	Password string ` + "`json:\"password\"`" + ` //+gob:secret
+	Email    string ` + "`json:\"email\"`" + `
+}
+`,
+		"main.go": `package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func main() {
+	patch := NewAccountPatchBuilder().Password("p").Email("e").Build()
+	data, err := json.Marshal(patch)
+	fmt.Println(string(data), err)
+	a := NewAccountBuilder().Login("l").Password("x").Email("x").Build()
+	ApplyAccountPatch(a, patch)
+	fmt.Println(a.Login, a.Password == "p", a.Email)
+}
+`,
+	}, "account.go")
+
+	assertOutput(t, runTestModule(t, dir), `{"email":"e","password":"***"} <nil>`, "l true e")
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"strings"
+)
+
+// protoFields type-checks package of protobuf message referenced by +gob:proto annotation (e.g. "pb.Person")
+// and returns exported fields of the message
+func (sp *StructParser) protoFields(astFile *ast.File, srcDir string, message string) ([]*StructField, error) {
+	expr, err := parser.ParseExpr(message)
+	if err != nil {
+		return nil, fmt.Errorf("invalid protobuf message %s: %v", message, err)
+	}
+	sel, ok := expr.(*ast.SelectorExpr)
+	if !ok {
+		return nil, fmt.Errorf("protobuf message %s must be in form of <package>.<Message>", message)
+	}
+	// types of message fields are never referenced by generated code, so their imports are dropped
+	return sp.foreignStructFields(astFile, srcDir, sel, make(map[string]string))
+}
+
+// GenerateProtoConverters generates ToProto() method converting structure to protobuf message and
+// New<StructName>FromProto() constructor populating structure from protobuf message through builder chain.
+// Fields are matched with message fields by case-insensitive names (e.g. "userID" field matches "UserId"
+// message field), every required field must have matching message field.
+func GenerateProtoConverters(
+	root *StructField,
+	fields []*StructField,
+	chain []*StructField,
+	messageFields []*StructField,
+) (string, error) {
+	message := root.StructFlags.Proto
+	messageNames := make(map[string]string)
+	for _, mf := range messageFields {
+		messageNames[strings.ToLower(mf.FieldName)] = mf.FieldName
+	}
+	required := make(map[*StructField]bool)
+	for _, sf := range chain {
+		required[sf] = true
+		if _, ok := messageNames[strings.ToLower(sf.FieldName)]; !ok {
+			return "", sf.errorf("field %s of struct %s is required, but protobuf message %s has no matching field",
+				sf.FieldName, sf.StructName, message)
+		}
+	}
+	bld := &strings.Builder{}
+	bld.WriteString(fmt.Sprintf(`
+func (v *%s) ToProto() *%s {
+	return &%s{
+`, root.structType(), message, message))
+	for _, sf := range fields {
+		if name, ok := messageNames[strings.ToLower(sf.FieldName)]; ok && !sf.NoCopy {
+			bld.WriteString(fmt.Sprintf("\t\t%s: v.%s,\n", name, sf.FieldName))
+		}
+	}
+	bld.WriteString("\t}\n}\n\n")
+
+	if root.StructFlags.Visibility == NoVisibility {
+		return bld.String(), nil
+	}
+	bld.WriteString(fmt.Sprintf(`
+func %sFromProto%s(m *%s) (*%s, error) {
+	if m == nil {
+		return nil, fmt.Errorf("protobuf message %s is nil")
+	}
+`, root.constructorName(), root.TypeParams, message, root.structType(), message))
+	if len(chain) > 0 {
+		values := make([]string, 0, len(chain))
+		for _, sf := range chain {
+			values = append(values, "m."+messageNames[strings.ToLower(sf.FieldName)])
+		}
+		bld.WriteString(fmt.Sprintf("\tv := %sBuilder%s().%s.%s()\n",
+			root.constructorName(), root.TypeArgs, strings.Join(chainCalls(chain, values), "."), root.StructFlags.BuildName))
+	} else {
+		bld.WriteString(fmt.Sprintf("\tv := &%s{}\n", root.structType()))
+	}
+	for _, sf := range fields {
+		name, ok := messageNames[strings.ToLower(sf.FieldName)]
+		if !ok || required[sf] || sf.NoCopy {
+			continue
+		}
+		bld.WriteString(fmt.Sprintf("\tv.%s = m.%s\n", sf.FieldName, name))
+	}
+	bld.WriteString("\treturn v, nil\n}\n\n")
+	return bld.String(), nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const testProtoPackage = `package pb
+
+type User struct {
+	Name   string
+	UserId int64
+	Email  string
+}
+`
+
+func TestProtoConvertersRoundTrip(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod":     testModule,
+		"pb/user.go": testProtoPackage,
+		"user.go": `package main
+
+import "t9/pb"
+
+var _ pb.User
+
+type User struct { //+gob:Constructor +gob:proto=pb.User
+	Name   string
+	UserID int64
+	Nick   string //+gob:_
+}
+`,
+		"main.go": `package main
+
+import (
+	"fmt"
+
+	"t9/pb"
+)
+
+func main() {
+	m := NewUserBuilder().Name("a").UserID(1).Build().ToProto()
+	u, err := NewUserFromProto(&pb.User{Name: m.Name, UserId: m.UserId + 1})
+	_, nilErr := NewUserFromProto(nil)
+	fmt.Println(m.Name, m.UserId, u.Name, u.UserID, err, nilErr != nil)
+}
+`,
+	}, "user.go")
+
+	assertOutput(t, runTestModule(t, dir), "a 1 a 2 <nil> true")
+}
+
+func TestProtoMessageMustCoverRequiredFields(t *testing.T) {
+	dir := writeTestModule(t, map[string]string{
+		"go.mod":     testModule,
+		"pb/user.go": testProtoPackage,
+		"user.go": `package main
+
+import "t9/pb"
+
+var _ pb.User
+
+type User struct { //+gob:Constructor +gob:proto=pb.User
+	Name  string
+	Phone string
+}
+`,
+	})
+
+	code, diagnostics := generateTestFile(t, dir, "user.go")
+	if code == 0 || !strings.Contains(diagnostics, "field Phone of struct User is required, but protobuf message pb.User has no matching field") {
+		t.Errorf("exit code %d:\n%s", code, diagnostics)
+	}
+}
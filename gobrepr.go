@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// secretMask replaces values of secret fields in output of generated stringers and serializers
+const secretMask = "***"
+
+// GenerateStringer generates String() method that formats structure the same way as "%+v" verb does, but
+// masks values of secret fields, so they don't leak into logs. Nothing is generated if structure has no
+// secret fields.
+func GenerateStringer(root *StructField, fields []*StructField) string {
+	hasSecrets := false
+	format := make([]string, 0, len(fields))
+	args := make([]string, 0, len(fields))
+	for _, sf := range fields {
+		switch {
+		case sf.Secret:
+			hasSecrets = true
+			format = append(format, sf.FieldName+":"+secretMask)
+		case sf.NoCopy:
+		default:
+			format = append(format, sf.FieldName+":%v")
+			args = append(args, "v."+sf.FieldName)
+		}
+	}
+	if !hasSecrets {
+		return ""
+	}
+	value := strconv.Quote("{" + strings.Join(format, " ") + "}")
+	if len(args) > 0 {
+		value = fmt.Sprintf("fmt.Sprintf(%s, %s)", value, strings.Join(args, ", "))
+	}
+	return fmt.Sprintf(`
+func (v %s) String() string {
+	return %s
+}
+
+`, reprReceiver(root, fields), value)
+}
+
+// GenerateLogValuer generates LogValue() method implementing slog.LogValuer, so structure is logged as group
+// of attributes. Values of secret fields are masked and optional fields are logged only if they are set.
+func GenerateLogValuer(root *StructField, fields []*StructField, chain []*StructField) string {
+	if !root.StructFlags.Slog {
+		return ""
+	}
+	required := make(map[*StructField]bool)
+	for _, sf := range chain {
+		required[sf] = true
+	}
+	for _, sf := range root.StructFlags.Derived {
+		required[sf] = true
+	}
+	bld := &strings.Builder{}
+	bld.WriteString(fmt.Sprintf(`
+func (v %s) LogValue() slog.Value {
+	attrs := make([]slog.Attr, 0, %d)
+`, reprReceiver(root, fields), len(fields)))
+	for _, sf := range fields {
+		switch {
+		case sf.NoCopy:
+		case sf.Secret:
+			bld.WriteString(fmt.Sprintf("\tattrs = append(attrs, slog.String(%s, %q))\n",
+				strconv.Quote(sf.FieldName), secretMask))
+		case required[sf]:
+			bld.WriteString(fmt.Sprintf("\tattrs = append(attrs, slog.Any(%s, v.%s))\n",
+				strconv.Quote(sf.FieldName), sf.FieldName))
+		default:
+			bld.WriteString(fmt.Sprintf(`	if %s {
+		attrs = append(attrs, slog.Any(%s, v.%s))
+	}
+`, sf.zeroCheck("v", true), strconv.Quote(sf.FieldName), sf.FieldName))
+		}
+	}
+	bld.WriteString("\treturn slog.GroupValue(attrs...)\n}\n\n")
+	return bld.String()
+}
+
+// zapEncoderMethods maps field types to methods of zapcore.ObjectEncoder adding values of these types
+var zapEncoderMethods = map[string]string{
+	"string":        "AddString",
+	"bool":          "AddBool",
+	"int":           "AddInt",
+	"int32":         "AddInt32",
+	"int64":         "AddInt64",
+	"uint":          "AddUint",
+	"uint32":        "AddUint32",
+	"uint64":        "AddUint64",
+	"float32":       "AddFloat32",
+	"float64":       "AddFloat64",
+	"time.Duration": "AddDuration",
+	"time.Time":     "AddTime",
+}
+
+// zerologEventMethods maps field types to methods of zerolog.Event adding values of these types
+var zerologEventMethods = map[string]string{
+	"string":        "Str",
+	"bool":          "Bool",
+	"int":           "Int",
+	"int32":         "Int32",
+	"int64":         "Int64",
+	"uint":          "Uint",
+	"uint32":        "Uint32",
+	"uint64":        "Uint64",
+	"float32":       "Float32",
+	"float64":       "Float64",
+	"time.Duration": "Dur",
+	"time.Time":     "Time",
+}
+
+// GenerateLogMarshaler generates method marshaling structure with selected logging library: MarshalLogObject
+// implementing zapcore.ObjectMarshaler or MarshalZerologObject implementing zerolog.LogObjectMarshaler.
+// Same as for LogValue(), values of secret fields are masked and optional fields are logged only if they are set.
+func GenerateLogMarshaler(root *StructField, fields []*StructField, chain []*StructField, marshaler string) string {
+	if marshaler == "none" {
+		return ""
+	}
+	required := make(map[*StructField]bool)
+	for _, sf := range chain {
+		required[sf] = true
+	}
+	for _, sf := range root.StructFlags.Derived {
+		required[sf] = true
+	}
+	bld := &strings.Builder{}
+	if marshaler == "zap" {
+		bld.WriteString(fmt.Sprintf(`
+func (v %s) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+`, reprReceiver(root, fields)))
+	} else {
+		bld.WriteString(fmt.Sprintf(`
+func (v %s) MarshalZerologObject(e *zerolog.Event) {
+`, reprReceiver(root, fields)))
+	}
+	for _, sf := range fields {
+		if sf.NoCopy {
+			continue
+		}
+		value := "v." + sf.FieldName
+		var add string
+		if marshaler == "zap" {
+			method, ok := zapEncoderMethods[sf.FieldTypeText]
+			switch {
+			case sf.Secret:
+				add = fmt.Sprintf("enc.AddString(%s, %q)\n", strconv.Quote(sf.FieldName), secretMask)
+			case ok:
+				add = fmt.Sprintf("enc.%s(%s, %s)\n", method, strconv.Quote(sf.FieldName), value)
+			default:
+				add = fmt.Sprintf(`if err := enc.AddReflected(%s, %s); err != nil {
+		return err
+	}
+`, strconv.Quote(sf.FieldName), value)
+			}
+		} else {
+			method, ok := zerologEventMethods[sf.FieldTypeText]
+			switch {
+			case sf.Secret:
+				add = fmt.Sprintf("e.Str(%s, %q)\n", strconv.Quote(sf.FieldName), secretMask)
+			case ok:
+				add = fmt.Sprintf("e.%s(%s, %s)\n", method, strconv.Quote(sf.FieldName), value)
+			default:
+				add = fmt.Sprintf("e.Interface(%s, %s)\n", strconv.Quote(sf.FieldName), value)
+			}
+		}
+		if required[sf] || sf.Secret {
+			bld.WriteString("\t" + add)
+		} else {
+			bld.WriteString(fmt.Sprintf(`	if %s {
+		%s	}
+`, sf.zeroCheck("v", true), add))
+		}
+	}
+	if marshaler == "zap" {
+		bld.WriteString("\treturn nil\n")
+	}
+	bld.WriteString("}\n\n")
+	return bld.String()
+}
+
+// reprReceiver returns receiver type of methods representing structure. Value receiver is used, so methods
+// are called for both values and pointers, unless structure holds locks that must not be copied.
+func reprReceiver(root *StructField, fields []*StructField) string {
+	for _, sf := range fields {
+		if sf.NoCopy {
+			return "*" + root.structType()
+		}
+	}
+	return root.structType()
+}
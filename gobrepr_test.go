@@ -0,0 +1,144 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSecretFieldsAreMasked(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod": testModule,
+		"account.go": `package main
+
+// +gob:Constructor +gob:immutable +gob:csv +gob:columns +gob:patch
+type Account struct {
+	User     string ` + "`json:\"user\"`" + `
+	Password string ` + "`json:\"password\"`" + ` //+gob:secret
+	Pin      int    ` + "`json:\"pin\"`" + `      //+gob:secret=nogetter
+}
+`,
+		"main.go": `package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func main() {
+	a := NewAccountBuilder().User("joe").Password("hunter2").Pin(1234).Build()
+	fmt.Println(a.String())
+	fmt.Println(a.ToImmutable().String())
+	fmt.Println(a.CSVRecord())
+	fmt.Println(AccountValues(a)...)
+	patch, _ := json.Marshal(NewAccountPatchBuilder().User("ann").Password("secret").Build())
+	fmt.Println(string(patch))
+	fmt.Println(a.ToImmutable().Password() == "hunter2")
+}
+`,
+	}, "account.go")
+
+	assertOutput(t, runTestModule(t, dir),
+		"{User:joe Password:*** Pin:***}",
+		"{user:joe password:*** pin:***}",
+		"[joe *** ***]",
+		"joe *** ***",
+		`{"password":"***","user":"ann"}`,
+		"true",
+	)
+	if generated := readTestFile(t, dir, "account_gob.go"); strings.Contains(generated, ") Pin() int") {
+		t.Errorf("getter of field with suppressed getters is generated:\n%s", generated)
+	}
+}
+
+func TestSecretFieldWithSuppressedGetterCannotHaveGetter(t *testing.T) {
+	dir := writeTestModule(t, map[string]string{
+		"go.mod": testModule,
+		"account.go": `package main
+
+type Account struct { //+gob:Constructor
+	token string //+gob:secret=nogetter +gob:getter
+}
+`,
+	})
+	code, diagnostics := generateTestFile(t, dir, "account.go")
+	if code != ExitAnnotation || !strings.Contains(diagnostics, "contradict each other") {
+		t.Errorf("expected contradicting annotations to be reported, got exit code %d:\n%s", code, diagnostics)
+	}
+}
+
+func TestLogValuerLogsNonZeroOptionalFields(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod": "module t9\n\ngo 1.21\n",
+		"user.go": `package main
+
+type User struct { //+gob:Constructor +gob:slog
+	Name   string
+	Age    int      //+gob:_
+	Nick   string   //+gob:_
+	Admin  bool     //+gob:_
+	Emails []string //+gob:_
+}
+`,
+		"main.go": `package main
+
+import (
+	"log/slog"
+	"os"
+)
+
+func main() {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+	u := NewUserBuilder().Name("joe").Build()
+	u.Age, u.Admin = 3, true
+	logger.Info("user", "user", u)
+}
+`,
+	}, "user.go")
+
+	assertOutput(t, runTestModule(t, dir), "level=INFO msg=user user.Name=joe user.Age=3 user.Admin=true")
+	generated := readTestFile(t, dir, "user_gob.go")
+	for _, check := range []string{"if v.Age != 0 {", `if v.Nick != "" {`, "if v.Admin {", "if v.Emails != nil {"} {
+		if !strings.Contains(generated, check) {
+			t.Errorf("generated LogValue lacks check %q:\n%s", check, generated)
+		}
+	}
+}
+
+func TestLogMarshalerAddsNonZeroOptionalFields(t *testing.T) {
+	for _, marshaler := range []string{"zap", "zerolog"} {
+		t.Run(marshaler, func(t *testing.T) {
+			dir := generateTestModule(t, map[string]string{
+				"go.mod": testModule,
+				"user.go": `package main
+
+type User struct { //+gob:Constructor
+	Name    string
+	Age     int           //+gob:_
+	Admin   bool          //+gob:_
+	Emails  []string      //+gob:_
+	Address struct{ City string } //+gob:_
+}
+`,
+			}, "user.go", "-log-marshaler", marshaler)
+
+			generated := readTestFile(t, dir, "user_gob.go")
+			for _, check := range []string{
+				"if v.Age != 0 {",
+				"if v.Admin {",
+				"if v.Emails != nil {",
+				"if !reflect.ValueOf(&v.Address).Elem().IsZero() {",
+			} {
+				if !strings.Contains(generated, check) {
+					t.Errorf("generated marshaler lacks check %q:\n%s", check, generated)
+				}
+			}
+		})
+	}
+}
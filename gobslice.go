@@ -0,0 +1,107 @@
+package main
+
+import "fmt"
+
+// sliceBuilderName returns name of batch builder of structure, it follows naming scheme and visibility of
+// builder chain types
+func (sf *StructField) sliceBuilderName() string {
+	name := sf.StructName + "_SliceBuilder"
+	if sf.StructFlags.Naming == "camel" {
+		name = sf.StructName + "SliceBuilder"
+	}
+	if sf.StructFlags.BuilderVisibility == PackageLevelVisibility {
+		return lowerFirst(name)
+	}
+	return name
+}
+
+// GenerateSliceBuilder generates batch builder (e.g. NewPersonSliceBuilder()) accumulating structures built
+// with full builder chain and returning them as slice, which reduces boilerplate of test datasets and seed
+// data. Batch builder is not generated for structures holding locks, because slice holds copies of structures.
+func GenerateSliceBuilder(chain []*StructField, fields []*StructField) string {
+	if len(chain) == 0 || !chain[0].StructFlags.SliceBuilder {
+		return ""
+	}
+	for _, sf := range fields {
+		if sf.NoCopy {
+			return ""
+		}
+	}
+	first := chain[0]
+	builderName := first.sliceBuilderName()
+	builderType := builderName + first.TypeArgs
+	return fmt.Sprintf(`
+type %[1]s%[2]s struct {
+	items []%[3]s
+}
+
+func %[4]sSliceBuilder%[2]s() *%[5]s {
+	return &%[5]s{}
+}
+
+// Add builds structure with builder chain started by build function and adds it to slice
+func (b *%[5]s) Add(build func(%[6]s) %[7]s) *%[5]s {
+	b.items = append(b.items, *build(%[4]sBuilder%[8]s()).%[9]s())
+	return b
+}
+
+func (b *%[5]s) %[9]s() []%[3]s {
+	return b.items
+}
+
+`, builderName, first.TypeParams, first.structType(), first.constructorName(), builderType,
+		first.builderFieldStructType(), first.finalizer().builderFieldStructType(), first.TypeArgs,
+		first.StructFlags.BuildName)
+}
+
+// collectorName returns name of collector of structure, it follows naming scheme and visibility of builder
+// chain types
+func (sf *StructField) collectorName() string {
+	name := sf.StructName + "_Collector"
+	if sf.StructFlags.Naming == "camel" {
+		name = sf.StructName + "Collector"
+	}
+	if sf.StructFlags.BuilderVisibility == PackageLevelVisibility {
+		return lowerFirst(name)
+	}
+	return name
+}
+
+// GenerateCollector generates collector (e.g. NewPersonCollector()) gathering structures built in loop, every
+// iteration starts builder chain with Next() and passes its finalizer to Add(), so all required fields are
+// set for every structure. Unlike batch builder, collector returns pointers to built structures.
+func GenerateCollector(chain []*StructField) string {
+	if len(chain) == 0 || !chain[0].StructFlags.Collector {
+		return ""
+	}
+	first := chain[0]
+	collectorName := first.collectorName()
+	collectorType := collectorName + first.TypeArgs
+	return fmt.Sprintf(`
+type %[1]s%[2]s struct {
+	items []*%[3]s
+}
+
+func %[4]sCollector%[2]s() *%[5]s {
+	return &%[5]s{}
+}
+
+// Next starts builder chain of the next structure
+func (c *%[5]s) Next() %[6]s {
+	return %[4]sBuilder%[8]s()
+}
+
+// Add builds structure and adds it to collected structures
+func (c *%[5]s) Add(b %[7]s) *%[5]s {
+	c.items = append(c.items, b.%[9]s())
+	return c
+}
+
+func (c *%[5]s) Collect() []*%[3]s {
+	return c.items
+}
+
+`, collectorName, first.TypeParams, first.structType(), first.constructorName(), collectorType,
+		first.builderFieldStructType(), first.finalizer().builderFieldStructType(), first.TypeArgs,
+		first.StructFlags.BuildName)
+}
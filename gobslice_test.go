@@ -0,0 +1,59 @@
+package main
+
+import "testing"
+
+func TestSliceBuilderAccumulatesBuiltStructs(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod": testModule,
+		"person.go": `package main
+
+type Person struct { //+gob:Constructor +gob:slice
+	Name string
+	Age  int
+}
+`,
+		"main.go": `package main
+
+import "fmt"
+
+func main() {
+	persons := NewPersonSliceBuilder().
+		Add(func(b Person_Builder_Name) Person_Builder_GobFinalizer { return b.Name("a").Age(1) }).
+		Add(func(b Person_Builder_Name) Person_Builder_GobFinalizer { return b.Name("b").Age(2) }).
+		Build()
+	fmt.Println(persons)
+}
+`,
+	}, "person.go")
+
+	assertOutput(t, runTestModule(t, dir), "[{a 1} {b 2}]")
+}
+
+func TestCollectorGathersStructsBuiltInLoop(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod": testModule,
+		"person.go": `package main
+
+type Person struct { //+gob:Constructor +gob:collector
+	Name string
+	Age  int
+}
+`,
+		"main.go": `package main
+
+import "fmt"
+
+func main() {
+	c := NewPersonCollector()
+	for i, name := range []string{"a", "b"} {
+		c.Add(c.Next().Name(name).Age(i))
+	}
+	for _, p := range c.Collect() {
+		fmt.Println(*p)
+	}
+}
+`,
+	}, "person.go")
+
+	assertOutput(t, runTestModule(t, dir), "{a 0}", "{b 1}")
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// columnName returns name of database column of field: name from "db" tag (the same tag is used by sqlx) or
+// lower-cased field name. Empty string is returned for fields that are not stored in database.
+func (sf *StructField) columnName() string {
+	if sf.NoCopy || sf.Lazy != "" || sf.Computed != "" {
+		return ""
+	}
+	if name, _, _ := strings.Cut(reflect.StructTag(sf.Tag).Get("db"), ","); name != "" {
+		if name == "-" {
+			return ""
+		}
+		return name
+	}
+	return strings.ToLower(sf.FieldName)
+}
+
+// helperName returns name of generated helper prefixed with struct name, e.g. "PersonColumnList" or
+// "scanPerson" for package-level structures
+func (sf *StructField) helperName(prefix string, suffix string) string {
+	name := prefix + strings.Title(sf.StructName) + suffix
+	if unicode.IsLower(rune(sf.StructName[0])) || sf.StructFlags.Visibility == PackageLevelVisibility {
+		return lowerFirst(name)
+	}
+	return name
+}
+
+func columnFields(fields []*StructField) []*StructField {
+	result := make([]*StructField, 0, len(fields))
+	for _, sf := range fields {
+		if sf.columnName() != "" {
+			result = append(result, sf)
+		}
+	}
+	return result
+}
+
+// GenerateRowScanner generates constant with comma-separated list of columns and Scan<StructName>() function
+// scanning current row of *sql.Rows into structure through builder chain. Columns must be selected in the
+// same order as they are listed in the constant.
+func GenerateRowScanner(root *StructField, fields []*StructField, chain []*StructField) (string, error) {
+	if !root.StructFlags.Scan {
+		return "", nil
+	}
+	columns := columnFields(fields)
+	required := make(map[*StructField]bool)
+	for _, sf := range chain {
+		required[sf] = true
+		if sf.columnName() == "" {
+			return "", sf.errorf("field %s of struct %s is required, but it is not stored in database column",
+				sf.FieldName, sf.StructName)
+		}
+	}
+	names := make([]string, 0, len(columns))
+	targets := make([]string, 0, len(columns))
+	for _, sf := range columns {
+		names = append(names, sf.columnName())
+		targets = append(targets, "&src"+sf.exportName())
+	}
+	bld := &strings.Builder{}
+	bld.WriteString(fmt.Sprintf(`
+const %s = %q
+
+func %s%s(rows *sql.Rows) (*%s, error) {
+`, root.helperName("", "ColumnList"), strings.Join(names, ", "),
+		root.helperName("Scan", ""), root.TypeParams, root.structType()))
+	for _, sf := range columns {
+		bld.WriteString(fmt.Sprintf("\tvar src%s %s\n", sf.exportName(), sf.FieldTypeText))
+	}
+	bld.WriteString(fmt.Sprintf(`	if err := rows.Scan(%s); err != nil {
+		return nil, err
+	}
+`, strings.Join(targets, ", ")))
+	if len(chain) > 0 {
+		values := make([]string, 0, len(chain))
+		for _, sf := range chain {
+			values = append(values, "src"+sf.exportName())
+		}
+		bld.WriteString(fmt.Sprintf("\tv := %sBuilder%s().%s.%s()\n",
+			root.constructorName(), root.TypeArgs, strings.Join(chainCalls(chain, values), "."), root.StructFlags.BuildName))
+	} else {
+		bld.WriteString(fmt.Sprintf("\tv := &%s{}\n", root.structType()))
+	}
+	for _, sf := range columns {
+		if !required[sf] {
+			bld.WriteString(fmt.Sprintf("\tv.%s = src%s\n", sf.FieldName, sf.exportName()))
+		}
+	}
+	bld.WriteString("\treturn v, nil\n}\n\n")
+	return bld.String(), nil
+}
+
+// GenerateColumnHelpers generates <StructName>Columns() function returning names of database columns and
+// <StructName>Values() function returning values of these columns in the same order, so query builders
+// (e.g. squirrel or sqlx) stay in sync with structure. Values of secret fields are masked.
+func GenerateColumnHelpers(root *StructField, fields []*StructField) string {
+	if !root.StructFlags.Columns {
+		return ""
+	}
+	names := make([]string, 0, len(fields))
+	values := make([]string, 0, len(fields))
+	for _, sf := range columnFields(fields) {
+		names = append(names, strconv.Quote(sf.columnName()))
+		if sf.Secret {
+			values = append(values, strconv.Quote(secretMask))
+		} else {
+			values = append(values, "v."+sf.FieldName)
+		}
+	}
+	return fmt.Sprintf(`
+func %s() []string {
+	return []string{%s}
+}
+
+func %s%s(v *%s) []%s {
+	return []%s{%s}
+}
+
+`, root.helperName("", "Columns"), strings.Join(names, ", "),
+		root.helperName("", "Values"), root.TypeParams, root.structType(), root.StructFlags.AnyStyle,
+		root.StructFlags.AnyStyle, strings.Join(values, ", "))
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// testSQLDriver is database driver of test modules returning single row of columns "name" and "age"
+const testSQLDriver = `package main
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"io"
+)
+
+type testDriver struct{}
+
+func (testDriver) Open(string) (driver.Conn, error) { return testConn{}, nil }
+
+type testConn struct{}
+
+func (testConn) Prepare(string) (driver.Stmt, error) { return testStmt{}, nil }
+func (testConn) Close() error                        { return nil }
+func (testConn) Begin() (driver.Tx, error)           { return nil, io.EOF }
+
+type testStmt struct{}
+
+func (testStmt) Close() error                               { return nil }
+func (testStmt) NumInput() int                              { return -1 }
+func (testStmt) Exec([]driver.Value) (driver.Result, error) { return nil, io.EOF }
+func (testStmt) Query([]driver.Value) (driver.Rows, error)  { return &testRows{}, nil }
+
+type testRows struct{ done bool }
+
+func (*testRows) Columns() []string { return []string{"name", "age"} }
+func (*testRows) Close() error      { return nil }
+func (r *testRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0], dest[1] = "a", int64(7)
+	return nil
+}
+
+func init() {
+	sql.Register("test", testDriver{})
+}
+`
+
+func TestScanRowsThroughBuilder(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod":    testModule,
+		"driver.go": testSQLDriver,
+		"person.go": `package main
+
+type Person struct { //+gob:Constructor +gob:scan
+	Name  string
+	Age   int ` + "`db:\"age\"`" + `
+	Notes string ` + "`db:\"-\"`" + ` //+gob:_
+}
+`,
+		"main.go": `package main
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+func main() {
+	db, _ := sql.Open("test", "")
+	rows, _ := db.Query("select " + PersonColumnList + " from person")
+	defer rows.Close()
+	for rows.Next() {
+		p, err := ScanPerson(rows)
+		fmt.Println(PersonColumnList, p.Name, p.Age, err)
+	}
+}
+`,
+	}, "person.go")
+
+	assertOutput(t, runTestModule(t, dir), "name, age a 7 <nil>")
+}
+
+func TestScannedRequiredFieldsMustBeStoredInColumns(t *testing.T) {
+	dir := writeTestModule(t, map[string]string{
+		"go.mod": testModule,
+		"person.go": `package main
+
+type Person struct { //+gob:Constructor +gob:scan
+	Name  string
+	Notes string ` + "`db:\"-\"`" + `
+}
+`,
+	})
+
+	code, diagnostics := generateTestFile(t, dir, "person.go")
+	if code == 0 || !strings.Contains(diagnostics, "field Notes of struct Person is required, but it is not stored in database column") {
+		t.Errorf("exit code %d:\n%s", code, diagnostics)
+	}
+}
+
+func TestColumnHelpersListStoredFields(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod": testModule,
+		"account.go": `package main
+
+type Account struct { //+gob:Constructor +gob:columns
+	Login    string ` + "`db:\"user_login\"`" + `
+	Password string //+gob:secret
+	Cache    string ` + "`db:\"-\"`" + `
+}
+`,
+		"main.go": `package main
+
+import "fmt"
+
+func main() {
+	a := NewAccountBuilder().Login("a").Password("p").Cache("c").Build()
+	fmt.Println(AccountColumns(), AccountValues(a))
+}
+`,
+	}, "account.go")
+
+	assertOutput(t, runTestModule(t, dir), "[user_login password] [a ***]")
+}
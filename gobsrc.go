@@ -0,0 +1,609 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// fieldSource describes external source of field values (environment variables, maps etc.) that is used
+// to generate additional constructors populating structs from this source
+type fieldSource struct {
+	// suffix of constructor name, e.g. "FromEnv" for NewConfigFromEnv
+	suffix string
+	// parameters of constructor, e.g. "m map[string]any"
+	params func(root *StructField) string
+	// description of a value for error messages, e.g. "environment variable"
+	description string
+	// missing describes absent value for error messages, e.g. "is not set"
+	missing string
+	// key returns name of field value in the source or empty string if field is not populated from the source
+	key func(sf *StructField) string
+	// lookup returns "if" statement header that binds found value, e.g. `s, ok := os.LookupEnv("HOST"); ok`
+	lookup func(key string) string
+	// convert writes code that converts found value and assigns it to target
+	convert func(bld *strings.Builder, sf *StructField, target string, errPrefix string) error
+}
+
+var envSource = fieldSource{
+	suffix: "FromEnv",
+	params: func(root *StructField) string {
+		return ""
+	},
+	description: "environment variable",
+	missing:     "is not set",
+	key: func(sf *StructField) string {
+		return sf.Env
+	},
+	lookup: func(key string) string {
+		return fmt.Sprintf("s, ok := os.LookupEnv(%s); ok", strconv.Quote(key))
+	},
+	convert: convertString,
+}
+
+var mapSource = fieldSource{
+	suffix: "FromMap",
+	params: func(root *StructField) string {
+		return "m map[string]" + root.StructFlags.AnyStyle
+	},
+	description: "key",
+	missing:     "is missing",
+	key: func(sf *StructField) string {
+		if name := sf.tagName("json"); name != "" {
+			return name
+		}
+		return sf.FieldName
+	},
+	lookup: func(key string) string {
+		return fmt.Sprintf("raw, ok := m[%s]; ok", strconv.Quote(key))
+	},
+	convert: convertAny,
+}
+
+var formSource = fieldSource{
+	suffix: "FromValues",
+	params: func(root *StructField) string {
+		return "values url.Values"
+	},
+	description: "form value",
+	missing:     "is missing",
+	key:         formKey,
+	lookup: func(key string) string {
+		return fmt.Sprintf("vals := values[%s]; len(vals) > 0", strconv.Quote(key))
+	},
+	convert: func(bld *strings.Builder, sf *StructField, target string, errPrefix string) error {
+		if sf.FieldTypeText == "[]string" {
+			bld.WriteString(fmt.Sprintf("        %s = vals\n", target))
+			return nil
+		}
+		bld.WriteString("        s := vals[0]\n")
+		return convertString(bld, sf, target, errPrefix)
+	},
+}
+
+func formKey(sf *StructField) string {
+	if name := sf.tagName("form"); name != "" {
+		return name
+	}
+	return sf.FieldName
+}
+
+// GenerateEnvConstructor generates constructor (e.g. NewConfigFromEnv) populating fields annotated with
+// +gob:env from environment variables. Every field of builder chain must be annotated, missing variables
+// of required fields are reported as errors, while optional fields keep zero values.
+func GenerateEnvConstructor(root *StructField, chain []*StructField, optional []*StructField) (string, error) {
+	for _, sf := range append(chain, optional...) {
+		if sf.Env != "" {
+			return generateSourceConstructor(root, chain, optional, &envSource)
+		}
+	}
+	return "", nil
+}
+
+// GenerateMapConstructor generates constructor (e.g. NewPersonFromMap) for structs annotated with +gob:map.
+// Values are looked up by json tag name (or by field name if there is no tag) and numeric values are coerced
+// to the type of the field, missing values of required fields are reported as errors.
+func GenerateMapConstructor(root *StructField, chain []*StructField, optional []*StructField) (string, error) {
+	if !root.StructFlags.FromMap {
+		return "", nil
+	}
+	return generateSourceConstructor(root, chain, optional, &mapSource)
+}
+
+// GenerateFormConstructor generates constructor (e.g. NewSearchRequestFromValues) for structs annotated with
+// +gob:form, so HTTP handlers can populate structs from url.Values (query or form values). Values are
+// looked up by form tag name (or by field name if there is no tag), missing required values are reported
+// as errors.
+func GenerateFormConstructor(root *StructField, chain []*StructField, optional []*StructField) (string, error) {
+	if !root.StructFlags.FromForm {
+		return "", nil
+	}
+	return generateSourceConstructor(root, chain, optional, &formSource)
+}
+
+// GenerateJSONDecoder generates function (e.g. DecodePerson) for structs annotated with +gob:json that strictly
+// decodes JSON payload (unknown fields are not allowed) and verifies that all fields of builder chain are present,
+// reporting missing fields by their json names. Payload is decoded into intermediate struct with exported
+// fields, so unexported fields of the struct are supported as well.
+func GenerateJSONDecoder(root *StructField, chain []*StructField, optional []*StructField) (string, error) {
+	if !root.StructFlags.FromJSON {
+		return "", nil
+	}
+	funcName := "Decode" + strings.Title(root.StructName)
+	if strings.HasPrefix(root.constructorName(), "new") {
+		funcName = "decode" + strings.Title(root.StructName)
+	}
+
+	bld := &strings.Builder{}
+	bld.WriteString(fmt.Sprintf(`
+func %s%s(r io.Reader) (*%s, error) {
+    var payload struct {
+`, funcName, root.TypeParams, root.structType()))
+	for _, sf := range chain {
+		if jsonKey(sf) == "" {
+			return "", sf.errorf("field %s of struct %s is required, but it is excluded from json",
+				sf.FieldName, sf.StructName)
+		}
+	}
+	for _, sf := range append(chain, optional...) {
+		if jsonKey(sf) == "" {
+			continue
+		}
+		bld.WriteString(fmt.Sprintf("        %s *%s `json:%s`\n", sf.exportName(), sf.FieldTypeText,
+			strconv.Quote(jsonKey(sf))))
+	}
+	bld.WriteString(`    }
+    dec := json.NewDecoder(r)
+    dec.DisallowUnknownFields()
+    if err := dec.Decode(&payload); err != nil {
+        return nil, err
+    }
+`)
+	if len(chain) > 0 {
+		bld.WriteString("    missing := make([]string, 0)\n")
+		values := make([]string, 0, len(chain))
+		for _, sf := range chain {
+			bld.WriteString(fmt.Sprintf(`    if payload.%s == nil {
+        missing = append(missing, %s)
+    }
+`, sf.exportName(), strconv.Quote(jsonKey(sf))))
+			values = append(values, "*payload."+sf.exportName())
+		}
+		bld.WriteString(fmt.Sprintf(`    if len(missing) > 0 {
+        return nil, fmt.Errorf("missing required fields: %%s", strings.Join(missing, ", "))
+    }
+    v := %sBuilder%s().%s.%s()
+`, root.constructorName(), root.TypeArgs, strings.Join(chainCalls(chain, values), "."), root.StructFlags.BuildName))
+	} else {
+		bld.WriteString(fmt.Sprintf("    v := &%s{}\n", root.structType()))
+	}
+	for _, sf := range optional {
+		if jsonKey(sf) == "" {
+			continue
+		}
+		bld.WriteString(fmt.Sprintf(`    if payload.%[1]s != nil {
+        v.%[2]s = *payload.%[1]s
+    }
+`, sf.exportName(), sf.FieldName))
+	}
+	root.generateValidationCheck(bld, "nil, err")
+	bld.WriteString(`    return v, nil
+}
+
+`)
+	return bld.String(), nil
+}
+
+// GenerateBindHelper generates Bind<StructName> function decoding request body of web framework context with
+// JSON decoder generated by GenerateJSONDecoder, so handlers cannot skip required fields validation
+func GenerateBindHelper(root *StructField, chain []*StructField, optional []*StructField) (string, error) {
+	if !root.StructFlags.Bind {
+		return "", nil
+	}
+	funcName := "Bind" + strings.Title(root.StructName)
+	decodeName := "Decode" + strings.Title(root.StructName)
+	if strings.HasPrefix(root.constructorName(), "new") {
+		funcName = lowerFirst(funcName)
+		decodeName = lowerFirst(decodeName)
+	}
+	context, body := "c *gin.Context", "c.Request.Body"
+	if root.StructFlags.WebFramework == "echo" {
+		context, body = "c echo.Context", "c.Request().Body"
+	}
+	return fmt.Sprintf(`
+func %s%s(%s) (*%s, error) {
+	return %s%s(%s)
+}
+
+`, funcName, root.TypeParams, context, root.structType(), decodeName, root.TypeArgs, body), nil
+}
+
+// GenerateYAMLUnmarshaler generates UnmarshalYAML method (node-based, for gopkg.in/yaml.v3) that decodes YAML
+// node and verifies that all required fields are present before building structure with builder chain
+func GenerateYAMLUnmarshaler(root *StructField, chain []*StructField, optional []*StructField) (string, error) {
+	if !root.StructFlags.FromYAML {
+		return "", nil
+	}
+	bld := &strings.Builder{}
+	bld.WriteString(fmt.Sprintf(`
+func (v *%s) UnmarshalYAML(node *yaml.Node) error {
+	var payload struct {
+`, root.structType()))
+	for _, sf := range chain {
+		if yamlKey(sf) == "" {
+			return "", sf.errorf("field %s of struct %s is required, but it is excluded from yaml",
+				sf.FieldName, sf.StructName)
+		}
+	}
+	for _, sf := range append(chain, optional...) {
+		if yamlKey(sf) == "" {
+			continue
+		}
+		bld.WriteString(fmt.Sprintf("\t\t%s *%s `yaml:%s`\n", sf.exportName(), sf.FieldTypeText,
+			strconv.Quote(yamlKey(sf))))
+	}
+	bld.WriteString(`	}
+	if err := node.Decode(&payload); err != nil {
+		return err
+	}
+`)
+	if len(chain) > 0 {
+		bld.WriteString("\tmissing := make([]string, 0)\n")
+		values := make([]string, 0, len(chain))
+		for _, sf := range chain {
+			bld.WriteString(fmt.Sprintf(`	if payload.%s == nil {
+		missing = append(missing, %s)
+	}
+`, sf.exportName(), strconv.Quote(yamlKey(sf))))
+			values = append(values, "*payload."+sf.exportName())
+		}
+		bld.WriteString(fmt.Sprintf(`	if len(missing) > 0 {
+		return fmt.Errorf("line %%d: missing required fields: %%s", node.Line, strings.Join(missing, ", "))
+	}
+	built := %sBuilder%s().%s.%s()
+`, root.constructorName(), root.TypeArgs, strings.Join(chainCalls(chain, values), "."), root.StructFlags.BuildName))
+		// fields are copied one by one, because structure may hold locks that must not be copied
+		for _, sf := range append(chain, root.StructFlags.Derived...) {
+			bld.WriteString(fmt.Sprintf("\tv.%[1]s = built.%[1]s\n", sf.FieldName))
+		}
+	}
+	for _, sf := range optional {
+		if yamlKey(sf) == "" {
+			continue
+		}
+		bld.WriteString(fmt.Sprintf(`	if payload.%[1]s != nil {
+		v.%[2]s = *payload.%[1]s
+	}
+`, sf.exportName(), sf.FieldName))
+	}
+	root.generateValidationCheck(bld, "err")
+	bld.WriteString(`	return nil
+}
+
+`)
+	return bld.String(), nil
+}
+
+// GenerateTOMLCodec generates Decode<StructName>TOML function that decodes TOML document and verifies that all
+// required fields are present before building structure with builder chain, and Encode<StructName>TOML function
+// encoding structure with the same keys (including unexported fields) and with masked values of secret fields. Both BurntSushi/toml and
+// pelletier/go-toml/v2 packages have the same Unmarshal and Marshal functions, so generated code works with both.
+func GenerateTOMLCodec(root *StructField, chain []*StructField, optional []*StructField) (string, error) {
+	if !root.StructFlags.TOML {
+		return "", nil
+	}
+	decodeName := "Decode" + strings.Title(root.StructName) + "TOML"
+	encodeName := "Encode" + strings.Title(root.StructName) + "TOML"
+	if strings.HasPrefix(root.constructorName(), "new") {
+		decodeName = lowerFirst(decodeName)
+		encodeName = lowerFirst(encodeName)
+	}
+	for _, sf := range chain {
+		if tomlKey(sf) == "" {
+			return "", sf.errorf("field %s of struct %s is required, but it is excluded from toml",
+				sf.FieldName, sf.StructName)
+		}
+	}
+	fields := make([]*StructField, 0, len(chain)+len(optional))
+	for _, sf := range append(chain, optional...) {
+		if tomlKey(sf) != "" {
+			fields = append(fields, sf)
+		}
+	}
+
+	bld := &strings.Builder{}
+	bld.WriteString(fmt.Sprintf(`
+func %s%s(data []byte) (*%s, error) {
+	var payload struct {
+`, decodeName, root.TypeParams, root.structType()))
+	for _, sf := range fields {
+		bld.WriteString(fmt.Sprintf("\t\t%s *%s `toml:%s`\n", sf.exportName(), sf.FieldTypeText,
+			strconv.Quote(tomlKey(sf))))
+	}
+	bld.WriteString(`	}
+	if err := toml.Unmarshal(data, &payload); err != nil {
+		return nil, err
+	}
+`)
+	if len(chain) > 0 {
+		bld.WriteString("\tmissing := make([]string, 0)\n")
+		values := make([]string, 0, len(chain))
+		for _, sf := range chain {
+			bld.WriteString(fmt.Sprintf(`	if payload.%s == nil {
+		missing = append(missing, %s)
+	}
+`, sf.exportName(), strconv.Quote(tomlKey(sf))))
+			values = append(values, "*payload."+sf.exportName())
+		}
+		bld.WriteString(fmt.Sprintf(`	if len(missing) > 0 {
+		return nil, fmt.Errorf("missing required fields: %%s", strings.Join(missing, ", "))
+	}
+	v := %sBuilder%s().%s.%s()
+`, root.constructorName(), root.TypeArgs, strings.Join(chainCalls(chain, values), "."), root.StructFlags.BuildName))
+	} else {
+		bld.WriteString(fmt.Sprintf("\tv := &%s{}\n", root.structType()))
+	}
+	for _, sf := range optional {
+		if tomlKey(sf) == "" {
+			continue
+		}
+		bld.WriteString(fmt.Sprintf(`	if payload.%[1]s != nil {
+		v.%[2]s = *payload.%[1]s
+	}
+`, sf.exportName(), sf.FieldName))
+	}
+	root.generateValidationCheck(bld, "nil, err")
+	bld.WriteString("\treturn v, nil\n}\n")
+
+	bld.WriteString(fmt.Sprintf(`
+func %s%s(v *%s) ([]byte, error) {
+	payload := struct {
+`, encodeName, root.TypeParams, root.structType()))
+	for _, sf := range fields {
+		fieldType := sf.FieldTypeText
+		if sf.Secret {
+			fieldType = "string"
+		}
+		bld.WriteString(fmt.Sprintf("\t\t%s %s `toml:%s`\n", sf.exportName(), fieldType,
+			strconv.Quote(tomlKey(sf))))
+	}
+	bld.WriteString("\t}{\n")
+	for _, sf := range fields {
+		if sf.Secret {
+			bld.WriteString(fmt.Sprintf("\t\t%s: %q,\n", sf.exportName(), secretMask))
+			continue
+		}
+		bld.WriteString(fmt.Sprintf("\t\t%s: v.%s,\n", sf.exportName(), sf.FieldName))
+	}
+	bld.WriteString(`	}
+	return toml.Marshal(payload)
+}
+
+`)
+	return bld.String(), nil
+}
+
+// tomlKey returns name of field in TOML document: name from "toml" tag or field name, or empty string if
+// field is excluded from TOML
+func tomlKey(sf *StructField) string {
+	name, _, _ := strings.Cut(reflect.StructTag(sf.Tag).Get("toml"), ",")
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return sf.FieldName
+	}
+	return name
+}
+
+// yamlKey returns name of field in YAML document: name from "yaml" tag or lower-cased field name (the same
+// as gopkg.in/yaml.v3 does), or empty string if field is excluded from YAML
+func yamlKey(sf *StructField) string {
+	name, _, _ := strings.Cut(reflect.StructTag(sf.Tag).Get("yaml"), ",")
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return strings.ToLower(sf.FieldName)
+	}
+	return name
+}
+
+func jsonKey(sf *StructField) string {
+	if sf.Tag != "" && reflect.StructTag(sf.Tag).Get("json") == "-" {
+		return ""
+	}
+	if name := sf.tagName("json"); name != "" {
+		return name
+	}
+	return sf.FieldName
+}
+
+func generateSourceConstructor(
+	root *StructField,
+	chain []*StructField,
+	optional []*StructField,
+	src *fieldSource,
+) (string, error) {
+	bld := &strings.Builder{}
+	bld.WriteString(fmt.Sprintf(`
+func %s%s%s(%s) (*%s, error) {
+`, root.constructorName(), src.suffix, root.TypeParams, src.params(root), root.structType()))
+	values := make([]string, 0, len(chain))
+	for _, sf := range chain {
+		key := src.key(sf)
+		if key == "" {
+			return "", sf.errorf("field %s of struct %s is required, but it cannot be populated from %s",
+				sf.FieldName, sf.StructName, src.description)
+		}
+		varName := "src" + sf.exportName()
+		bld.WriteString(fmt.Sprintf("    var %s %s\n", varName, sf.FieldTypeText))
+		if err := generateSourceField(bld, sf, varName, src, true); err != nil {
+			return "", err
+		}
+		values = append(values, varName)
+	}
+	if len(chain) > 0 {
+		bld.WriteString(fmt.Sprintf("    v := %sBuilder%s().%s.%s()\n",
+			root.constructorName(), root.TypeArgs, strings.Join(chainCalls(chain, values), "."), root.StructFlags.BuildName))
+	} else {
+		bld.WriteString(fmt.Sprintf("    v := &%s{}\n", root.structType()))
+	}
+	for _, sf := range optional {
+		if src.key(sf) == "" {
+			continue
+		}
+		if err := generateSourceField(bld, sf, "v."+sf.FieldName, src, false); err != nil {
+			return "", err
+		}
+	}
+	bld.WriteString(`    return v, nil
+}
+
+`)
+	return bld.String(), nil
+}
+
+func generateSourceField(bld *strings.Builder, sf *StructField, target string, src *fieldSource, required bool) error {
+	key := src.key(sf)
+	errPrefix := fmt.Sprintf("%s %s", src.description, key)
+	bld.WriteString(fmt.Sprintf("    if %s {\n", src.lookup(key)))
+	if err := src.convert(bld, sf, target, errPrefix); err != nil {
+		return err
+	}
+	if required {
+		bld.WriteString(fmt.Sprintf(`    } else {
+        return nil, fmt.Errorf(%s)
+    }
+`, strconv.Quote(errPrefix+" "+src.missing)))
+	} else {
+		bld.WriteString("    }\n")
+	}
+	return nil
+}
+
+// convertString writes code parsing string value bound to "s" variable
+func convertString(bld *strings.Builder, sf *StructField, target string, errPrefix string) error {
+	var parse string
+	var conversion string
+	switch sf.FieldTypeText {
+	case "string":
+		conversion = "s"
+	case "bool":
+		parse, conversion = "strconv.ParseBool(s)", "parsed"
+	case "int", "int8", "int16", "int32", "int64":
+		parse = fmt.Sprintf("strconv.ParseInt(s, 10, %s)", bitSize(sf.FieldTypeText, "int"))
+		conversion = sf.FieldTypeText + "(parsed)"
+	case "uint", "uint8", "uint16", "uint32", "uint64":
+		parse = fmt.Sprintf("strconv.ParseUint(s, 10, %s)", bitSize(sf.FieldTypeText, "uint"))
+		conversion = sf.FieldTypeText + "(parsed)"
+	case "float32":
+		parse, conversion = "strconv.ParseFloat(s, 32)", "float32(parsed)"
+	case "float64":
+		parse, conversion = "strconv.ParseFloat(s, 64)", "parsed"
+	case "time.Duration":
+		parse, conversion = "time.ParseDuration(s)", "parsed"
+	default:
+		return sf.errorf("field %s of struct %s has type %s that cannot be parsed from string",
+			sf.FieldName, sf.StructName, sf.FieldTypeText)
+	}
+	if parse != "" {
+		bld.WriteString(fmt.Sprintf(`        parsed, err := %s
+        if err != nil {
+            return nil, fmt.Errorf(%s, err)
+        }
+`, parse, strconv.Quote(errPrefix+": %w")))
+	}
+	bld.WriteString(fmt.Sprintf("        %s = %s\n", target, conversion))
+	return nil
+}
+
+// convertAny writes code coercing value of any type bound to "raw" variable
+func convertAny(bld *strings.Builder, sf *StructField, target string, errPrefix string) error {
+	unexpectedType := fmt.Sprintf(`return nil, fmt.Errorf(%s, raw)`, strconv.Quote(errPrefix+": unexpected type %T"))
+	switch sf.FieldTypeText {
+	case "int", "int8", "int16", "int32", "int64",
+		"uint", "uint8", "uint16", "uint32", "uint64",
+		"float32", "float64":
+		bld.WriteString(fmt.Sprintf(`        rv := reflect.ValueOf(raw)
+        switch {
+        case rv.CanInt():
+            %[1]s = %[2]s(rv.Int())
+        case rv.CanUint():
+            %[1]s = %[2]s(rv.Uint())
+        case rv.CanFloat():
+            %[1]s = %[2]s(rv.Float())
+        case rv.Kind() == reflect.String:
+            parsed, err := strconv.ParseFloat(rv.String(), 64)
+            if err != nil {
+                return nil, fmt.Errorf(%[3]s, err)
+            }
+            %[1]s = %[2]s(parsed)
+        default:
+            %[4]s
+        }
+`, target, sf.FieldTypeText, strconv.Quote(errPrefix+": %w"), unexpectedType))
+	case "bool":
+		bld.WriteString(fmt.Sprintf(`        switch x := raw.(type) {
+        case bool:
+            %[1]s = x
+        case string:
+            parsed, err := strconv.ParseBool(x)
+            if err != nil {
+                return nil, fmt.Errorf(%[2]s, err)
+            }
+            %[1]s = parsed
+        default:
+            %[3]s
+        }
+`, target, strconv.Quote(errPrefix+": %w"), unexpectedType))
+	case "string":
+		bld.WriteString(fmt.Sprintf(`        switch x := raw.(type) {
+        case string:
+            %[1]s = x
+        case fmt.Stringer:
+            %[1]s = x.String()
+        default:
+            %[2]s
+        }
+`, target, unexpectedType))
+	default:
+		bld.WriteString(fmt.Sprintf(`        x, ok := raw.(%s)
+        if !ok {
+            %s
+        }
+        %s = x
+`, sf.FieldTypeText, unexpectedType, target))
+	}
+	return nil
+}
+
+func bitSize(typeName string, prefix string) string {
+	if size := strings.TrimPrefix(typeName, prefix); size != "" {
+		return size
+	}
+	return "0"
+}
+
+// tagName returns name specified by struct tag (e.g. "first_name" for `json:"first_name,omitempty"`) or
+// empty string if tag is not specified
+func (sf *StructField) tagName(key string) string {
+	name, _, _ := strings.Cut(reflect.StructTag(sf.Tag).Get(key), ",")
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+// optionalByTags reports whether field is optional according to "omitempty" option of its json tag or
+// its pointer type
+func (sf *StructField) optionalByTags() bool {
+	_, options, _ := strings.Cut(reflect.StructTag(sf.Tag).Get("json"), ",")
+	for _, option := range strings.Split(options, ",") {
+		if option == "omitempty" {
+			return true
+		}
+	}
+	return strings.HasPrefix(sf.FieldTypeText, "*")
+}
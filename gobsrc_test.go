@@ -0,0 +1,363 @@
+package main
+
+import "testing"
+
+func TestEnvConstructorReadsVariables(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod": testModule,
+		"config.go": `package main
+
+import "time"
+
+type Config struct { //+gob:Constructor
+	Host    string        //+gob:env=T9_HOST
+	Port    int           //+gob:env=T9_PORT
+	Debug   bool          //+gob:env=T9_DEBUG
+	Ratio   float64       //+gob:env=T9_RATIO
+	Timeout time.Duration //+gob:env=T9_TIMEOUT
+	Nick    string        //+gob:_ +gob:env=T9_NICK
+}
+`,
+		"main.go": `package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	for name, value := range map[string]string{"T9_HOST": "h", "T9_PORT": "80", "T9_DEBUG": "true",
+		"T9_RATIO": "0.5", "T9_TIMEOUT": "2s"} {
+		os.Setenv(name, value)
+	}
+	c, err := NewConfigFromEnv()
+	fmt.Println(*c, err)
+	os.Setenv("T9_PORT", "x")
+	_, err = NewConfigFromEnv()
+	fmt.Println(err != nil)
+	os.Unsetenv("T9_HOST")
+	_, err = NewConfigFromEnv()
+	fmt.Println(err)
+}
+`,
+	}, "config.go")
+
+	assertOutput(t, runTestModule(t, dir), "{h 80 true 0.5 2s } <nil>", "true",
+		"environment variable T9_HOST is not set")
+}
+
+func TestMapConstructorCoercesValues(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod": testModule,
+		"event.go": `package main
+
+type Event struct { //+gob:Constructor +gob:map
+	Name   string ` + "`json:\"name\"`" + `
+	Count  int
+	Active bool    ` + "`json:\"active\"`" + `
+	Score  float64 ` + "`json:\"score\"`" + `
+	Note   string  ` + "`json:\"note\"`" + ` //+gob:_
+}
+`,
+		"main.go": `package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func main() {
+	var m map[string]any
+	_ = json.Unmarshal([]byte(` + "`" + `{"name": "a", "Count": 2, "active": true, "score": 1.5}` + "`" + `), &m)
+	e, err := NewEventFromMap(m)
+	fmt.Println(*e, err)
+	_, err = NewEventFromMap(map[string]any{"name": "a"})
+	fmt.Println(err != nil)
+	_, err = NewEventFromMap(map[string]any{"name": 1, "Count": 2, "active": true, "score": 1})
+	fmt.Println(err != nil)
+}
+`,
+	}, "event.go")
+
+	assertOutput(t, runTestModule(t, dir), "{a 2 true 1.5 } <nil>", "true", "true")
+}
+
+func TestFormConstructorParsesValues(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod": testModule,
+		"search.go": `package main
+
+type Search struct { //+gob:Constructor +gob:form
+	Query string   ` + "`form:\"q\"`" + `
+	Page  int      ` + "`form:\"page\"`" + `
+	Tags  []string ` + "`form:\"tag\"`" + `
+	Exact bool     //+gob:_
+}
+`,
+		"main.go": `package main
+
+import (
+	"fmt"
+	"net/url"
+)
+
+func main() {
+	values, _ := url.ParseQuery("q=go&page=2&tag=a&tag=b&Exact=true")
+	s, err := NewSearchFromValues(values)
+	fmt.Println(*s, err)
+	values.Del("page")
+	_, err = NewSearchFromValues(values)
+	fmt.Println(err != nil)
+	values.Set("page", "x")
+	_, err = NewSearchFromValues(values)
+	fmt.Println(err != nil)
+}
+`,
+	}, "search.go")
+
+	assertOutput(t, runTestModule(t, dir), "{go 2 [a b] true} <nil>", "true", "true")
+}
+
+func TestJSONDecoderVerifiesRequiredFields(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod": testModule,
+		"user.go": `package main
+
+type User struct { //+gob:Constructor +gob:json
+	Name  string ` + "`json:\"name\"`" + `
+	email string
+	Age   int ` + "`json:\"age\"`" + ` //+gob:_
+}
+`,
+		"main.go": `package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+func main() {
+	u, err := DecodeUser(strings.NewReader(` + "`" + `{"name": "a", "email": "e", "age": 3}` + "`" + `))
+	fmt.Println(*u, err)
+	for _, payload := range []string{` + "`" + `{"age": 1}` + "`" + `, ` + "`" + `{"name": "a", "email": "e", "x": 1}` + "`" + `} {
+		_, err = DecodeUser(strings.NewReader(payload))
+		fmt.Println(err)
+	}
+}
+`,
+	}, "user.go")
+
+	assertOutput(t, runTestModule(t, dir), "{a e 3} <nil>", "missing required fields: name, email",
+		`json: unknown field "x"`)
+}
+
+// testYAMLModule is go.mod of test module replacing gopkg.in/yaml.v3 with fake package, which decodes values of
+// node into fields of structure by their yaml tags
+const testYAMLModule = testModule + `
+require gopkg.in/yaml.v3 v3.0.0
+
+replace gopkg.in/yaml.v3 => ./yaml
+`
+
+const testYAMLPackage = `package yaml
+
+import "reflect"
+
+type Node struct {
+	Line   int
+	Values map[string]any
+}
+
+func (n *Node) Decode(v any) error {
+	rv := reflect.ValueOf(v).Elem()
+	for i := 0; i < rv.NumField(); i++ {
+		if value, ok := n.Values[rv.Type().Field(i).Tag.Get("yaml")]; ok {
+			field := reflect.New(rv.Field(i).Type().Elem())
+			field.Elem().Set(reflect.ValueOf(value))
+			rv.Field(i).Set(field)
+		}
+	}
+	return nil
+}
+`
+
+func TestYAMLUnmarshalerVerifiesRequiredFields(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod":       testYAMLModule,
+		"yaml/go.mod":  "module gopkg.in/yaml.v3\n\ngo 1.18\n",
+		"yaml/yaml.go": testYAMLPackage,
+		"config.go": `package main
+
+type Config struct { //+gob:Constructor +gob:yaml
+	Host string ` + "`yaml:\"server_host\"`" + `
+	Port int
+	Nick string //+gob:_
+}
+`,
+		"main.go": `package main
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+func main() {
+	var c Config
+	err := c.UnmarshalYAML(&yaml.Node{Values: map[string]any{"server_host": "h", "port": 80, "nick": "n"}})
+	fmt.Println(c, err)
+	err = c.UnmarshalYAML(&yaml.Node{Line: 3, Values: map[string]any{"nick": "n"}})
+	fmt.Println(err)
+}
+`,
+	}, "config.go")
+
+	assertOutput(t, runTestModule(t, dir), "{h 80 n} <nil>", "line 3: missing required fields: server_host, port")
+}
+
+// testTOMLPackage is fake TOML package supporting string and int values only
+const testTOMLPackage = `package toml
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+func Unmarshal(data []byte, v any) error {
+	rv := reflect.ValueOf(v).Elem()
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		key, text, _ := strings.Cut(line, " = ")
+		for i := 0; i < rv.NumField(); i++ {
+			if rv.Type().Field(i).Tag.Get("toml") != key {
+				continue
+			}
+			field := reflect.New(rv.Field(i).Type().Elem())
+			if s, err := strconv.Unquote(text); err == nil {
+				field.Elem().SetString(s)
+			} else if n, err := strconv.Atoi(text); err == nil {
+				field.Elem().SetInt(int64(n))
+			} else {
+				return fmt.Errorf("invalid value of %s", key)
+			}
+			rv.Field(i).Set(field)
+		}
+	}
+	return nil
+}
+
+func Marshal(v any) ([]byte, error) {
+	bld := &strings.Builder{}
+	rv := reflect.ValueOf(v)
+	for i := 0; i < rv.NumField(); i++ {
+		fmt.Fprintf(bld, "%s = %#v\n", rv.Type().Field(i).Tag.Get("toml"), rv.Field(i).Interface())
+	}
+	return []byte(bld.String()), nil
+}
+`
+
+func TestTOMLCodecVerifiesRequiredFields(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod": testModule + `
+require github.com/pelletier/go-toml/v2 v2.0.0
+
+replace github.com/pelletier/go-toml/v2 => ./toml
+`,
+		"toml/go.mod":  "module github.com/pelletier/go-toml/v2\n\ngo 1.18\n",
+		"toml/toml.go": testTOMLPackage,
+		"config.go": `package main
+
+type Config struct { //+gob:Constructor +gob:toml
+	Host     string ` + "`toml:\"server_host\"`" + `
+	port     int
+	Password string //+gob:secret
+	Nick     string //+gob:_
+}
+`,
+		"main.go": `package main
+
+import "fmt"
+
+func main() {
+	data, _ := EncodeConfigTOML(NewConfigBuilder().Host("h").Port(80).Password("p").Build())
+	fmt.Print(string(data))
+	c, err := DecodeConfigTOML([]byte("server_host = \"h\"\nport = 80\nPassword = \"p\"\nNick = \"n\""))
+	fmt.Println(c.Host, c.port, c.Password, c.Nick, err)
+	_, err = DecodeConfigTOML([]byte("Nick = \"n\""))
+	fmt.Println(err)
+}
+`,
+	}, "config.go", "-toml", "pelletier")
+
+	assertOutput(t, runTestModule(t, dir), `server_host = "h"`, "port = 80", `Password = "***"`, `Nick = ""`,
+		"h 80 p n <nil>", "missing required fields: server_host, port, Password")
+}
+
+func TestBindHelperDecodesRequestBody(t *testing.T) {
+	for _, framework := range []struct {
+		name    string
+		module  string
+		version string
+		pkg     string
+		context string
+	}{
+		{
+			name:    "gin",
+			module:  "github.com/gin-gonic/gin",
+			version: "v1.0.0",
+			pkg:     "package gin\n\nimport \"net/http\"\n\ntype Context struct {\n\tRequest *http.Request\n}\n",
+			context: "&gin.Context{Request: r}",
+		},
+		{
+			name:    "echo",
+			module:  "github.com/labstack/echo/v4",
+			version: "v4.0.0",
+			pkg:     "package echo\n\nimport \"net/http\"\n\ntype Context interface {\n\tRequest() *http.Request\n}\n",
+			context: "echo.Context(context{r})",
+		},
+	} {
+		t.Run(framework.name, func(t *testing.T) {
+			dir := generateTestModule(t, map[string]string{
+				"go.mod": testModule + "\nrequire " + framework.module + " " + framework.version + "\n\nreplace " +
+					framework.module + " => ./web\n",
+				"web/go.mod": "module " + framework.module + "\n\ngo 1.18\n",
+				"web/web.go": framework.pkg,
+				"request.go": `package main
+
+type CreateUserRequest struct { //+gob:Constructor +gob:bind
+	Name string ` + "`json:\"name\"`" + `
+}
+`,
+				"main.go": `package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"` + framework.module + `"
+)
+
+type context struct {
+	r *http.Request
+}
+
+func (c context) Request() *http.Request {
+	return c.r
+}
+
+func main() {
+	for _, body := range []string{` + "`" + `{"name": "a"}` + "`, `{}`" + `} {
+		r, _ := http.NewRequest("POST", "/", strings.NewReader(body))
+		u, err := BindCreateUserRequest(` + framework.context + `)
+		fmt.Println(u, err)
+	}
+}
+`,
+			}, "request.go", "-web-framework", framework.name)
+
+			assertOutput(t, runTestModule(t, dir), "&{a} <nil>", "<nil> missing required fields: name")
+		})
+	}
+}
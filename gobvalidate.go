@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Validation is check of field value, e.g. "+gob:validate=len(arg) > 0, "name must not be empty"". Expression
+// refers to value of field as arg, Message is quoted message of error returned when expression is false.
+type Validation struct {
+	Expr    string
+	Message string
+}
+
+// validationRegexp splits value of +gob:validate annotation into expression and quoted message
+var validationRegexp = regexp.MustCompile(`^(.+?),\s*("(?:[^"\\]|\\.)*")$`)
+
+// fieldValidations returns validations of field. Like expression of computed field, validation takes the rest
+// of the comment line, so field with several validations has them on separate lines of its doc comment.
+func (sp *StructParser) fieldValidations(field *ast.Field, name string) ([]Validation, error) {
+	matches := sp.flagValidateRegexp.FindAllStringSubmatch(sp.nameComment(field, name), -1)
+	validations := make([]Validation, 0, len(matches))
+	for _, match := range matches {
+		value := strings.TrimSpace(match[1])
+		parts := validationRegexp.FindStringSubmatch(value)
+		if parts == nil {
+			return nil, fmt.Errorf("+gob:validate=%s must be expression followed by quoted message, "+
+				"e.g. +gob:validate=len(arg) > 0, \"must not be empty\"", value)
+		}
+		if _, err := parser.ParseExpr(parts[1]); err != nil {
+			return nil, fmt.Errorf("+gob:validate=%s has invalid expression: %v", value, err)
+		}
+		if _, err := strconv.Unquote(parts[2]); err != nil {
+			return nil, fmt.Errorf("+gob:validate=%s has invalid message: %v", value, err)
+		}
+		validations = append(validations, Validation{Expr: strings.TrimSpace(parts[1]), Message: parts[2]})
+	}
+	return validations, nil
+}
+
+// generateBuildEFunction generates BuildE() function of finalizer, it builds structure and returns validation
+// error (see GenerateValidationError) instead of structure if any check fails
+func (sf *StructField) generateBuildEFunction(bld *strings.Builder) {
+	if len(sf.StructFlags.Validated) == 0 {
+		return
+	}
+	bld.WriteString(fmt.Sprintf(`
+// %[2]sE builds %[4]s and checks values of its fields, *%[5]s is returned if any check fails
+func (b %[1]s) %[2]sE() (*%[3]s, error) {
+    v := b.%[2]s()
+`, sf.builderFieldStructType(), sf.StructFlags.BuildName, sf.structType(), sf.StructName, sf.validationErrorName()))
+	sf.generateValidationCheck(bld, "nil, err")
+	bld.WriteString(`    return v, nil
+}
+
+`)
+}
+
+// validationErrorName returns name of validation error of structure (e.g. PersonValidationError), it is
+// package-level for structures with package-level constructors
+func (sf *StructField) validationErrorName() string {
+	if strings.HasPrefix(sf.constructorName(), "new") {
+		return lowerFirst(sf.StructName) + "ValidationError"
+	}
+	return strings.Title(sf.StructName) + "ValidationError"
+}
+
+// fieldErrorName returns name of failed validation of field of structure (e.g. PersonFieldError)
+func (sf *StructField) fieldErrorName() string {
+	if strings.HasPrefix(sf.constructorName(), "new") {
+		return lowerFirst(sf.StructName) + "FieldError"
+	}
+	return strings.Title(sf.StructName) + "FieldError"
+}
+
+// generateValidationCheck writes code validating structure v and returning failure with error err
+func (sf *StructField) generateValidationCheck(bld *strings.Builder, failure string) {
+	if len(sf.StructFlags.Validated) == 0 {
+		return
+	}
+	bld.WriteString(fmt.Sprintf(`    if err := validate%s%s(v); err != nil {
+        return %s
+    }
+`, strings.Title(sf.StructName), sf.TypeArgs, failure))
+}
+
+// GenerateValidationError generates validation error of structure (e.g. PersonValidationError) aggregating all
+// failed validations of its fields, and function checking values of fields that returns it. Failed validations
+// (e.g. PersonFieldError) hold field name, rule and message, and are returned by Unwrap() method, so callers can
+// react to specific fields with errors.As().
+func GenerateValidationError(root *StructField) string {
+	if len(root.StructFlags.Validated) == 0 {
+		return ""
+	}
+	errorName := root.validationErrorName()
+	fieldErrorName := root.fieldErrorName()
+	bld := &strings.Builder{}
+	bld.WriteString(fmt.Sprintf(`
+// %[2]s is failed validation of field of %[3]s
+type %[2]s struct {
+	Field   string
+	Rule    string
+	Message string
+}
+
+func (e *%[2]s) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// %[1]s holds all failed validations of fields of %[3]s
+type %[1]s struct {
+	Errors []*%[2]s
+}
+
+func (e *%[1]s) Error() string {
+	messages := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (e *%[1]s) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+func validate%[4]s%[5]s(v *%[6]s) error {
+	verr := &%[1]s{}
+`, errorName, fieldErrorName, root.StructName, strings.Title(root.StructName), root.TypeParams, root.structType()))
+	for _, validated := range root.StructFlags.Validated {
+		for _, validation := range validated.Validations {
+			bld.WriteString(fmt.Sprintf(`	if arg := v.%s; !(%s) {
+		verr.Errors = append(verr.Errors, &%s{Field: %s, Rule: %s, Message: %s})
+	}
+`, validated.FieldName, validation.Expr, fieldErrorName, strconv.Quote(validated.FieldName),
+				strconv.Quote(validation.Expr), validation.Message))
+		}
+	}
+	bld.WriteString(`	if len(verr.Errors) > 0 {
+		return verr
+	}
+	return nil
+}
+
+`)
+	return bld.String()
+}
@@ -0,0 +1,67 @@
+package main
+
+import "testing"
+
+func TestBuildEValidatesFields(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod": testModule,
+		"person.go": `package main
+
+type Person struct { //+gob:Constructor
+	Name string //+gob:validate=len(arg) > 0, "name must not be empty"
+	// +gob:validate=arg >= 0, "age must not be negative"
+	// +gob:validate=arg < 150, "age is too large"
+	Age int
+}
+`,
+		"main.go": `package main
+
+import "fmt"
+
+func main() {
+	p, err := NewPersonBuilder().Name("a").Age(1).BuildE()
+	fmt.Println(*p, err)
+	_, err = NewPersonBuilder().Name("").Age(200).BuildE()
+	fmt.Println(err)
+}
+`,
+	}, "person.go")
+
+	assertOutput(t, runTestModule(t, dir), "{a 1} <nil>", "Name: name must not be empty; Age: age is too large")
+}
+
+func TestValidationErrorHoldsFailedFields(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod": testModule,
+		"person.go": `package main
+
+type Person struct { //+gob:Constructor +gob:json
+	Name string ` + "`json:\"name\"`" + ` //+gob:validate=len(arg) > 0, "name must not be empty"
+	Age  int    ` + "`json:\"age\"`" + `  //+gob:validate=arg >= 0, "age must not be negative"
+}
+`,
+		"main.go": `package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+func main() {
+	_, err := DecodePerson(strings.NewReader(` + "`" + `{"name": "", "age": -1}` + "`" + `))
+	var verr *PersonValidationError
+	fmt.Println(errors.As(err, &verr), len(verr.Errors))
+	var ferr *PersonFieldError
+	if errors.As(err, &ferr) {
+		fmt.Println(ferr.Field, ferr.Rule, ferr.Message)
+	}
+	_, err = NewPersonBuilder().Name("a").Age(-1).BuildE()
+	fmt.Println(err)
+}
+`,
+	}, "person.go")
+
+	assertOutput(t, runTestModule(t, dir), "true 2", "Name len(arg) > 0 name must not be empty",
+		"Age: age must not be negative")
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// viewName returns name of view structure (e.g. PersonView), it is package-level for structures with
+// package-level constructors
+func (sf *StructField) viewName() string {
+	if strings.HasPrefix(sf.constructorName(), "new") {
+		return lowerFirst(sf.StructName) + "View"
+	}
+	return strings.Title(sf.StructName) + "View"
+}
+
+// GenerateView generates view structure (e.g. PersonView) containing only fields listed in +gob:view=<fields>
+// annotation and ToView() method converting structure into its view, so safe subset of structure can be
+// exposed (e.g. in API responses). Fields of view keep names, types and tags of fields of structure.
+func GenerateView(root *StructField, fields []*StructField) (string, error) {
+	if len(root.StructFlags.View) == 0 {
+		return "", nil
+	}
+	byName := make(map[string]*StructField)
+	for _, sf := range fields {
+		byName[sf.FieldName] = sf
+	}
+	viewed := make([]*StructField, 0, len(root.StructFlags.View))
+	for _, name := range root.StructFlags.View {
+		sf, found := byName[name]
+		if !found {
+			return "", fmt.Errorf("field %s of +gob:view annotation is not found in struct %s", name, root.StructName)
+		}
+		if sf.NoCopy {
+			return "", sf.errorf("field %s of struct %s holds lock and cannot be part of view", name, root.StructName)
+		}
+		viewed = append(viewed, sf)
+	}
+	viewName := root.viewName()
+	bld := &strings.Builder{}
+	bld.WriteString(fmt.Sprintf(`
+// %s is view of %s containing only some of its fields
+type %s%s struct {
+`, viewName, root.StructName, viewName, root.TypeParams))
+	for _, sf := range viewed {
+		if sf.Tag != "" {
+			bld.WriteString(fmt.Sprintf("\t%s %s %s\n", sf.FieldName, sf.FieldTypeText, viewTag(sf.Tag)))
+		} else {
+			bld.WriteString(fmt.Sprintf("\t%s %s\n", sf.FieldName, sf.FieldTypeText))
+		}
+	}
+	bld.WriteString(fmt.Sprintf(`}
+
+func (v *%s) ToView() %s%s {
+	return %s%s{
+`, root.structType(), viewName, root.TypeArgs, viewName, root.TypeArgs))
+	for _, sf := range viewed {
+		bld.WriteString(fmt.Sprintf("\t\t%s: v.%s,\n", sf.FieldName, sf.FieldName))
+	}
+	bld.WriteString("\t}\n}\n\n")
+	return bld.String(), nil
+}
+
+// viewTag returns struct tag literal, raw string literal is used unless tag contains backquote
+func viewTag(tag string) string {
+	if strings.Contains(tag, "`") {
+		return strconv.Quote(tag)
+	}
+	return "`" + tag + "`"
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestViewContainsListedFields(t *testing.T) {
+	files := map[string]string{
+		"go.mod": testModule,
+		"user.go": `package main
+
+type User struct { //+gob:Constructor +gob:view=Name,Email
+	Name     string ` + "`json:\"name\"`" + `
+	Email    string ` + "`json:\"email\"`" + `
+	Password string ` + "`json:\"password\"`" + `
+}
+`,
+		"main.go": `package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func main() {
+	view := NewUserBuilder().Name("a").Email("e").Password("p").Build().ToView()
+	data, _ := json.Marshal(view)
+	fmt.Println(string(data))
+}
+`,
+	}
+	dir := generateTestModule(t, files, "user.go")
+	assertOutput(t, runTestModule(t, dir), `{"name":"a","email":"e"}`)
+
+	dir = writeTestModule(t, withTestFile(files, "user.go", strings.Replace(files["user.go"], "Name,Email", "Name,Phone", 1)))
+	code, diagnostics := generateTestFile(t, dir, "user.go")
+	if code == 0 || !strings.Contains(diagnostics, "field Phone of +gob:view annotation is not found in struct User") {
+		t.Errorf("exit code %d:\n%s", code, diagnostics)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strconv"
+	"strings"
+)
+
+// minLangVersion is the oldest minor version of Go accepted by lang flag, generated code relies on
+// reflect.Value.IsZero() added in Go 1.13
+const minLangVersion = 13
+
+// parseLangVersion parses value of lang flag (e.g. "go1.17") and returns minor version of Go
+func parseLangVersion(lang string) (int, error) {
+	minor, err := strconv.Atoi(strings.TrimPrefix(lang, "go1."))
+	if !strings.HasPrefix(lang, "go1.") || err != nil || minor < 0 {
+		return 0, fmt.Errorf("\"lang\" flag must be Go version, e.g. go1.17")
+	}
+	if minor < minLangVersion {
+		return 0, fmt.Errorf("\"lang\" flag must be go1.%d or newer", minLangVersion)
+	}
+	return minor, nil
+}
+
+// langAtLeast reports whether generated code may use features of Go version 1.<minor>, all features are
+// allowed when lang flag is not passed
+func (o CommandLineOptions) langAtLeast(minor int) bool {
+	return o.Lang == 0 || o.Lang >= minor
+}
+
+// CheckInputLang returns error positioned at the first declaration of input file requiring newer version of Go
+// than the one passed with lang flag, so code generated for it would not compile anyway
+func CheckInputLang(opts CommandLineOptions, astFile *ast.File) error {
+	if opts.langAtLeast(18) {
+		return nil
+	}
+	var pos token.Pos
+	ast.Inspect(astFile, func(n ast.Node) bool {
+		if pos.IsValid() {
+			return false
+		}
+		switch t := n.(type) {
+		case *ast.TypeSpec:
+			if t.TypeParams != nil {
+				pos = t.TypeParams.Pos()
+			}
+		case *ast.FuncType:
+			if t.TypeParams != nil {
+				pos = t.TypeParams.Pos()
+			}
+		}
+		return true
+	})
+	if pos.IsValid() {
+		return &PositionError{Pos: pos,
+			Err: fmt.Errorf("type parameters require go1.18, but \"lang\" flag is go1.%d", opts.Lang)}
+	}
+	return nil
+}
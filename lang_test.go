@@ -0,0 +1,51 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseLangVersion(t *testing.T) {
+	for lang, expected := range map[string]int{"go1.17": 17, "go1.13": 13, "go1.22": 22} {
+		if minor, err := parseLangVersion(lang); err != nil || minor != expected {
+			t.Errorf("%s: expected %d, got %d (%v)", lang, expected, minor, err)
+		}
+	}
+	for _, lang := range []string{"1.17", "go1.x", "go1.12", "go2.0"} {
+		if _, err := parseLangVersion(lang); err == nil {
+			t.Errorf("%s: expected error", lang)
+		}
+	}
+}
+
+func TestGeneratedCodeCompilesForOlderLang(t *testing.T) {
+	files := map[string]string{
+		"go.mod": "module t9\n\ngo 1.17\n",
+		"bag.go": `package main
+
+type Bag struct { //+gob:Constructor
+	Items map[string]interface{}
+	Name  string ` + "`json:\"name\"`" + ` //+gob:default="bag"
+}
+`,
+		"main.go": "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(*NewBagBuilder().Items(nil).Build())\n}\n",
+	}
+	dir := generateTestModule(t, files, "bag.go", "-lang", "go1.17")
+	assertOutput(t, runTestModule(t, dir), "{map[] bag}")
+
+	for _, test := range []struct {
+		source  string
+		message string
+	}{
+		{source: "package main\n\ntype Box[T any] struct { //+gob:Constructor\n\tValue T\n}\n",
+			message: "bag.go:3:9: type parameters require go1.18, but \"lang\" flag is go1.17"},
+		{source: "package main\n\ntype Bag struct { //+gob:Constructor +gob:slog\n\tName string\n}\n",
+			message: "+gob:slog annotation of struct Bag requires go1.21"},
+	} {
+		dir := writeTestModule(t, withTestFile(files, "bag.go", test.source))
+		if code, diagnostics := generateTestFile(t, dir, "bag.go", "-lang", "go1.17"); code == 0 ||
+			!strings.Contains(diagnostics, test.message) {
+			t.Errorf("exit code %d:\n%s", code, diagnostics)
+		}
+	}
+}
@@ -1,16 +1,19 @@
 package main
 
 import (
+	"bytes"
 	"flag"
 	"fmt"
 	"go/ast"
+	"go/format"
 	"go/parser"
 	"go/token"
 	"io/ioutil"
-	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
 	"unicode"
 )
@@ -19,105 +22,541 @@ func fileNameWithoutExt(fileName string) string {
 	return strings.TrimSuffix(fileName, filepath.Ext(fileName))
 }
 
+// makeOutputFilename returns name of file generated for input file, e.g. person_gob.go for person.go. _GOOS and
+// _GOARCH suffixes of input file stay at the end (e.g. config_gob_linux.go for config_linux.go), so generated
+// file has the same implicit build constraint.
 func makeOutputFilename(inFilename string) string {
 	path := filepath.Dir(inFilename)
 	ext := filepath.Ext(inFilename)
-	outFilename := fmt.Sprintf("%s/%s_gob%s", path, fileNameWithoutExt(filepath.Base(inFilename)), ext)
+	name, platform := splitPlatformSuffix(fileNameWithoutExt(filepath.Base(inFilename)))
+	outFilename := fmt.Sprintf("%s/%s_gob%s%s", path, name, platform, ext)
 	return outFilename
 }
 
-func parseCommandLineArgs() (
-	inFilename string,
-	outFilename string,
-	generateFor *string,
-	usePtrReceiver bool,
-	constructorVisibility string,
-) {
-	_, err := exec.LookPath("goimports")
-	if err != nil {
-		_, _ = fmt.Fprintln(os.Stderr, "Error: \"goimports\" executable does not exist")
-		_, _ = fmt.Fprintln(os.Stderr, "You must install it to continue with gobetter:\n"+
-			"    go get golang.org/x/tools/cmd/goimports")
-		os.Exit(1)
+// makeLegacyOutputFilename returns name of file generated for input file by previous versions of gobetter that
+// inserted _gob suffix after _GOOS and _GOARCH suffixes, e.g. config_linux_gob.go for config_linux.go
+func makeLegacyOutputFilename(inFilename string) string {
+	return fmt.Sprintf("%s/%s_gob%s", filepath.Dir(inFilename), fileNameWithoutExt(filepath.Base(inFilename)),
+		filepath.Ext(inFilename))
+}
+
+// knownOS and knownArch are values of GOOS and GOARCH recognized by go command in file name suffixes
+var (
+	knownOS = map[string]bool{
+		"aix": true, "android": true, "darwin": true, "dragonfly": true, "freebsd": true, "hurd": true,
+		"illumos": true, "ios": true, "js": true, "linux": true, "nacl": true, "netbsd": true, "openbsd": true,
+		"plan9": true, "solaris": true, "wasip1": true, "windows": true, "zos": true,
+	}
+	knownArch = map[string]bool{
+		"386": true, "amd64": true, "amd64p32": true, "arm": true, "armbe": true, "arm64": true, "arm64be": true,
+		"loong64": true, "mips": true, "mipsle": true, "mips64": true, "mips64le": true, "mips64p32": true,
+		"mips64p32le": true, "ppc": true, "ppc64": true, "ppc64le": true, "riscv": true, "riscv64": true,
+		"s390": true, "s390x": true, "sparc": true, "sparc64": true, "wasm": true,
+	}
+)
+
+// splitPlatformSuffix splits file name without extension into name and _GOOS, _GOARCH or _GOOS_GOARCH suffix
+// implying build constraint, e.g. "config" and "_linux_amd64" for "config_linux_amd64". Same as go command,
+// the part before the first underscore is never treated as suffix.
+func splitPlatformSuffix(name string) (string, string) {
+	first := strings.Index(name, "_")
+	if first < 0 {
+		return name, ""
 	}
+	parts := strings.Split(name[first+1:], "_")
+	n := len(parts)
+	suffixLen := 0
+	switch {
+	case n >= 2 && knownOS[parts[n-2]] && knownArch[parts[n-1]]:
+		suffixLen = len(parts[n-2]) + len(parts[n-1]) + 2
+	case knownOS[parts[n-1]] || knownArch[parts[n-1]]:
+		suffixLen = len(parts[n-1]) + 1
+	}
+	return name[:len(name)-suffixLen], name[len(name)-suffixLen:]
+}
 
-	inputFilePtr := flag.String("input", "", "go input file path")
-	outputFilePtr := flag.String("output", "", "go output file path (optional)")
-	generateForPtr := flag.String("generate-for", "annotated",
+const version = "0.12"
+
+type CommandLineOptions struct {
+	InFilename            string
+	OutFilename           string
+	GenerateFor           *string
+	UsePtrReceiver        bool
+	ConstructorVisibility string
+	FieldOrder            string
+	MockTool              string
+	Force                 bool
+	CacheDir              string
+	Overlay               Overlay
+	Jobs                  int
+	Tags                  []string
+	Lang                  int
+	OutPerms              os.FileMode
+	Diagnostics           string
+	Strict                bool
+	MaxBuilderTypes       int
+	OnlyStructs           *NamePatterns
+	SkipStructs           *NamePatterns
+	GetterStyle           string
+	ExportedGetter        string
+	SetterStyle           string
+	FinalizerName         string
+	BuildName             string
+	BuilderVisibility     Visibility
+	Split                 string
+	Naming                string
+	Formatter             string
+	FixImports            bool
+	LocalPrefix           string
+	AnyStyle              string
+	OptionalFromTags      bool
+	RequiredFields        string
+	LogMarshaler          string
+	TOMLPackage           string
+	WebFramework          string
+	DeprecatedShims       bool
+	Bench                 bool
+	ConfigContent         []byte
+}
+
+func requireExecutable(name string, pkg string) {
+	if _, err := exec.LookPath(name); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: \"%s\" executable does not exist\n", name)
+		_, _ = fmt.Fprintf(os.Stderr, "You must install it to continue with gobetter:\n"+
+			"    go get %s\n", pkg)
+		os.Exit(ExitUsage)
+	}
+}
+
+// parseCommandLineArgs parses command-line arguments (without program name) into options, invalid arguments
+// terminate the process with ExitUsage
+func parseCommandLineArgs(args []string) (opts CommandLineOptions) {
+	flags := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	inputFilePtr := flags.String("input", "", "go input file path")
+	outputFilePtr := flags.String("output", "", "go output file path (optional)")
+	generateForPtr := flags.String("generate-for", "annotated",
 		`allows parsing of non-annotated struct types:
 |  all       - process exported and package-level classes
 |  exported  - process exported classes only
+|  tagged    - process classes having at least one +gob: annotation (including field annotations)
 |  annotated - process specifically annotated class only
 `)
-	receiverTypePtr := flag.String("receiver", "value",
+	receiverTypePtr := flags.String("receiver", "value",
 		`specify function receiver type:
 |  value     - receiver must be a value type, e.g. { func (v *Class) Name }
 |  pointer   - receiver must be a pointer type, e.g. { func (v Class) Name }
 `)
-	constructorVisibilityPtr := flag.String("constructor", "exported",
+	constructorVisibilityPtr := flags.String("constructor", "exported",
 		`generate exported or package-level constructors:
 |  exported  - exported (upper-cased) constructors will be created
 |  package   - package-level (lower-cased) constructors will be created
 |  none      - no constructors will be created
 `)
-	flag.Bool("print-version", false, "print current version")
+	sortPtr := flags.String("sort", "seq",
+		`specify order of fields in builder chain:
+|  seq       - fields are ordered as they are declared in structure
+|  abc       - fields are ordered alphabetically by name
+|  type      - fields are grouped by type name and then ordered alphabetically by name
+`)
+	mockPtr := flags.String("mock", "none",
+		`emit go:generate directive creating mocks for interfaces used by struct fields:
+|  none      - no directive will be emitted
+|  moq       - mocks will be generated by github.com/matryer/moq
+|  mockgen   - mocks will be generated by github.com/golang/mock/mockgen
+`)
+	onlyPtr := flags.String("only", "",
+		"process only structs with names matching comma-separated glob patterns (e.g. \"*Request\")\n"+
+			"or regular expressions enclosed in slashes (e.g. \"/^Create.+Request$/\")")
+	skipStructsPtr := flags.String("skip-structs", "",
+		"do not process structs with names matching comma-separated glob patterns or regular expressions\n"+
+			"enclosed in slashes")
+	getterStylePtr := flags.String("getter-style", "bare",
+		`specify naming of generated getters:
+|  bare      - getters are named after fields, e.g. { FirstName() }
+|  get       - getters have "Get" prefix, e.g. { GetFirstName() }
+|  must      - bare getters and getters panicking on zero values, e.g. { FirstName() and MustFirstName() }
+`)
+	exportedGetterPtr := flags.String("exported-getter", "error",
+		`how getters of exported fields (which would have the same names as fields) are handled:
+|  error     - generation fails
+|  warn      - warning is reported and getter is not generated
+|  get       - getter with "Get" prefix is generated, e.g. { GetFirstName() }
+`)
+	setterStylePtr := flags.String("setter-style", "bare",
+		`specify naming of builder chain methods:
+|  bare      - methods are named after fields, e.g. { FirstName("Joe") }
+|  with      - methods have "With" prefix, e.g. { WithFirstName("Joe") }
+|  set       - methods have "Set" prefix, e.g. { SetFirstName("Joe") }
+`)
+	finalizerNamePtr := flags.String("finalizer-name", "GobFinalizer",
+		"suffix of the last builder chain type, e.g. { Person_Builder_GobFinalizer }")
+	buildNamePtr := flags.String("build-name", "Build", "name of builder chain method returning built struct")
+	builderVisibilityPtr := flags.String("builder-visibility", "exported",
+		`generate exported or package-level builder chain types:
+|  exported  - exported builder types will be created, e.g. { Person_Builder_FirstName }
+|  package   - package-level builder types will be created, e.g. { person_builder_firstName }
+`)
+	formatterPtr := flags.String("formatter", "gofmt",
+		`how generated files are post-processed:
+|  gofmt     - code is formatted with go/format (or with goimports if -fix-imports is set)
+|  gofumpt   - same as gofmt, then code is formatted with gofumpt
+|  none      - generated code is written as is
+`)
+	fixImportsPtr := flags.Bool("fix-imports", false,
+		"fix imports of generated files with goimports, otherwise (default) import block is computed by\n"+
+			"gobetter and code is formatted with go/format")
+	localPtr := flags.String("local", "",
+		"put imports beginning with this string after 3rd-party packages; comma-separated list")
+	optionalFromTagsPtr := flags.Bool("optional-from-tags", false,
+		"treat fields with \"omitempty\" option of json tag or with pointer types as optional")
+	requiredPtr := flags.String("required", "all",
+		`which fields are added to builder chain:
+|  all        - all fields except of optional ones (annotated with //+gob:_)
+|  annotated  - only fields annotated with //+gob:required
+`)
+	logMarshalerPtr := flags.String("log-marshaler", "none",
+		`generate log marshaler method for every processed structure:
+|  none     - no log marshalers will be generated
+|  zap      - MarshalLogObject(enc zapcore.ObjectEncoder) error method will be generated
+|  zerolog  - MarshalZerologObject(e *zerolog.Event) method will be generated
+`)
+	tomlPtr := flags.String("toml", "burntsushi",
+		`TOML package used by code generated for structures annotated with //+gob:toml:
+|  burntsushi  - github.com/BurntSushi/toml
+|  pelletier   - github.com/pelletier/go-toml/v2
+`)
+	webFrameworkPtr := flags.String("web-framework", "gin",
+		`web framework used by code generated for structures annotated with //+gob:bind:
+|  gin   - github.com/gin-gonic/gin
+|  echo  - github.com/labstack/echo/v4
+`)
+	anyStylePtr := flags.String("anystyle", "any",
+		`spelling of empty interface type in generated code:
+|  any          - e.g. { map[string]any }
+|  interface{}  - e.g. { map[string]interface{} }
+`)
+	langPtr := flags.String("lang", "",
+		"version of Go (e.g. go1.17) generated code must compile with, code generated for older versions than\n"+
+			"go1.18 spells empty interface as interface{} and input files with type parameters are rejected")
+	benchPtr := flags.Bool("bench", false,
+		"generate <input-file-name>_gob_bench_test.go file with benchmarks comparing builders with composite literals")
+	deprecatedShimsPtr := flags.Bool("deprecated-shims", false,
+		"generate deprecated aliases of builder types and methods with names they have with default naming flags")
+	namingPtr := flags.String("naming", "legacy",
+		`naming scheme of generated builder chain types:
+|  legacy    - underscore-separated names, e.g. { Person_Builder_FirstName }
+|  camel     - CamelCase names, e.g. { PersonBuilderFirstName }
+`)
+	splitPtr := flags.String("split", "file",
+		`how to split generated code into files:
+|  file      - code for all structures is written into single output file
+|  struct    - code for every structure is written into separate <struct>_gob.go file
+`)
+	overlayPtr := flags.String("overlay", "",
+		"JSON file replacing contents of files (e.g. with unsaved editor buffers) in the same format as\n"+
+			"-overlay flag of go command: {\"Replace\": {\"<file>\": \"<file with its contents>\"}}")
+	cacheDirPtr := flags.String("cache-dir", DefaultCacheDir(),
+		"directory to cache generated files in (\"off\" disables cache, GOBETTERCACHE environment variable\n"+
+			"can be used to change default value)")
+	jobsPtr := flags.Int("jobs", runtime.NumCPU(),
+		"number of files processed concurrently when input is a directory (\"dir\" or recursive \"dir/...\")")
+	tagsPtr := flags.String("tags", "",
+		"comma-separated list of build tags satisfied by files processed when input is a directory, files with\n"+
+			"build constraints (//go:build lines and _GOOS/_GOARCH suffixes) not satisfied by these tags, GOOS\n"+
+			"and GOARCH are skipped")
+	diagnosticsPtr := flags.String("diagnostics", "text",
+		`format of errors and warnings printed to stderr:
+|  text      - one "path:line:col: message" line per diagnostic
+|  json      - one JSON object per diagnostic, e.g. { {"file": "a.go", "line": 1, "column": 6, ...} }
+`)
+	strictPtr := flags.Bool("strict", false,
+		"report warnings (e.g. unknown annotations or skipped fields) as errors and fail generation")
+	maxBuilderTypesPtr := flags.Int("max-builder-types", 50,
+		"warn when builder chain of structure has more types than this number (0 disables the warning)")
+	configPtr := flags.String("config", "",
+		"JSON configuration file setting default values of flags (see \"gobetter config schema\"), flags passed\n"+
+			"in command line take precedence")
+	forcePtr := flags.Bool("force", false, "regenerate output file even if its signature is up to date")
+	outPermsPtr := flags.String("out-perms", "0644", "octal permissions of generated files")
+	readOnlyPtr := flags.Bool("read-only", false,
+		"write generated files read-only (0444), so they are not edited by hand, same as \"-out-perms 0444\"")
+	flags.Bool("print-version", false, "print current version")
 
-	flag.Parse()
-	if isFlagPassed("print-version") {
-		println("gobetter version 0.11")
+	_ = flags.Parse(args)
+	if *configPtr != "" {
+		config, problems, err := LoadConfig(*configPtr)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: failed to load configuration file %s: %v\n", *configPtr, err)
+			os.Exit(ExitUsage)
+		}
+		for _, problem := range problems {
+			_, _ = fmt.Fprintf(os.Stderr, "%s: %s\n", *configPtr, problem)
+		}
+		if len(problems) > 0 {
+			os.Exit(ExitUsage)
+		}
+		if err = config.Apply(flags); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(ExitUsage)
+		}
+		// content cannot fail to be read again, it was just loaded
+		opts.ConfigContent, _ = os.ReadFile(*configPtr)
+	}
+	if isFlagPassed(flags, "print-version") {
+		println("gobetter version " + version)
 	}
 
-	inFilename = *inputFilePtr
+	opts.InFilename = *inputFilePtr
+	opts.Force = *forcePtr
+	perms, permsErr := strconv.ParseUint(*outPermsPtr, 8, 32)
+	if permsErr != nil || perms > 0o777 || perms&0o400 == 0 {
+		_, _ = fmt.Fprintln(os.Stderr, "Error: \"out-perms\" flag must be octal permissions readable by owner, e.g. 0644")
+		os.Exit(ExitUsage)
+	}
+	opts.OutPerms = os.FileMode(perms)
+	if *readOnlyPtr {
+		if isFlagPassed(flags, "out-perms") {
+			_, _ = fmt.Fprintln(os.Stderr, "Error: \"read-only\" and \"out-perms\" flags cannot be used together")
+			os.Exit(ExitUsage)
+		}
+		opts.OutPerms = 0o444
+	}
+	opts.Jobs = *jobsPtr
+	if *tagsPtr != "" {
+		opts.Tags = strings.Split(*tagsPtr, ",")
+	}
+	opts.CacheDir = *cacheDirPtr
+	if *overlayPtr != "" {
+		overlay, err := LoadOverlay(*overlayPtr)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: failed to load overlay %s: %v\n", *overlayPtr, err)
+			os.Exit(ExitUsage)
+		}
+		opts.Overlay = overlay
+	}
 
-	if !isFlagPassed("input") {
+	if !isFlagPassed(flags, "input") {
 		_, _ = fmt.Fprintln(os.Stderr, "Error: \"input\" flag must be specified")
-		os.Exit(1)
+		os.Exit(ExitUsage)
 	}
-	if _, err := os.Stat(inFilename); os.IsNotExist(err) {
-		_, _ = fmt.Fprintf(os.Stderr, "File %s does not exist\n", inFilename)
-		os.Exit(1)
+	if dir, _, ok := directoryInput(opts.InFilename); ok {
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			_, _ = fmt.Fprintf(os.Stderr, "Directory %s does not exist\n", dir)
+			os.Exit(ExitUsage)
+		}
+		if isFlagPassed(flags, "output") {
+			_, _ = fmt.Fprintln(os.Stderr, "Error: \"output\" flag cannot be used when input is a directory")
+			os.Exit(ExitUsage)
+		}
+		if opts.Jobs < 1 {
+			_, _ = fmt.Fprintln(os.Stderr, "Error: \"jobs\" flag must be positive")
+			os.Exit(ExitUsage)
+		}
+	} else if _, err := os.Stat(opts.InFilename); os.IsNotExist(err) {
+		_, _ = fmt.Fprintf(os.Stderr, "File %s does not exist\n", opts.InFilename)
+		os.Exit(ExitUsage)
 	}
 
-	if isFlagPassed("output") {
-		outFilename = *outputFilePtr
+	if isFlagPassed(flags, "output") {
+		opts.OutFilename = *outputFilePtr
 	} else {
-		outFilename = makeOutputFilename(inFilename)
+		opts.OutFilename = makeOutputFilename(opts.InFilename)
 	}
 
-	if *generateForPtr == "all" || *generateForPtr == "exported" {
-		generateFor = generateForPtr
+	if *generateForPtr == "all" || *generateForPtr == "exported" || *generateForPtr == "tagged" {
+		opts.GenerateFor = generateForPtr
 	} else if *generateForPtr == "annotated" {
-		generateFor = nil
+		opts.GenerateFor = nil
 	} else {
-		_, _ = fmt.Fprintln(os.Stderr, "Error: \"generate-for\" flag must be \"all\", \"exported\", or \"annotated\"")
-		os.Exit(1)
+		_, _ = fmt.Fprintln(os.Stderr, "Error: \"generate-for\" flag must be \"all\", \"exported\", \"tagged\", or \"annotated\"")
+		os.Exit(ExitUsage)
 	}
 
 	switch {
 	case *receiverTypePtr == "pointer":
-		usePtrReceiver = true
+		opts.UsePtrReceiver = true
 	case *receiverTypePtr == "value":
-		usePtrReceiver = false
+		opts.UsePtrReceiver = false
 	default:
 		_, _ = fmt.Fprintln(os.Stderr, "Error: \"receiver\" flag must be \"pointer\" or \"value\"")
-		os.Exit(1)
+		os.Exit(ExitUsage)
 	}
 
 	if *constructorVisibilityPtr == "exported" || *constructorVisibilityPtr == "package" || *constructorVisibilityPtr == "none" {
-		constructorVisibility = *constructorVisibilityPtr
+		opts.ConstructorVisibility = *constructorVisibilityPtr
 	} else {
 		_, _ = fmt.Fprintln(os.Stderr, "Error: \"constructor\" flag must be \"exported\", \"package\", or \"none\"")
-		os.Exit(1)
+		os.Exit(ExitUsage)
+	}
+
+	if *sortPtr == "seq" || *sortPtr == "abc" || *sortPtr == "type" {
+		opts.FieldOrder = *sortPtr
+	} else {
+		_, _ = fmt.Fprintln(os.Stderr, "Error: \"sort\" flag must be \"seq\", \"abc\", or \"type\"")
+		os.Exit(ExitUsage)
+	}
+
+	if *mockPtr == "none" || *mockPtr == "moq" || *mockPtr == "mockgen" {
+		opts.MockTool = *mockPtr
+	} else {
+		_, _ = fmt.Fprintln(os.Stderr, "Error: \"mock\" flag must be \"none\", \"moq\", or \"mockgen\"")
+		os.Exit(ExitUsage)
+	}
+
+	if *getterStylePtr == "bare" || *getterStylePtr == "get" || *getterStylePtr == "must" {
+		opts.GetterStyle = *getterStylePtr
+	} else {
+		_, _ = fmt.Fprintln(os.Stderr, "Error: \"getter-style\" flag must be \"bare\", \"get\", or \"must\"")
+		os.Exit(ExitUsage)
+	}
+
+	switch *exportedGetterPtr {
+	case "error", "warn", "get":
+		opts.ExportedGetter = *exportedGetterPtr
+	default:
+		_, _ = fmt.Fprintln(os.Stderr, "Error: \"exported-getter\" flag must be \"error\", \"warn\" or \"get\"")
+		os.Exit(ExitUsage)
+	}
+
+	if *setterStylePtr == "bare" || *setterStylePtr == "with" || *setterStylePtr == "set" {
+		opts.SetterStyle = *setterStylePtr
+	} else {
+		_, _ = fmt.Fprintln(os.Stderr, "Error: \"setter-style\" flag must be \"bare\", \"with\", or \"set\"")
+		os.Exit(ExitUsage)
+	}
+
+	if !token.IsIdentifier(*finalizerNamePtr) || !token.IsIdentifier(*buildNamePtr) {
+		_, _ = fmt.Fprintln(os.Stderr, "Error: \"finalizer-name\" and \"build-name\" flags must be valid identifiers")
+		os.Exit(ExitUsage)
+	}
+	opts.FinalizerName = strings.Title(*finalizerNamePtr)
+	opts.BuildName = *buildNamePtr
+
+	switch *builderVisibilityPtr {
+	case "exported":
+		opts.BuilderVisibility = ExportedVisibility
+	case "package":
+		opts.BuilderVisibility = PackageLevelVisibility
+	default:
+		_, _ = fmt.Fprintln(os.Stderr, "Error: \"builder-visibility\" flag must be \"exported\" or \"package\"")
+		os.Exit(ExitUsage)
+	}
+
+	opts.FixImports = *fixImportsPtr
+	opts.DeprecatedShims = *deprecatedShimsPtr
+	opts.Bench = *benchPtr
+	opts.Strict = *strictPtr
+	if *maxBuilderTypesPtr < 0 {
+		_, _ = fmt.Fprintln(os.Stderr, "Error: \"max-builder-types\" flag must not be negative")
+		os.Exit(ExitUsage)
+	}
+	opts.MaxBuilderTypes = *maxBuilderTypesPtr
+	opts.OptionalFromTags = *optionalFromTagsPtr
+	opts.LocalPrefix = *localPtr
+	switch *formatterPtr {
+	case "gofmt", "gofumpt", "none":
+		opts.Formatter = *formatterPtr
+	default:
+		_, _ = fmt.Fprintln(os.Stderr, "Error: \"formatter\" flag must be \"gofmt\", \"gofumpt\" or \"none\"")
+		os.Exit(ExitUsage)
+	}
+	switch *diagnosticsPtr {
+	case "text", "json":
+		opts.Diagnostics = *diagnosticsPtr
+	default:
+		_, _ = fmt.Fprintln(os.Stderr, "Error: \"diagnostics\" flag must be \"text\" or \"json\"")
+		os.Exit(ExitUsage)
+	}
+	if opts.FixImports && opts.Formatter != "none" {
+		requireExecutable("goimports", "golang.org/x/tools/cmd/goimports")
+	}
+	if opts.Formatter == "gofumpt" {
+		requireExecutable("gofumpt", "mvdan.cc/gofumpt")
+	}
+
+	if *requiredPtr == "all" || *requiredPtr == "annotated" {
+		opts.RequiredFields = *requiredPtr
+	} else {
+		_, _ = fmt.Fprintln(os.Stderr, "Error: \"required\" flag must be \"all\" or \"annotated\"")
+		os.Exit(ExitUsage)
+	}
+
+	if *logMarshalerPtr == "none" || *logMarshalerPtr == "zap" || *logMarshalerPtr == "zerolog" {
+		opts.LogMarshaler = *logMarshalerPtr
+	} else {
+		_, _ = fmt.Fprintln(os.Stderr, "Error: \"log-marshaler\" flag must be \"none\", \"zap\" or \"zerolog\"")
+		os.Exit(ExitUsage)
+	}
+
+	switch *tomlPtr {
+	case "burntsushi":
+		opts.TOMLPackage = "github.com/BurntSushi/toml"
+	case "pelletier":
+		opts.TOMLPackage = "github.com/pelletier/go-toml/v2"
+	default:
+		_, _ = fmt.Fprintln(os.Stderr, "Error: \"toml\" flag must be \"burntsushi\" or \"pelletier\"")
+		os.Exit(ExitUsage)
+	}
+
+	if *webFrameworkPtr == "gin" || *webFrameworkPtr == "echo" {
+		opts.WebFramework = *webFrameworkPtr
+	} else {
+		_, _ = fmt.Fprintln(os.Stderr, "Error: \"web-framework\" flag must be \"gin\" or \"echo\"")
+		os.Exit(ExitUsage)
+	}
+
+	if *anyStylePtr == "any" || *anyStylePtr == "interface{}" {
+		opts.AnyStyle = *anyStylePtr
+	} else {
+		_, _ = fmt.Fprintln(os.Stderr, "Error: \"anystyle\" flag must be \"any\" or \"interface{}\"")
+		os.Exit(ExitUsage)
+	}
+	if *langPtr != "" {
+		lang, err := parseLangVersion(*langPtr)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(ExitUsage)
+		}
+		opts.Lang = lang
+		if !opts.langAtLeast(18) {
+			if isFlagPassed(flags, "anystyle") && opts.AnyStyle == "any" {
+				_, _ = fmt.Fprintf(os.Stderr, "Error: \"anystyle\" flag cannot be \"any\" with \"lang\" flag %s\n", *langPtr)
+				os.Exit(ExitUsage)
+			}
+			opts.AnyStyle = "interface{}"
+		}
+	}
+
+	if *namingPtr == "legacy" || *namingPtr == "camel" {
+		opts.Naming = *namingPtr
+	} else {
+		_, _ = fmt.Fprintln(os.Stderr, "Error: \"naming\" flag must be \"legacy\" or \"camel\"")
+		os.Exit(ExitUsage)
+	}
+
+	if *splitPtr == "file" || *splitPtr == "struct" {
+		opts.Split = *splitPtr
+	} else {
+		_, _ = fmt.Fprintln(os.Stderr, "Error: \"split\" flag must be \"file\" or \"struct\"")
+		os.Exit(ExitUsage)
+	}
+
+	var err error
+	if opts.OnlyStructs, err = ParseNamePatterns(*onlyPtr); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: \"only\" flag: %v\n", err)
+		os.Exit(ExitUsage)
+	}
+	if opts.SkipStructs, err = ParseNamePatterns(*skipStructsPtr); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: \"skip-structs\" flag: %v\n", err)
+		os.Exit(ExitUsage)
 	}
 
-	println("Input file:", inFilename)
-	println("Output file:", outFilename)
 	return
 }
 
-func isFlagPassed(name string) bool {
+func isFlagPassed(flags *flag.FlagSet, name string) bool {
 	found := false
-	flag.Visit(func(f *flag.Flag) {
+	flags.Visit(func(f *flag.Flag) {
 		if f.Name == name {
 			found = true
 		}
@@ -125,101 +564,857 @@ func isFlagPassed(name string) bool {
 	return found
 }
 
+// hasName reports whether identifiers include one with name
+func hasName(idents []*ast.Ident, name string) bool {
+	for _, ident := range idents {
+		if ident.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func structFieldList(st *ast.StructType) []*ast.Field {
+	if st == nil {
+		return nil
+	}
+	return st.Fields.List
+}
+
 func main() {
+	defer exitOnPanic()
+	if IsVetToolInvocation(os.Args[1:]) {
+		os.Exit(RunVetTool(os.Args[1:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(RunConfigCommand(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "stats" {
+		os.Exit(RunStatsCommand(os.Args[2:]))
+	}
 
-	inFilename, outFilename, defaultTypes, usePtrReceiver, constructorVisibility := parseCommandLineArgs()
-	fileContent, err := os.ReadFile(inFilename)
-	if err != nil {
-		_, _ = fmt.Fprintf(os.Stderr, "error: failed to read file %s: %v\n", inFilename, err)
-		os.Exit(1)
+	// "gobetter fix" accepts the same flags as generation, so it knows names of generated builder chains
+	fix := len(os.Args) > 1 && os.Args[1] == "fix"
+	migrate := len(os.Args) > 1 && os.Args[1] == "migrate"
+	var renames []fieldRename
+	if fix || migrate {
+		os.Args = append(os.Args[:1], os.Args[2:]...)
 	}
-	fset := token.NewFileSet()
-	astFile, err := parser.ParseFile(fset, inFilename, nil, parser.ParseComments)
+	if migrate {
+		// "gobetter migrate -rename old=new" regenerates code and rewrites call sites of renamed fields
+		var err error
+		renames, os.Args, err = ExtractRenames(os.Args)
+		if err == nil && len(renames) == 0 {
+			err = fmt.Errorf("\"rename\" flag must be specified")
+		}
+		if err != nil {
+			exitWithError(ExitUsage, err)
+		}
+	}
+
+	opts := parseCommandLineArgs(os.Args[1:])
+	if fix || migrate {
+		// call sites are rewritten regardless of whether generated code is up to date
+		opts.Force = true
+	}
+	if _, _, ok := directoryInput(opts.InFilename); ok {
+		if fix || migrate {
+			_, _ = fmt.Fprintln(os.Stderr, "Error: input must be a file")
+			os.Exit(ExitUsage)
+		}
+		os.Exit(RunDirectory(opts, os.Args[1:], opts.Jobs))
+	}
+
+	run := &fileRun{
+		opts:     opts,
+		args:     os.Args[1:],
+		packages: NewPackageCache(opts.Overlay),
+		stdout:   os.Stdout,
+		stderr:   os.Stderr,
+		fix:      fix,
+		migrate:  migrate,
+		renames:  renames,
+	}
+	if code := run.generate(); code != 0 {
+		os.Exit(code)
+	}
+	NewDiagnostics(opts.Diagnostics, opts.Strict, os.Stderr).Summary(run.stats)
+}
+
+// generateFile generates code for input file of run, failures abort it with exit code (see fileRun.exit)
+func (r *fileRun) generateFile() {
+	opts := r.opts
+	// progress is printed to stdout, so stderr carries diagnostics only and stays parseable in json format
+	r.printf("Input file: %s\n", opts.InFilename)
+	if opts.Split == "struct" {
+		r.printf("Output directory: %s\n", filepath.Dir(opts.OutFilename))
+	} else {
+		r.printf("Output file: %s\n", opts.OutFilename)
+	}
+	inFilename := opts.InFilename
+	fileContent := r.content
+	if fileContent == nil {
+		var err error
+		if fileContent, err = opts.Overlay.ReadFile(inFilename); err != nil {
+			r.exitWithError(ExitParse, fmt.Errorf("failed to read file %s: %v", inFilename, err))
+		}
+	}
+	signature := ComputeSignature(fileContent, opts.ConfigContent, r.args)
+	diagnostics := NewDiagnostics(opts.Diagnostics, opts.Strict, r.stderr)
+	stats := &r.stats
+	stats.FilesScanned = 1
+	defer func() {
+		stats.Warnings = diagnostics.Warnings()
+	}()
+	outDir := filepath.Dir(opts.OutFilename)
+	if !r.fix && !r.migrate && !mayHaveAnnotatedStructs(opts.GenerateFor, fileContent) {
+		// file is not parsed at all, but code generated from it earlier is stale now
+		r.printf("Input file has no +gob: annotations\n")
+		r.removeStaleOutputs(outDir)
+		return
+	}
+	if opts.Split == "struct" {
+		if !opts.Force && AreStructOutputsUpToDate(r.stdout, outDir, inFilename, signature) {
+			r.printf("Output files are up to date\n")
+			stats.FilesSkipped++
+			return
+		}
+	} else if !opts.Force && IsUpToDate(r.stdout, opts.OutFilename, signature) {
+		r.printf("Output file is up to date\n")
+		stats.FilesSkipped++
+		return
+	}
+	packages := r.packages
+	fset := packages.FileSet()
+	astFile, err := parser.ParseFile(fset, inFilename, fileContent, parser.ParseComments)
 	if err != nil {
-		log.Fatal(err)
+		diagnostics.ReportError(fset, err, token.NoPos)
+		r.exit(ExitParse)
+	}
+	if err = CheckInputLang(opts, astFile); err != nil {
+		diagnostics.ReportError(fset, err, token.NoPos)
+		r.exit(ExitParse)
 	}
-	sp := NewStructParser(fset, fileContent)
+	sp := NewStructParser(packages, astFile, fileContent, opts.AnyStyle)
 
-	bld := strings.Builder{}
-	bld.WriteString(GeneratePackage(astFile))
-	bld.WriteString(GenerateImports(astFile))
+	outputs := make([]structOutput, 0)
+	foreignImports := make(map[string]string)
+	localInterfaces := sp.interfaceTypes(astFile)
+	lockTypes := sp.lockTypes(astFile)
+	mockInterfaces := make([]string, 0)
+	fixTargets := make(map[string]*fixTarget)
+	benchmarks := &strings.Builder{}
 
 	ast.Inspect(astFile, func(n ast.Node) bool {
 		ts, ok := n.(*ast.TypeSpec)
 		if !ok {
 			return true
 		}
-		st, ok := ts.Type.(*ast.StructType)
-		if !ok {
+		var st *ast.StructType
+		var structFlags StructFlags
+		switch t := ts.Type.(type) {
+		case *ast.StructType:
+			st = t
+			structFlags = sp.constructorFlags(ts)
+		case *ast.SelectorExpr:
+			// defined type over struct from another package (e.g. "type Order pkg.Order") is processed
+			// only when annotated, because it requires type-checking of the imported package
+			structFlags = sp.constructorFlags(ts)
+			if !structFlags.ProcessStruct {
+				return true
+			}
+		default:
+			return true
+		}
+		if structFlags.Skip {
+			return true
+		}
+		if opts.OnlyStructs != nil && !opts.OnlyStructs.Match(ts.Name.Name) {
+			return true
+		}
+		if opts.SkipStructs != nil && opts.SkipStructs.Match(ts.Name.Name) {
 			return true
 		}
 
 		structName := ts.Name.Name
-		structFlags := sp.constructorFlags(st)
+		// errors are reported at position of structure name, unless they are caused by its fields
+		failed := func(err error) {
+			diagnostics.ReportError(fset, err, ts.Name.Pos())
+			r.exit(ExitAnnotation)
+		}
+		if structFlags.Slog && !opts.langAtLeast(21) {
+			failed(fmt.Errorf("+gob:slog annotation of struct %s requires go1.21 (log/slog package), but \"lang\" "+
+				"flag is go1.%d", ts.Name.Name, opts.Lang))
+		}
+		typeParams, typeArgs := sp.typeParams(ts)
 		if !structFlags.ProcessStruct {
-			if defaultTypes == nil {
+			if opts.GenerateFor == nil {
 				return true
 			}
-			if *defaultTypes == "exported" {
+			if *opts.GenerateFor == "exported" {
 				if !unicode.IsUpper(rune(ts.Name.Name[0])) {
 					return true
 				}
 			}
+			if *opts.GenerateFor == "tagged" && !sp.hasAnnotations(ts) {
+				return true
+			}
 			structFlags.ProcessStruct = true
-			structFlags.PtrReceiver = usePtrReceiver
+			structFlags.PtrReceiver = opts.UsePtrReceiver
 			switch {
-			case constructorVisibility == "exported":
+			case opts.ConstructorVisibility == "exported":
 				structFlags.Visibility = ExportedVisibility
-			case constructorVisibility == "package":
+			case opts.ConstructorVisibility == "package":
 				structFlags.Visibility = PackageLevelVisibility
 			default:
 				structFlags.Visibility = NoVisibility
 			}
 		}
 
-		fmt.Printf("Process structure %s\n", structName)
+		r.printf("Process structure %s\n", structName)
+		stats.Structs++
+		for _, annotation := range unknownAnnotations(sp.structAnnotations(ts), structAnnotationNames) {
+			diagnostics.Warnf(fset.Position(sp.structAnnotationPos(ts, annotation)),
+				"unknown annotation %s of struct %s%s", annotation, structName,
+				annotationSuggestion(annotation, structAnnotationNames))
+		}
+		bld := &strings.Builder{}
+		outputs = append(outputs, structOutput{structName: structName, code: bld})
+		structFlags.GetterStyle = opts.GetterStyle
+		structFlags.SetterStyle = opts.SetterStyle
+		structFlags.FinalizerName = opts.FinalizerName
+		structFlags.BuildName = opts.BuildName
+		structFlags.BuilderVisibility = opts.BuilderVisibility
+		structFlags.Naming = opts.Naming
+		structFlags.AnyStyle = opts.AnyStyle
 
+		// fields holds all named fields in order of declaration
+		fields := make([]*StructField, 0)
 		structFields := make([]*StructField, 0)
-		for _, field := range st.Fields.List {
+		optionalFields := make([]*StructField, 0)
+		if st == nil {
+			foreignFields, err := sp.foreignStructFields(astFile, filepath.Dir(inFilename), ts.Type.(*ast.SelectorExpr),
+				foreignImports)
+			if err != nil {
+				failed(fmt.Errorf("struct %s: %w", structName, err))
+			}
+			for _, field := range foreignFields {
+				field.StructFlags = &structFlags
+				field.StructName = structName
+				fields = append(fields, field)
+				if structFlags.Visibility != NoVisibility {
+					structFields = append(structFields, field)
+				}
+			}
+		}
+		for _, field := range structFieldList(st) {
 			fieldTypeText := sp.fieldTypeText(field)
-			for _, fieldName := range field.Names {
+			// embedded fields are named after their types, but they are skipped unless annotated
+			fieldNames := fieldNames(field)
+			if len(field.Names) == 0 && !sp.embeddedFieldIncluded(field) {
+				diagnostics.Warnf(fset.Position(field.Pos()), "embedded field %s of struct %s is skipped, annotate it "+
+					"with +gob:required, +gob:_ or +gob:default to include it", fieldTypeText, structName)
+				fieldNames = nil
+			}
+			fieldLabel := fieldTypeText
+			if len(fieldNames) > 0 {
+				names := make([]string, 0, len(fieldNames))
+				for _, name := range fieldNames {
+					names = append(names, name.Name)
+				}
+				fieldLabel = strings.Join(names, ", ")
+			}
+			for _, annotation := range unknownAnnotations(sp.allFieldAnnotations(field), fieldAnnotationNames) {
+				diagnostics.Warnf(fset.Position(sp.fieldAnnotationPos(field, annotation)),
+					"unknown annotation %s of field %s of struct %s%s", annotation, fieldLabel, structName,
+					annotationSuggestion(annotation, fieldAnnotationNames))
+			}
+			for _, annotation := range sp.allFieldAnnotations(field) {
+				_, targets, _ := annotationTargets(annotation)
+				for _, target := range targets {
+					if !hasName(fieldNames, target) {
+						diagnostics.Warnf(fset.Position(sp.fieldAnnotationPos(field, annotation)),
+							"annotation %s of field %s of struct %s targets unknown name %s", annotation,
+							fieldLabel, structName, target)
+					}
+				}
+			}
+			if ident, ok := field.Type.(*ast.Ident); ok && localInterfaces[ident.Name] {
+				localInterfaces[ident.Name] = false
+				mockInterfaces = append(mockInterfaces, ident.Name)
+			}
+			for _, fieldName := range fieldNames {
+				if first, second, found := annotationConflict(sp.fieldAnnotations(field, fieldName.Name)); found {
+					failed(&PositionError{Pos: sp.fieldAnnotationPos(field, second), Err: fmt.Errorf(
+						"annotations %s and %s of field %s of struct %s contradict each other", first, second,
+						fieldName.Name, structName)})
+				}
 				structField := StructField{
 					StructFlags:   &structFlags,
 					StructName:    structName,
 					FieldName:     fieldName.Name,
 					FieldTypeText: fieldTypeText,
-					Acronym:       sp.fieldAcronym(field),
+					Acronym:       sp.fieldAcronym(field, fieldName.Name),
+					Group:         sp.fieldGroup(field, fieldName.Name),
+					Lazy:          sp.fieldLazy(field, fieldName.Name),
+					Computed:      sp.fieldComputed(field, fieldName.Name),
+					Default:       sp.fieldDefault(field, fieldName.Name),
+					Env:           sp.fieldEnv(field, fieldName.Name),
+					Tag:           sp.fieldTag(field),
+					TypeParams:    typeParams,
+					TypeArgs:      typeArgs,
+					NoCopy:        sp.isLockType(astFile, field.Type, lockTypes),
+					Zero:          sp.fieldZero(field.Type),
+					Annotations:   sp.fieldAnnotations(field, fieldName.Name),
+					Pos:           fieldName.Pos(),
 				}
-				if structFlags.Visibility != NoVisibility {
-					if !sp.fieldOptional(field) {
-						structFields = append(structFields, &structField)
+				secret, noGetter, err := sp.fieldSecret(field, fieldName.Name)
+				if err != nil {
+					failed(structField.errorf("field %s of struct %s: %v", fieldName.Name, structName, err))
+				}
+				structField.Secret, structField.NoGetter = secret, noGetter
+				if strings.HasPrefix(structField.Default, defaultFuncPrefix) {
+					call, err := sp.defaultFuncCall(astFile, filepath.Dir(inFilename), &structField)
+					if err != nil {
+						failed(err)
+					}
+					structField.Default = call
+				}
+				validations, err := sp.fieldValidations(field, fieldName.Name)
+				if err != nil {
+					failed(structField.errorf("field %s of struct %s: %v", fieldName.Name, structName, err))
+				}
+				structField.Validations = validations
+				if len(structField.Validations) > 0 && !structField.NoCopy {
+					structFlags.Validated = append(structFlags.Validated, &structField)
+				}
+				fields = append(fields, &structField)
+				required := !sp.fieldOptional(field, fieldName.Name) && !(opts.OptionalFromTags && structField.optionalByTags())
+				if opts.RequiredFields == "annotated" {
+					required = sp.fieldRequired(field, fieldName.Name)
+				}
+				switch {
+				case structField.NoCopy:
+					// locks are not populated by builders and constructors, their zero values are ready to use
+				case structField.Lazy != "" || structField.Computed != "" || structField.Default != "":
+					structFlags.Derived = append(structFlags.Derived, &structField)
+				case structFlags.Visibility != NoVisibility && required:
+					structFields = append(structFields, &structField)
+				default:
+					optionalFields = append(optionalFields, &structField)
+				}
+				if structField.NoGetter && sp.fieldGetter(field, fieldName.Name) {
+					failed(structField.errorf("annotations +gob:secret=nogetter and +gob:getter of field %s of "+
+						"struct %s contradict each other", fieldName.Name, structName))
+				}
+				if sp.fieldGetter(field, fieldName.Name) {
+					getterField := &structField
+					if fieldName.IsExported() && opts.GetterStyle != "get" {
+						switch opts.ExportedGetter {
+						case "warn":
+							diagnostics.Warnf(fset.Position(fieldName.Pos()), "getter of exported field %s of struct %s "+
+								"would have the same name as the field, getter is not generated", fieldName.Name, structName)
+							getterField = nil
+						case "get":
+							getterFlags := structFlags
+							getterFlags.GetterStyle = "get"
+							copied := structField
+							copied.StructFlags = &getterFlags
+							getterField = &copied
+						default:
+							failed(structField.errorf("getter of exported field %s of struct %s would have the same "+
+								"name as the field, remove +gob:getter annotation or use \"-exported-getter get\"",
+								fieldName.Name, structName))
+						}
+					}
+					if getterField != nil {
+						bld.WriteString(getterField.GenerateGetter())
+						stats.Getters++
 					}
 				}
-				if sp.fieldGetter(field) {
-					bld.WriteString(structField.GenerateGetter())
+			}
+		}
+
+		if err := CheckStructFieldGroups(structFields); err != nil {
+			failed(err)
+		}
+		SortStructFields(structFields, opts.FieldOrder)
+		if structFlags.Chunk > 0 {
+			if err := ChunkStructFields(structFields); err != nil {
+				failed(err)
+			}
+		}
+		structFlags.ConstructorDoc = GenerateConstructorDoc(structFields, optionalFields)
+		if structFlags.Chunk > 0 && len(structFields) > 0 {
+			bld.WriteString(GenerateSourceCodeForChunks(structFields))
+		} else {
+			for i, sp := range structFields {
+				var str string
+				isLast := i == len(structFields)-1
+				if i == 0 {
+					str = sp.GenerateSourceCodeForStructField(structFields[0], nil, isLast)
+				} else {
+					str = sp.GenerateSourceCodeForStructField(structFields[0], structFields[i-1], isLast)
 				}
+				bld.WriteString(str)
 			}
 		}
+		if len(structFields) > 0 {
+			next := structFields[0].finalizer()
+			if len(structFields) > 1 {
+				next = structFields[1]
+			}
+			bld.WriteString(structFields[0].GenerateConstructorOf(next))
+		}
+		if builderTypes := BuilderTypeCount(structFields); opts.MaxBuilderTypes > 0 &&
+			builderTypes > opts.MaxBuilderTypes {
+			diagnostics.Warnf(fset.Position(ts.Name.Pos()), "builder chain of struct %s has %d types, more than "+
+				"%d allowed by -max-builder-types, consider splitting it into sections with +gob:chunk=<n> or "+
+				"grouping fields with +gob:group=<name>", structName, builderTypes, opts.MaxBuilderTypes)
+		}
+		if opts.DeprecatedShims {
+			shims, aliases := GenerateDeprecatedShims(structFields)
+			bld.WriteString(shims)
+			stats.Aliases += aliases
+		}
+		if opts.Bench {
+			bench, err := GenerateBenchmarks(structFields)
+			if err != nil {
+				failed(err)
+			}
+			benchmarks.WriteString(bench)
+		}
 
-		for i, sp := range structFields {
-			var str string
-			isLast := i == len(structFields)-1
-			if i == 0 {
-				str = sp.GenerateSourceCodeForStructField(nil, isLast)
+		bld.WriteString(GenerateProvider(structFields))
+		bld.WriteString(GenerateBuilderFrom(structFields, fields))
+		bld.WriteString(GenerateSliceBuilder(structFields, fields))
+		bld.WriteString(GenerateCollector(structFields))
+		root := &StructField{
+			StructFlags: &structFlags,
+			StructName:  structName,
+			TypeParams:  typeParams,
+			TypeArgs:    typeArgs,
+		}
+		bld.WriteString(GenerateWithDefaults(root, structFields))
+		bld.WriteString(GenerateValidationError(root))
+		if len(structFields) > 0 {
+			stats.Builders++
+			fixTargets[structName] = &fixTarget{root: root, fields: fields, chain: structFields}
+		}
+		structFlags.WebFramework = opts.WebFramework
+		if structFlags.Bind && structFlags.Visibility != NoVisibility {
+			if opts.WebFramework == "echo" {
+				foreignImports["github.com/labstack/echo/v4"] = "echo"
 			} else {
-				str = sp.GenerateSourceCodeForStructField(structFields[i-1], isLast)
+				foreignImports["github.com/gin-gonic/gin"] = "gin"
+			}
+		}
+		if structFlags.TOML && structFlags.Visibility != NoVisibility {
+			// package is imported explicitly, because goimports cannot tell which TOML package is used
+			foreignImports[opts.TOMLPackage] = "toml"
+		}
+		if structFlags.Visibility != NoVisibility {
+			for _, generate := range []func(*StructField, []*StructField, []*StructField) (string, error){
+				GenerateEnvConstructor,
+				GenerateMapConstructor,
+				GenerateFormConstructor,
+				GenerateJSONDecoder,
+				GenerateYAMLUnmarshaler,
+				GenerateTOMLCodec,
+				GenerateBindHelper,
+			} {
+				constructor, err := generate(root, structFields, optionalFields)
+				if err != nil {
+					failed(err)
+				}
+				bld.WriteString(constructor)
 			}
-			bld.WriteString(str)
+		}
+		if structFlags.Proto != "" {
+			messageFields, err := sp.protoFields(astFile, filepath.Dir(inFilename), structFlags.Proto)
+			if err != nil {
+				failed(fmt.Errorf("struct %s: %w", structName, err))
+			}
+			converters, err := GenerateProtoConverters(root, fields, structFields, messageFields)
+			if err != nil {
+				failed(err)
+			}
+			bld.WriteString(converters)
+		}
+		scanner, err := GenerateRowScanner(root, fields, structFields)
+		if err != nil {
+			failed(err)
+		}
+		bld.WriteString(scanner)
+		bld.WriteString(GenerateColumnHelpers(root, fields))
+		csvHelpers, err := GenerateCSVHelpers(root, fields, structFields, optionalFields)
+		if err != nil {
+			failed(err)
+		}
+		bld.WriteString(csvHelpers)
+		bld.WriteString(GenerateStringer(root, fields))
+		bld.WriteString(GenerateMerge(root, fields))
+		bld.WriteString(GeneratePatch(root, fields))
+		bld.WriteString(GenerateImmutable(root, fields))
+		view, err := GenerateView(root, fields)
+		if err != nil {
+			failed(err)
+		}
+		bld.WriteString(view)
+		bld.WriteString(GenerateLogValuer(root, fields, structFields))
+		bld.WriteString(GenerateLogMarshaler(root, fields, structFields, opts.LogMarshaler))
+
+		groups, err := GroupStructFields(structFields)
+		if err != nil {
+			failed(err)
+		}
+		for _, group := range groups {
+			bld.WriteString(group.GenerateSourceCodeForGroup())
 		}
 		return true
 	})
+	// map fields can refer to structures declared after them, so their builders are generated once all
+	// structures are processed
+	for _, output := range outputs {
+		if target := fixTargets[output.structName]; target != nil {
+			output.code.WriteString(GenerateMapBuilders(target, fixTargets))
+		}
+	}
+
+	if diagnostics.Errors() > 0 {
+		// warnings are reported as errors in strict mode
+		r.exit(ExitAnnotation)
+	}
+	if r.fix {
+		if err = FixCompositeLiterals(r.stdout, filepath.Dir(inFilename), astFile.Name.Name, fixTargets, diagnostics); err != nil {
+			r.exitWithError(ExitWrite, err)
+		}
+		if diagnostics.Errors() > 0 {
+			r.exit(ExitAnnotation)
+		}
+		return
+	}
+	if r.migrate {
+		migration, err := NewRenameMigration(r.renames, fixTargets)
+		if err != nil {
+			r.exitWithError(ExitAnnotation, err)
+		}
+		if err = migration.MigrateCallSites(r.stdout, inFilename); err != nil {
+			r.exitWithError(ExitWrite, err)
+		}
+	}
+	for _, output := range outputs {
+		stats.Generated = append(stats.Generated, StructLines{Filename: inFilename, Struct: output.structName,
+			Lines: nonBlankLines(output.code.String())})
+	}
+	if benchmarks.Len() > 0 {
+		benchFilename := makeBenchFilename(outDir, inFilename)
+		result := GeneratePackage(astFile, signature, "") +
+			"import \"testing\"\n" +
+			GenerateBenchHeader(opts.AnyStyle) +
+			benchmarks.String()
+		r.writeOutputFiles(generatedFile{filename: benchFilename, content: []byte(result)})
+	}
+	mockDirective := GenerateMockDirective(astFile, inFilename, opts.MockTool, mockInterfaces)
+	if opts.Split == "struct" {
+		r.writeStructOutputs(outDir, signature, astFile, mockDirective, foreignImports, outputs)
+		return
+	}
+	body := strings.Builder{}
+	for _, output := range outputs {
+		body.WriteString(output.code.String())
+	}
+	regions, err := readCustomRegions(opts.OutFilename)
+	if err != nil {
+		r.exitWithError(ExitWrite, err)
+	}
+	// file is still parsed and processed on cache hit, so diagnostics and stats don't depend on cache, only
+	// computing imports and formatting are skipped. Cache entry holds a single file, so per-struct output is always
+	// regenerated, and imports of cached file don't cover custom regions, so file with regions is always regenerated.
+	cache := NewGenerationCache(opts.CacheDir)
+	if cached := cache.Get(signature); cached != nil && !opts.Force && regions == "" {
+		if err = replaceFile(opts.OutFilename, cached, opts.OutPerms); err != nil {
+			r.exitWithError(ExitWrite, err)
+		}
+		r.printf("Output file is restored from cache\n")
+		r.removeLegacyOutput()
+		return
+	}
+	code := withCustomRegions(body.String(), regions)
+	result := GeneratePackage(astFile, signature, "") +
+		mockDirective +
+		r.generateImports(astFile, foreignImports, regionImports(opts.OutFilename, regions), code) +
+		code
+	r.writeOutputFiles(generatedFile{filename: opts.OutFilename, content: []byte(result)})
+	r.removeLegacyOutput()
+	if formatted, err := os.ReadFile(opts.OutFilename); err == nil && regions == "" {
+		// file with custom regions is not cached, because its imports cover regions as well
+		cache.Put(signature, formatted)
+	}
+}
 
-	result := bld.String()
-	if err = ioutil.WriteFile(outFilename, []byte(result), os.FileMode(0644)); err != nil {
-		panic(err)
+// mayHaveAnnotatedStructs cheaply checks whether input file may produce any output before parsing it, in
+// annotated and tagged modes structures are processed only when file contains +gob: annotations
+func mayHaveAnnotatedStructs(generateFor *string, fileContent []byte) bool {
+	if generateFor != nil && *generateFor != "tagged" {
+		return true
+	}
+	return bytes.Contains(fileContent, []byte("+gob:"))
+}
+
+// removeStaleOutputs removes files previously generated from input file that has no structures to process
+func (r *fileRun) removeStaleOutputs(outDir string) {
+	if r.opts.Split == "struct" {
+		if err := RemoveStaleStructOutputs(r.stdout, outDir, r.opts.InFilename, nil); err != nil {
+			r.exitWithError(ExitWrite, err)
+		}
+		return
 	}
-	z := exec.Command("goimports", "-w", outFilename)
-	if err := z.Run(); err != nil {
-		log.Fatal(err)
+	if _, _, found := ReadSignature(r.opts.OutFilename); found {
+		if err := removeGeneratedFile(r.stdout, r.opts.OutFilename); err != nil {
+			r.exitWithError(ExitWrite, err)
+		}
+	}
+}
+
+// nonBlankLines returns number of non-blank lines of code, so counts don't depend on formatting of generated code
+func nonBlankLines(code string) int {
+	lines := 0
+	for _, line := range strings.Split(code, "\n") {
+		if strings.TrimSpace(line) != "" {
+			lines++
+		}
+	}
+	return lines
+}
+
+// removeLegacyOutput removes file generated for input file with _GOOS or _GOARCH suffix by previous versions of
+// gobetter (see makeLegacyOutputFilename), because it lacks build constraint and collides with other platforms
+func (r *fileRun) removeLegacyOutput() {
+	opts := r.opts
+	legacy := makeLegacyOutputFilename(opts.InFilename)
+	if opts.OutFilename != makeOutputFilename(opts.InFilename) || filepath.Clean(legacy) == filepath.Clean(opts.OutFilename) {
+		return
+	}
+	if _, _, generated := ReadSignature(legacy); generated {
+		if err := makeWritable(legacy); err != nil {
+			r.exitWithError(ExitWrite, err)
+		}
+		if err := os.Remove(legacy); err != nil {
+			r.exitWithError(ExitWrite, err)
+		}
+	}
+}
+
+type structOutput struct {
+	structName string
+	code       *strings.Builder
+}
+
+// writeStructOutputs writes code generated for every structure into its own file and removes files
+// generated from the same input file during previous runs for structures that were not generated now
+func (r *fileRun) writeStructOutputs(outDir string, signature string, astFile *ast.File, mockDirective string,
+	foreignImports map[string]string, outputs []structOutput) {
+	opts := r.opts
+	source := filepath.Base(opts.InFilename)
+	generated := make([]string, 0, len(outputs))
+	files := make([]generatedFile, 0, len(outputs))
+	for i, output := range outputs {
+		outFilename := makeStructOutputFilename(outDir, output.structName, opts.InFilename)
+		if _, err := os.Stat(outFilename); err == nil {
+			if fileSource, _ := ReadSource(outFilename); fileSource != source {
+				r.exitWithError(ExitWrite, fmt.Errorf("file %s already exists and was not generated from %s",
+					outFilename, source))
+			}
+		}
+		result := GeneratePackage(astFile, signature, source)
+		if i == 0 {
+			result += mockDirective
+		}
+		regions, err := readCustomRegions(outFilename)
+		if err != nil {
+			r.exitWithError(ExitWrite, err)
+		}
+		code := withCustomRegions(output.code.String(), regions)
+		result += r.generateImports(astFile, foreignImports, regionImports(outFilename, regions), code) + code
+		files = append(files, generatedFile{filename: outFilename, content: []byte(result)})
+		r.printf("Output file: %s\n", outFilename)
+		generated = append(generated, outFilename)
+	}
+	r.writeOutputFiles(files...)
+	if err := RemoveStaleStructOutputs(r.stdout, outDir, opts.InFilename, generated); err != nil {
+		r.exitWithError(ExitWrite, err)
+	}
+}
+
+// generateImports generates import block of output file. Without goimports processing only imports
+// referenced by generated code are added.
+func (r *fileRun) generateImports(astFile *ast.File, foreignImports map[string]string, regionImports []importSpec,
+	code string) string {
+	if r.opts.FixImports {
+		return GenerateImports(astFile, foreignImports, regionImports)
+	}
+	imports, err := GenerateUsedImports(r.packages, filepath.Dir(r.opts.InFilename), astFile, foreignImports,
+		regionImports, code, r.opts.LocalPrefix)
+	if err != nil {
+		r.exitWithError(ExitWrite, fmt.Errorf("failed to compute imports of generated code: %v", err))
+	}
+	return imports
+}
+
+// regionImports returns imports of previously generated file that custom regions may reference, or nil if file
+// has no custom regions
+func regionImports(filename string, regions string) []importSpec {
+	if regions == "" {
+		return nil
+	}
+	return readRegionImports(filename)
+}
+
+// generatedFile is content of generated file to be written by fileRun.writeOutputFiles
+type generatedFile struct {
+	filename string
+	content  []byte
+}
+
+// writeOutputFiles writes and formats generated files in temporary files in directories of output files, which
+// then replace output files. Output files are therefore never partially written, also when several gobetter
+// processes (e.g. started by parallel "go generate ./...") write the same files. Signature of output file is
+// checked again right before it is replaced, and file already generated with the same signature by another
+// process meanwhile is kept as is (unless generation is forced).
+func (r *fileRun) writeOutputFiles(files ...generatedFile) {
+	opts := r.opts
+	temps := make([]string, 0, len(files))
+	removeTemps := func() {
+		for _, temp := range temps {
+			_ = os.Remove(temp)
+		}
+	}
+	for _, file := range files {
+		temp, err := writeTempFile(file.filename, file.content)
+		if err != nil {
+			removeTemps()
+			r.exitWithError(ExitWrite, err)
+		}
+		temps = append(temps, temp)
+	}
+	if err := formatOutputFiles(opts, temps...); err != nil {
+		removeTemps()
+		r.exitWithError(ExitWrite, err)
+	}
+	for i, file := range files {
+		if !opts.Force && hasSameSignature(file.filename, temps[i]) {
+			_ = os.Remove(temps[i])
+			continue
+		}
+		if err := renameTempFile(temps[i], file.filename, opts.OutPerms); err != nil {
+			removeTemps()
+			r.exitWithError(ExitWrite, err)
+		}
+	}
+}
+
+// replaceFile atomically replaces content of file
+func replaceFile(filename string, content []byte, perms os.FileMode) error {
+	temp, err := writeTempFile(filename, content)
+	if err != nil {
+		return err
+	}
+	if err = renameTempFile(temp, filename, perms); err != nil {
+		_ = os.Remove(temp)
+	}
+	return err
+}
+
+// hasSameSignature reports whether existing file has the same signature as generated file that would replace it
+func hasSameSignature(filename string, generated string) bool {
+	version, hash, found := ReadSignature(filename)
+	generatedVersion, generatedHash, generatedFound := ReadSignature(generated)
+	return found && generatedFound && version == generatedVersion && hash == generatedHash
+}
+
+// renameTempFile sets permissions of temporary file and replaces file with it. File being replaced is made
+// writable first, because read-only files cannot be replaced on some systems (e.g. on Windows).
+func renameTempFile(temp string, filename string, perms os.FileMode) error {
+	if err := os.Chmod(temp, perms); err != nil {
+		return err
+	}
+	if err := makeWritable(filename); err != nil {
+		return err
+	}
+	return os.Rename(temp, filename)
+}
+
+// makeWritable makes existing file writable by owner, e.g. before it is replaced or removed
+func makeWritable(filename string) error {
+	info, err := os.Stat(filename)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode().Perm()&0o200 != 0 {
+		return nil
+	}
+	return os.Chmod(filename, info.Mode().Perm()|0o200)
+}
+
+// writeTempFile writes content into new temporary file next to file it replaces, name of temporary file starts
+// with dot, so it is ignored by go command
+func writeTempFile(filename string, content []byte) (string, error) {
+	temp, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".*")
+	if err != nil {
+		return "", err
+	}
+	_, err = temp.Write(content)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(temp.Name(), os.FileMode(0644))
+	}
+	if err != nil {
+		_ = os.Remove(temp.Name())
+		return "", err
+	}
+	return temp.Name(), nil
+}
+
+// formatOutputFiles post-processes generated files in place with selected formatter
+func formatOutputFiles(opts CommandLineOptions, files ...string) error {
+	if opts.Formatter == "none" || len(files) == 0 {
+		return nil
+	}
+	if opts.FixImports {
+		args := []string{"-w"}
+		if opts.LocalPrefix != "" {
+			args = append(args, "-local", opts.LocalPrefix)
+		}
+		z := exec.Command("goimports", append(args, files...)...)
+		if err := z.Run(); err != nil {
+			return err
+		}
+	} else {
+		for _, file := range files {
+			content, err := os.ReadFile(file)
+			if err != nil {
+				return err
+			}
+			formatted, err := format.Source(content)
+			if err != nil {
+				return err
+			}
+			if err = ioutil.WriteFile(file, formatted, os.FileMode(0644)); err != nil {
+				return err
+			}
+		}
+	}
+	if opts.Formatter == "gofumpt" {
+		z := exec.Command("gofumpt", append([]string{"-w"}, files...)...)
+		if err := z.Run(); err != nil {
+			return err
+		}
 	}
+	return nil
 }
@@ -0,0 +1,454 @@
+package main
+
+import (
+	"bytes"
+	"go/build"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// generateTestModule writes files of module into temporary directory and generates code for its input file with
+// command-line arguments, generation must succeed. Returns directory of module.
+func generateTestModule(t *testing.T, files map[string]string, input string, args ...string) string {
+	t.Helper()
+	dir := writeTestModule(t, files)
+	generateTestModuleFile(t, dir, input, args...)
+	return dir
+}
+
+// generateTestFile generates code for input file of test module with command-line arguments, returns exit code
+// and diagnostics of generation
+func generateTestFile(t *testing.T, dir string, input string, args ...string) (int, string) {
+	t.Helper()
+	args = append([]string{"-input", filepath.Join(dir, input), "-cache-dir", "off"}, args...)
+	var stdout, stderr bytes.Buffer
+	opts := parseCommandLineArgs(args)
+	run := &fileRun{
+		opts:     opts,
+		args:     args,
+		packages: NewPackageCache(opts.Overlay),
+		stdout:   &stdout,
+		stderr:   &stderr,
+	}
+	return run.generate(), stderr.String()
+}
+
+// generateTestModuleFile generates code for input file of test module written before, generation must succeed
+func generateTestModuleFile(t *testing.T, dir string, input string, args ...string) {
+	t.Helper()
+	if code, diagnostics := generateTestFile(t, dir, input, args...); code != 0 {
+		t.Fatalf("exit code %d:\n%s", code, diagnostics)
+	}
+}
+
+// withTestFile returns copy of files of test module with file added or replaced
+func withTestFile(files map[string]string, name string, content string) map[string]string {
+	result := map[string]string{name: content}
+	for fileName, fileContent := range files {
+		if fileName != name {
+			result[fileName] = fileContent
+		}
+	}
+	return result
+}
+
+// readTestFile returns content of file of test module
+func readTestFile(t *testing.T, dir string, name string) string {
+	t.Helper()
+	content, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+// runTestModule runs main package of test module with "go run", so generated code is verified to compile
+// together with code using it and to behave as expected. Returns output of program.
+func runTestModule(t *testing.T, dir string) string {
+	t.Helper()
+	cmd := exec.Command("go", "run", ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOWORK=off", "GOFLAGS=-mod=mod")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("go run failed: %v\n%s", err, output)
+	}
+	return string(output)
+}
+
+// assertOutput compares output of test program with expected lines
+func assertOutput(t *testing.T, output string, expected ...string) {
+	t.Helper()
+	if strings.TrimSpace(output) != strings.Join(expected, "\n") {
+		t.Errorf("expected output:\n%s\ngot:\n%s", strings.Join(expected, "\n"), output)
+	}
+}
+
+const testModule = "module t9\n\ngo 1.18\n"
+
+func TestGenericStructBuilder(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod": testModule,
+		"pair.go": `package main
+
+type Number interface {
+	~int | ~int64 | float64
+}
+
+type Pair[K comparable, V Number] struct { //+gob:Constructor
+	Key   K
+	Value V
+}
+`,
+		"main.go": `package main
+
+import "fmt"
+
+type Score int64
+
+func main() {
+	p := NewPairBuilder[string, Score]().Key("a").Value(5).Build()
+	q := NewPairBuilder[int, float64]().Key(1).Value(2.5).Build()
+	fmt.Println(p.Key, p.Value, q.Key, q.Value)
+}
+`,
+	}, "pair.go")
+
+	assertOutput(t, runTestModule(t, dir), "a 5 1 2.5")
+}
+
+func TestGenericInnerStructBuilder(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod": testModule,
+		"pair.go": `package main
+
+type Pair[K comparable, V ~int | ~string] struct { //+gob:Constructor
+	Key   K
+	Inner struct {
+		// value of pair
+		Val   V
+		Other map[K][]V
+	}
+}
+`,
+		"main.go": `package main
+
+import "fmt"
+
+func main() {
+	inner := struct {
+		// value of pair
+		Val   string
+		Other map[int][]string
+	}{Val: "v", Other: map[int][]string{1: {"x"}}}
+	p := NewPairBuilder[int, string]().Key(1).Inner(inner).Build()
+	fmt.Println(p.Key, p.Inner.Val, p.Inner.Other[1][0])
+}
+`,
+	}, "pair.go")
+
+	assertOutput(t, runTestModule(t, dir), "1 v x")
+}
+
+func TestConstructorDocOmitsCommentsOfInnerStruct(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod": testModule,
+		"pair.go": `package main
+
+type Pair[K comparable, V any] struct { //+gob:Constructor
+	Inner struct {
+		// val is value
+		val   V
+		other []K // other keys
+	}
+	Note string //+gob:_
+}
+`,
+	}, "pair.go")
+
+	generated := readTestFile(t, dir, "pair_gob.go")
+	if !strings.Contains(generated, "//   - Inner(struct { val V; other []K })\n") {
+		t.Errorf("constructor doc lacks comment-free type of inner struct field:\n%s", generated)
+	}
+	if strings.Contains(generated, "// val is value V") || strings.Contains(generated, "// other keys }") {
+		t.Errorf("constructor doc contains comments of inner struct fields:\n%s", generated)
+	}
+}
+
+func TestDefaultsAreSetWhenFieldsAreZero(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod": testModule,
+		"server.go": `package main
+
+import "time"
+
+type Server struct { //+gob:Constructor +gob:from
+	Host    string
+	Port    int           //+gob:default=8080
+	Name    string        //+gob:default="server"
+	Verbose bool          //+gob:default=true
+	Tags    []string      //+gob:default=[]string{"a"}
+	Created time.Time     //+gob:default=time.Unix(1, 0).UTC()
+	Timeout time.Duration //+gob:default=time.Second
+}
+`,
+		"main.go": `package main
+
+import "fmt"
+
+func main() {
+	s := NewServerBuilder().Host("a").Build()
+	fmt.Println(s.Port, s.Name, s.Verbose, s.Tags, s.Created.Unix(), s.Timeout)
+	s.Port, s.Name = 1, "copy"
+	c := ServerBuilderFrom(*s).Build()
+	fmt.Println(c.Port, c.Name)
+}
+`,
+	}, "server.go")
+
+	assertOutput(t, runTestModule(t, dir), "8080 server true [a] 1 1s", "1 copy")
+	generated := readTestFile(t, dir, "server_gob.go")
+	for _, check := range []string{
+		"if v.Port == 0 {",
+		`if v.Name == "" {`,
+		"if !v.Verbose {",
+		"if v.Tags == nil {",
+		// types of other packages are not known to be comparable
+		"if reflect.ValueOf(&v.Created).Elem().IsZero() {",
+	} {
+		if !strings.Contains(generated, check) {
+			t.Errorf("generated Build() lacks check %q:\n%s", check, generated)
+		}
+	}
+}
+
+func TestMustGettersPanicOnZeroFields(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod": testModule,
+		"person.go": `package main
+
+type Person struct { //+gob:Constructor
+	name    string   //+gob:getter
+	age     int      //+gob:getter
+	emails  []string //+gob:getter
+	address struct { //+gob:getter
+		city string
+	}
+}
+`,
+		"main.go": `package main
+
+import "fmt"
+
+func main() {
+	p := NewPersonBuilder().Name("joe").Age(0).Emails(nil).Address(struct{ city string }{"x"}).Build()
+	fmt.Println(p.MustName(), p.MustAddress().city)
+	for _, get := range []func(){func() { p.MustAge() }, func() { p.MustEmails() }} {
+		func() {
+			defer func() { fmt.Println(recover()) }()
+			get()
+		}()
+	}
+}
+`,
+	}, "person.go", "-getter-style", "must")
+
+	assertOutput(t, runTestModule(t, dir), "joe x", "Person.age is not set", "Person.emails is not set")
+	generated := readTestFile(t, dir, "person_gob.go")
+	for _, check := range []string{
+		`if v.name == "" {`,
+		"if v.age == 0 {",
+		"if v.emails == nil {",
+		"if reflect.ValueOf(&v.address).Elem().IsZero() {",
+	} {
+		if !strings.Contains(generated, check) {
+			t.Errorf("generated Must getter lacks check %q:\n%s", check, generated)
+		}
+	}
+}
+
+func TestFormatterPostProcessesGeneratedFiles(t *testing.T) {
+	files := map[string]string{
+		"go.mod":    testModule,
+		"person.go": "package main\n\ntype Person struct { //+gob:Constructor\n\tName string\n}\n",
+	}
+	if generated := readTestFile(t, generateTestModule(t, files, "person.go", "-formatter", "none"),
+		"person_gob.go"); !strings.Contains(generated, "\n    return") {
+		t.Errorf("generated file is formatted with -formatter none:\n%s", generated)
+	}
+	if generated := readTestFile(t, generateTestModule(t, files, "person.go"), "person_gob.go"); strings.Contains(
+		generated, "\n    return") {
+		t.Errorf("generated file is not formatted:\n%s", generated)
+	}
+
+	if runtime.GOOS == "windows" {
+		t.Skip("fake gofumpt is a shell script")
+	}
+	// fake gofumpt marks files it is run for
+	bin := writeTestModule(t, map[string]string{
+		"gofumpt": "#!/bin/sh\nshift\nfor f in \"$@\"; do echo '// gofumpt' >> \"$f\"; done\n",
+	})
+	if err := os.Chmod(filepath.Join(bin, "gofumpt"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	if generated := readTestFile(t, generateTestModule(t, files, "person.go", "-formatter", "gofumpt"),
+		"person_gob.go"); !strings.HasSuffix(generated, "// gofumpt\n") || strings.Contains(generated, "\n    return") {
+		t.Errorf("generated file is not formatted with gofmt and gofumpt:\n%s", generated)
+	}
+}
+
+func TestWithDefaultsConstructor(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod": testModule,
+		"server.go": `package main
+
+type Server struct { //+gob:Constructor
+	Host string
+	Port int    //+gob:default=8080
+	Name string //+gob:default="server"
+}
+
+type Limits struct { //+gob:Constructor
+	Max  int //+gob:default=10
+	Idle int //+gob:_
+}
+`,
+		"main.go": `package main
+
+import "fmt"
+
+func main() {
+	fmt.Println(*NewServerWithDefaults().Host("a").Build(), *NewLimitsWithDefaults())
+}
+`,
+	}, "server.go")
+
+	assertOutput(t, runTestModule(t, dir), "{a 8080 server} {10 0}")
+}
+
+func TestOutputFilenamesKeepPlatformSuffixes(t *testing.T) {
+	ctx := build.Default
+	ctx.GOOS, ctx.GOARCH = "windows", "arm64"
+	ctx.OpenFile = func(string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader("package main\n")), nil
+	}
+	for input, expected := range map[string]string{
+		"dir/config.go":                   "dir/config_gob.go",
+		"dir/config_linux.go":             "dir/config_gob_linux.go",
+		"dir/config_linux_amd64.go":       "dir/config_gob_linux_amd64.go",
+		"dir/config_arm64.go":             "dir/config_gob_arm64.go",
+		"dir/linux.go":                    "dir/linux_gob.go",
+		"dir/server_config_windows.go":    "dir/server_config_gob_windows.go",
+		"dir/config_linux_unknownarch.go": "dir/config_linux_unknownarch_gob.go",
+	} {
+		if output := makeOutputFilename(input); output != expected {
+			t.Errorf("%s: expected %s, got %s", input, expected, output)
+		}
+		// build constraints implied by names of input and output files are the same
+		for _, name := range []string{input, expected} {
+			inMatch, _ := ctx.MatchFile(".", filepath.Base(input))
+			if outMatch, _ := ctx.MatchFile(".", filepath.Base(name)); inMatch != outMatch {
+				t.Errorf("%s: build constraint of %s differs", input, name)
+			}
+		}
+	}
+	if bench := makeBenchFilename("dir", "dir/config_linux.go"); bench != filepath.Join("dir", "config_gob_bench_linux_test.go") {
+		t.Errorf("unexpected benchmark file name %s", bench)
+	}
+}
+
+func TestConcurrentGenerationsWriteCompleteFiles(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod":    testModule,
+		"person.go": "package main\n\ntype Person struct { //+gob:Constructor\n\tName string\n\tAge  int\n}\n",
+	}, "person.go")
+	expected := readTestFile(t, dir, "person_gob.go")
+
+	codes := make(chan int, 8)
+	for i := 0; i < cap(codes); i++ {
+		go func() {
+			code, _ := generateTestFile(t, dir, "person.go", "-force")
+			codes <- code
+		}()
+	}
+	for i := 0; i < cap(codes); i++ {
+		if code := <-codes; code != 0 {
+			t.Errorf("exit code %d", code)
+		}
+	}
+	if generated := readTestFile(t, dir, "person_gob.go"); generated != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, generated)
+	}
+	// temporary files are renamed to output file or removed
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Errorf("unexpected files in module directory: %v", entries)
+	}
+}
+
+func TestPermissionsOfGeneratedFiles(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file permissions are not supported on Windows")
+	}
+	dir := writeTestModule(t, map[string]string{
+		"go.mod":    testModule,
+		"person.go": "package main\n\ntype Person struct { //+gob:Constructor\n\tName string\n}\n",
+	})
+	outFilename := filepath.Join(dir, "person_gob.go")
+	for _, test := range []struct {
+		args  []string
+		perms os.FileMode
+	}{
+		{perms: 0o644},
+		{args: []string{"-read-only"}, perms: 0o444},
+		// read-only file is replaced
+		{args: []string{"-out-perms", "0640"}, perms: 0o640},
+	} {
+		generateTestModuleFile(t, dir, "person.go", append(test.args, "-force")...)
+		info, err := os.Stat(outFilename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if perms := info.Mode().Perm(); perms != test.perms {
+			t.Errorf("%v: expected permissions %o, got %o", test.args, test.perms, perms)
+		}
+	}
+}
+
+func TestGenericStructWithInlineUnionConstraint(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod": testModule,
+		"box.go": `package main
+
+type Box[T ~int | ~string, P interface{ ~[]T }] struct { //+gob:Constructor
+	Value T
+	Items P
+}
+`,
+		"main.go": `package main
+
+import "fmt"
+
+type Name string
+
+func main() {
+	b := NewBoxBuilder[Name, []Name]().Value("a").Items([]Name{"b"}).Build()
+	fmt.Println(b.Value, b.Items)
+}
+`,
+	}, "box.go")
+
+	assertOutput(t, runTestModule(t, dir), "a [b]")
+	if generated := readTestFile(t, dir, "box_gob.go"); !strings.Contains(generated, "[T ~int | ~string, P interface{ ~[]T }]") {
+		t.Errorf("type parameters are not rendered as declared:\n%s", generated)
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// fieldRename is renaming of structure field passed to "gobetter migrate" as "-rename [Struct.]old=new"
+type fieldRename struct {
+	structName string
+	oldName    string
+	newName    string
+}
+
+// identifierEdit replaces identifier at position with new name
+type identifierEdit struct {
+	pos     token.Pos
+	end     token.Pos
+	newName string
+}
+
+// ExtractRenames removes "-rename" arguments from command-line arguments, so the rest of arguments are the same
+// as arguments of generation and generated code has the same signature as if it was generated by "go generate"
+func ExtractRenames(args []string) (renames []fieldRename, rest []string, err error) {
+	rest = make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		name, value, hasValue := strings.Cut(strings.TrimLeft(args[i], "-"), "=")
+		if !strings.HasPrefix(args[i], "-") || name != "rename" {
+			rest = append(rest, args[i])
+			continue
+		}
+		if !hasValue {
+			if i+1 == len(args) {
+				return nil, nil, fmt.Errorf("\"rename\" flag must have value in form of [Struct.]old=new")
+			}
+			i++
+			value = args[i]
+		}
+		oldName, newName, found := strings.Cut(value, "=")
+		if !found || oldName == "" || newName == "" {
+			return nil, nil, fmt.Errorf("\"rename\" flag must have value in form of [Struct.]old=new, got %q", value)
+		}
+		rename := fieldRename{oldName: oldName, newName: newName}
+		if structName, fieldName, qualified := strings.Cut(oldName, "."); qualified {
+			rename.structName, rename.oldName = structName, fieldName
+		}
+		renames = append(renames, rename)
+	}
+	return renames, rest, nil
+}
+
+// renameMigration holds old and new names of builder chain identifiers affected by field renames
+type renameMigration struct {
+	// setters maps old setter name to new one for every constructor of builder chain
+	setters map[string]map[string]string
+	// types maps old builder type name to new one
+	types map[string]string
+}
+
+// NewRenameMigration computes old and new names of setters and builder types of renamed fields, every renamed
+// field must be part of builder chain of some structure
+func NewRenameMigration(renames []fieldRename, targets map[string]*fixTarget) (*renameMigration, error) {
+	setters := make(map[string]map[string]string)
+	types := make(map[string]string)
+	for _, rename := range renames {
+		migrated := false
+		for structName, target := range targets {
+			if rename.structName != "" && rename.structName != structName {
+				continue
+			}
+			for _, sf := range target.chain {
+				if sf.FieldName != rename.newName {
+					continue
+				}
+				old := *sf
+				old.FieldName = rename.oldName
+				constructor := target.root.constructorName() + "Builder"
+				if setters[constructor] == nil {
+					setters[constructor] = make(map[string]string)
+				}
+				setters[constructor][old.setterName()] = sf.setterName()
+				types[old.builderFieldStructName()] = sf.builderFieldStructName()
+				migrated = true
+			}
+		}
+		if !migrated {
+			return nil, fmt.Errorf("field %s renamed from %s is not part of builder chain of any structure",
+				rename.newName, rename.oldName)
+		}
+	}
+	return &renameMigration{setters: setters, types: types}, nil
+}
+
+// MigrateCallSites rewrites builder chain call sites of renamed fields (setters and builder types) in all
+// non-generated files of module containing input file
+func (m *renameMigration) MigrateCallSites(out io.Writer, inFilename string) error {
+	root := moduleRoot(filepath.Dir(inFilename))
+	return filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if path != root && isIgnoredDir(entry.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".go") {
+			return nil
+		}
+		for _, file := range parsePackageFiles([]string{path}) {
+			if file.generated {
+				continue
+			}
+			edits := findRenameEdits(file, m.setters, m.types)
+			if len(edits) == 0 {
+				continue
+			}
+			formatted, err := format.Source([]byte(applyEdits(file, edits)))
+			if err != nil {
+				return fmt.Errorf("failed to format migrated file %s: %v", path, err)
+			}
+			if err = os.WriteFile(path, formatted, os.FileMode(0644)); err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintf(out, "Migrate %d call site(s) in %s\n", len(edits), path)
+		}
+		return nil
+	})
+}
+
+// moduleRoot returns directory containing go.mod file of module that contains directory, or directory itself
+// if it is not part of any module
+func moduleRoot(dir string) string {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return dir
+	}
+	for current := abs; ; current = filepath.Dir(current) {
+		if _, err := os.Stat(filepath.Join(current, "go.mod")); err == nil {
+			return current
+		}
+		if filepath.Dir(current) == current {
+			return dir
+		}
+	}
+}
+
+func findRenameEdits(file *parsedFile, setters map[string]map[string]string, types map[string]string) []identifierEdit {
+	edits := make([]identifierEdit, 0)
+	ast.Inspect(file.astFile, func(n ast.Node) bool {
+		switch t := n.(type) {
+		case *ast.CallExpr:
+			sel, ok := t.Fun.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			for constructor, renamed := range setters {
+				if newName, found := renamed[sel.Sel.Name]; found && chainStartsWith(sel.X, constructor) {
+					edits = append(edits, identifierEdit{pos: sel.Sel.Pos(), end: sel.Sel.End(), newName: newName})
+				}
+			}
+		case *ast.Ident:
+			if newName, found := types[t.Name]; found {
+				edits = append(edits, identifierEdit{pos: t.Pos(), end: t.End(), newName: newName})
+			}
+		}
+		return true
+	})
+	sort.Slice(edits, func(i, j int) bool {
+		return edits[i].pos < edits[j].pos
+	})
+	return edits
+}
+
+// chainStartsWith reports whether chain of method calls (e.g. NewPersonBuilder().FirstName("a")) starts with
+// call of constructor, possibly qualified with package name or instantiated with type arguments
+func chainStartsWith(expr ast.Expr, constructor string) bool {
+	for {
+		call, ok := expr.(*ast.CallExpr)
+		if !ok {
+			return false
+		}
+		fun := call.Fun
+		switch t := fun.(type) {
+		case *ast.IndexExpr:
+			fun = t.X
+		case *ast.IndexListExpr:
+			fun = t.X
+		}
+		switch t := fun.(type) {
+		case *ast.Ident:
+			return t.Name == constructor
+		case *ast.SelectorExpr:
+			if t.Sel.Name == constructor {
+				return true
+			}
+			expr = t.X
+		default:
+			return false
+		}
+	}
+}
+
+func applyEdits(file *parsedFile, edits []identifierEdit) string {
+	bld := &strings.Builder{}
+	cursor := token.Pos(file.fileSet.File(file.astFile.Pos()).Base())
+	for _, edit := range edits {
+		bld.WriteString(file.source(cursor, edit.pos))
+		bld.WriteString(edit.newName)
+		cursor = edit.end
+	}
+	bld.WriteString(file.source(cursor, cursor+token.Pos(len(file.content)-file.offset(cursor))))
+	return bld.String()
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMigrateCallSites(t *testing.T) {
+	runGoldenTest(t, filepath.Join("testdata", "migrate"), nil, func(run *fileRun) {
+		run.migrate = true
+		run.renames = []fieldRename{{oldName: "FirstName", newName: "GivenName"}}
+	})
+}
+
+func TestExtractRenames(t *testing.T) {
+	renames, rest, err := ExtractRenames([]string{
+		"-input", "person.go", "-rename", "FirstName=GivenName", "--rename=Person.Age=Years", "-getter-style", "get",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedRenames := []fieldRename{
+		{oldName: "FirstName", newName: "GivenName"},
+		{structName: "Person", oldName: "Age", newName: "Years"},
+	}
+	if !reflect.DeepEqual(renames, expectedRenames) {
+		t.Errorf("expected renames %+v, got %+v", expectedRenames, renames)
+	}
+	if expectedRest := []string{"-input", "person.go", "-getter-style", "get"}; !reflect.DeepEqual(rest, expectedRest) {
+		t.Errorf("expected rest of arguments %q, got %q", expectedRest, rest)
+	}
+
+	for _, args := range [][]string{{"-rename"}, {"-rename", "FirstName"}, {"-rename=FirstName="}, {"-rename==GivenName"}} {
+		if _, _, err := ExtractRenames(args); err == nil {
+			t.Errorf("expected error for arguments %q", args)
+		}
+	}
+}
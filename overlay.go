@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Overlay replaces contents of files on disk (e.g. with unsaved editor buffers), nil content means that file is
+// deleted. Keys are absolute paths of files.
+type Overlay map[string][]byte
+
+// LoadOverlay loads overlay from JSON file in the same format as "-overlay" flag of go command uses, i.e.
+// {"Replace": {"<path of file>": "<path of file with its contents>"}}, where empty replacement path deletes file
+func LoadOverlay(filename string) (Overlay, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var spec struct {
+		Replace map[string]string
+	}
+	if err = json.Unmarshal(content, &spec); err != nil {
+		return nil, err
+	}
+	overlay := make(Overlay)
+	for path, replacement := range spec.Replace {
+		path, err = filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		if replacement == "" {
+			overlay[path] = nil
+			continue
+		}
+		if overlay[path], err = os.ReadFile(replacement); err != nil {
+			return nil, err
+		}
+	}
+	return overlay, nil
+}
+
+func (o Overlay) lookup(filename string) (content []byte, found bool) {
+	path, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, false
+	}
+	content, found = o[path]
+	return content, found
+}
+
+// ReadFile reads file from overlay or from disk if it is not overlaid
+func (o Overlay) ReadFile(filename string) ([]byte, error) {
+	content, found := o.lookup(filename)
+	if !found {
+		return os.ReadFile(filename)
+	}
+	if content == nil {
+		return nil, &fs.PathError{Op: "open", Path: filename, Err: fs.ErrNotExist}
+	}
+	return content, nil
+}
+
+// ImportFrom imports package with importer, packages containing overlaid files are type-checked from their
+// (overlaid) sources instead and cached in checked by their directories. Hooks of go/build context cannot be
+// used for this, because go/build ignores modules when they are set.
+func (o Overlay) ImportFrom(fset *token.FileSet, imp types.ImporterFrom, path string, srcDir string,
+	checked map[string]*types.Package) (*types.Package, error) {
+	if len(o) == 0 {
+		return imp.ImportFrom(path, srcDir, 0)
+	}
+	srcDir, err := filepath.Abs(srcDir)
+	if err != nil {
+		return nil, err
+	}
+	bp, err := buildContext(srcDir).Import(path, srcDir, 0)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := filepath.Abs(bp.Dir)
+	if err != nil {
+		return nil, err
+	}
+	filenames := make(map[string]bool)
+	for _, name := range bp.GoFiles {
+		filenames[filepath.Join(dir, name)] = true
+	}
+	overlaid := false
+	for filename := range o {
+		if filepath.Dir(filename) == dir {
+			overlaid = true
+			if strings.HasSuffix(filename, ".go") && !strings.HasSuffix(filename, "_test.go") {
+				filenames[filename] = true
+			}
+		}
+	}
+	if !overlaid {
+		return imp.ImportFrom(path, srcDir, 0)
+	}
+	if pkg, found := checked[dir]; found {
+		return pkg, nil
+	}
+	files := make([]*ast.File, 0, len(filenames))
+	for filename := range filenames {
+		content, err := o.ReadFile(filename)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		file, err := parser.ParseFile(fset, filename, content, 0)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	conf := types.Config{Importer: imp}
+	pkg, err := conf.Check(bp.ImportPath, fset, files, nil)
+	if err != nil {
+		return nil, err
+	}
+	checked[dir] = pkg
+	return pkg, nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOverlayReplacesInputAndImportedFiles(t *testing.T) {
+	dir := writeTestModule(t, map[string]string{
+		"go.mod":     testModule,
+		"pb/user.go": "package pb\n\ntype User struct {\n\tName string\n}\n",
+		"user.go":    "package main\n\ntype User struct {\n\tName string\n}\n",
+		"edits/user.go": `package main
+
+import "t9/pb"
+
+var _ pb.User
+
+type User struct { //+gob:Constructor +gob:proto=pb.User
+	Name  string
+	Phone string
+}
+`,
+		"edits/pb.go":  "package pb\n\ntype User struct {\n\tName  string\n\tPhone string\n}\n",
+		"overlay.json": `{"Replace": {"user.go": "edits/user.go", "pb/user.go": "edits/pb.go"}}`,
+	})
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// relative paths of overlay are resolved against working directory
+	if err = os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.Chdir(wd) }()
+
+	generateTestModuleFile(t, dir, "user.go", "-overlay", filepath.Join(dir, "overlay.json"))
+	generated := readTestFile(t, dir, "user_gob.go")
+	if !strings.Contains(generated, "Phone: v.Phone,") {
+		t.Errorf("generated file does not reflect overlaid files:\n%s", generated)
+	}
+}
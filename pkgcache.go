@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// PackageCache loads packages that generated code is checked against once per run: imported packages are
+// type-checked from source and files of package directories are parsed only the first time they are needed,
+// all with the same file set. It is shared by all files of directory input, so it is safe for concurrent use.
+type PackageCache struct {
+	fileSet *token.FileSet
+	overlay Overlay
+
+	// mu guards importer, which is not safe for concurrent use, and maps below
+	mu       sync.Mutex
+	importer *sourceImporter
+	// overlaid holds packages type-checked from overlaid sources by their directories, other packages are
+	// cached by importer itself
+	overlaid map[string]*types.Package
+	// dirs holds parsed source files of directories, see DirFiles
+	dirs map[string][]*ast.File
+}
+
+func NewPackageCache(overlay Overlay) *PackageCache {
+	fileSet := token.NewFileSet()
+	return &PackageCache{
+		fileSet:  fileSet,
+		overlay:  overlay,
+		importer: newSourceImporter(fileSet),
+		overlaid: make(map[string]*types.Package),
+		dirs:     make(map[string][]*ast.File),
+	}
+}
+
+// FileSet returns file set of all files parsed during run, input files are parsed with it as well
+func (c *PackageCache) FileSet() *token.FileSet {
+	return c.fileSet
+}
+
+// Import imports package (from overlay if it has overlaid files) as seen from source directory
+func (c *PackageCache) Import(path string, srcDir string) (*types.Package, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.overlay.ImportFrom(c.fileSet, c.importer, path, srcDir, c.overlaid)
+}
+
+// DirFiles returns parsed source files of directory except test files and files generated by gobetter, files of
+// all packages in directory are returned. Files are parsed without comments.
+func (c *PackageCache) DirFiles(dir string) []*ast.File {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if files, found := c.dirs[dir]; found {
+		return files
+	}
+	files := make([]*ast.File, 0)
+	filenames, _ := filepath.Glob(filepath.Join(dir, "*.go"))
+	for _, filename := range filenames {
+		if strings.HasSuffix(filename, "_test.go") {
+			continue
+		}
+		content, err := c.overlay.ReadFile(filename)
+		if err != nil || bytes.Contains(content, []byte("// Code generated by gobetter")) {
+			continue
+		}
+		file, err := parser.ParseFile(c.fileSet, filename, content, 0)
+		if err != nil {
+			continue
+		}
+		files = append(files, file)
+	}
+	c.dirs[dir] = files
+	return files
+}
+
+// PackageName returns name of package imported by path from source directory. Package is located the same way
+// importer locates it, but only package clauses of its files are read, so it is not type-checked.
+func (c *PackageCache) PackageName(path string, srcDir string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	bp, err := c.importer.locate(path, srcDir)
+	if err != nil {
+		return "", err
+	}
+	return bp.Name, nil
+}
+
+// sourceImporter type-checks imported packages from their sources the same way as importer.ForCompiler(fset,
+// "source", nil) does, but go command resolving import paths in module mode is run in directory of importing
+// package rather than in working directory, so packages of every module are found wherever gobetter is run.
+// Function bodies of imported packages are not checked.
+type sourceImporter struct {
+	fileSet *token.FileSet
+	// located holds located packages by source directory and import path
+	located map[string]*build.Package
+	// packages holds imported packages by their directories, because the same import path may refer to different
+	// packages in different modules (e.g. replaced differently), nil package marks package being imported
+	packages map[string]*types.Package
+}
+
+func newSourceImporter(fileSet *token.FileSet) *sourceImporter {
+	return &sourceImporter{
+		fileSet:  fileSet,
+		located:  make(map[string]*build.Package),
+		packages: make(map[string]*types.Package),
+	}
+}
+
+func (p *sourceImporter) Import(path string) (*types.Package, error) {
+	return p.ImportFrom(path, ".", 0)
+}
+
+func (p *sourceImporter) ImportFrom(path string, srcDir string, _ types.ImportMode) (*types.Package, error) {
+	if path == "unsafe" {
+		return types.Unsafe, nil
+	}
+	bp, err := p.locate(path, srcDir)
+	if err != nil {
+		return nil, err
+	}
+	if pkg, found := p.packages[bp.Dir]; found {
+		if pkg == nil {
+			return nil, fmt.Errorf("import cycle through package %s", bp.ImportPath)
+		}
+		return pkg, nil
+	}
+	p.packages[bp.Dir] = nil
+	defer func() {
+		if p.packages[bp.Dir] == nil {
+			delete(p.packages, bp.Dir)
+		}
+	}()
+
+	files := make([]*ast.File, 0, len(bp.GoFiles)+len(bp.CgoFiles))
+	for _, name := range append(append([]string{}, bp.GoFiles...), bp.CgoFiles...) {
+		file, err := parser.ParseFile(p.fileSet, filepath.Join(bp.Dir, name), nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	// only hard errors fail import, e.g. unused variables in imported package do not
+	var hardErr error
+	conf := types.Config{
+		Importer:         p,
+		FakeImportC:      true,
+		IgnoreFuncBodies: true,
+		Error: func(err error) {
+			if typeErr, ok := err.(types.Error); hardErr == nil && (!ok || !typeErr.Soft) {
+				hardErr = err
+			}
+		},
+	}
+	pkg, _ := conf.Check(bp.ImportPath, p.fileSet, files, nil)
+	if hardErr != nil {
+		return nil, fmt.Errorf("type-checking package %q failed (%v)", bp.ImportPath, hardErr)
+	}
+	pkg.MarkComplete()
+	p.packages[bp.Dir] = pkg
+	return pkg, nil
+}
+
+// locate locates package imported by path from source directory without type-checking it
+func (p *sourceImporter) locate(path string, srcDir string) (*build.Package, error) {
+	srcDir, err := filepath.Abs(srcDir)
+	if err != nil {
+		return nil, err
+	}
+	key := srcDir + "\x00" + path
+	if bp, found := p.located[key]; found {
+		return bp, nil
+	}
+	bp, err := buildContext(srcDir).Import(path, srcDir, 0)
+	if err != nil {
+		return nil, err
+	}
+	p.located[key] = bp
+	return bp, nil
+}
+
+// buildContext returns default build context running go command in source directory rather than in working
+// one, so import paths are resolved in module of source directory
+func buildContext(srcDir string) *build.Context {
+	ctxt := build.Default
+	ctxt.Dir = srcDir
+	return &ctxt
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestPackageCacheLoadsPackagesOnce(t *testing.T) {
+	dir := writeTestModule(t, map[string]string{
+		"go.mod":         testModule,
+		"model/model.go": "package model\n\ntype ID string\n",
+		"a.go":           "package main\n\nimport \"t9/model\"\n\ntype A struct {\n\tID model.ID\n}\n",
+		"b.go":           "package main\n\ntype B struct{}\n",
+		"a_gob.go":       "// Code generated by gobetter; DO NOT EDIT.\n\npackage main\n",
+		"a_test.go":      "package main\n",
+	})
+	cache := NewPackageCache(nil)
+
+	first, err := cache.Import("t9/model", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := cache.Import("t9/model", filepath.Join(dir, "model"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second || first.Scope().Lookup("ID") == nil {
+		t.Errorf("package is imported more than once or without its types")
+	}
+
+	files := cache.DirFiles(dir)
+	if len(files) != 2 {
+		t.Fatalf("expected 2 files, got %d", len(files))
+	}
+	if again := cache.DirFiles(dir); &again[0] != &files[0] {
+		t.Errorf("files of directory are parsed more than once")
+	}
+	for _, file := range files {
+		if name := cache.FileSet().File(file.Pos()).Name(); filepath.Dir(name) != dir {
+			t.Errorf("file %s is not in file set of cache", name)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"runtime/debug"
+)
+
+// fileRun is generation of code for single input file. Files of directory input are generated in the same
+// process sharing package cache, so packages they import are loaded once, output of every file is written to
+// its own buffers.
+type fileRun struct {
+	opts CommandLineOptions
+	// args are command-line arguments signatures of generated files are computed from
+	args     []string
+	packages *PackageCache
+	// content is content of input file already read by directory walk, nil if it is not read yet
+	content []byte
+	stdout  io.Writer
+	stderr  io.Writer
+	// fix and migrate are set for "gobetter fix" and "gobetter migrate" commands
+	fix     bool
+	migrate bool
+	renames []fieldRename
+	stats   RunStats
+}
+
+// runAbort is panic value aborting generation of file with exit code, it is raised by fileRun.exit from code
+// nested deep in traversal of input file and recovered by fileRun.generate
+type runAbort struct {
+	code int
+}
+
+// generate generates code for input file and returns exit code, panics of generator are reported as internal
+// errors of the file rather than crashing the whole run
+func (r *fileRun) generate() (code int) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			if abort, ok := rec.(runAbort); ok {
+				code = abort.code
+				return
+			}
+			_, _ = fmt.Fprintf(r.stderr, "Error: internal error: %v\n%s", rec, debug.Stack())
+			code = ExitInternal
+		}
+	}()
+	r.generateFile()
+	return 0
+}
+
+// exit aborts generation of file with exit code
+func (r *fileRun) exit(code int) {
+	panic(runAbort{code: code})
+}
+
+// exitWithError prints error and aborts generation of file with exit code of its class
+func (r *fileRun) exitWithError(code int, err error) {
+	_, _ = fmt.Fprintf(r.stderr, "Error: %v\n", err)
+	r.exit(code)
+}
+
+// printf prints progress of generation
+func (r *fileRun) printf(format string, a ...interface{}) {
+	_, _ = fmt.Fprintf(r.stdout, format, a...)
+}
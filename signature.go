@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// signatureVersion must be incremented every time when the way signature is computed changes, so
+// files generated by older versions of gobetter are regenerated
+const signatureVersion = 1
+
+const signaturePrefix = "// gobetter:signature="
+
+// ComputeSignature computes signature of generation input (input file content, content of configuration file
+// and command-line arguments) in form of "v<version>:<hash>"
+func ComputeSignature(fileContent []byte, configContent []byte, args []string) string {
+	h := sha256.New()
+	h.Write([]byte(version + "\n"))
+	for i := 0; i < len(args); i++ {
+		// "force", "jobs", "diagnostics", "cache-dir" and "tags" flags do not affect generated code ("tags" only
+		// select files of directory input), while contents of overlaid input file and of configuration file are
+		// hashed below instead of "overlay" and "config" flags
+		name, _, hasValue := strings.Cut(strings.TrimLeft(args[i], "-"), "=")
+		if name == "force" {
+			continue
+		}
+		if name == "cache-dir" || name == "overlay" || name == "jobs" || name == "diagnostics" ||
+			name == "config" || name == "tags" {
+			if !hasValue {
+				i++
+			}
+			continue
+		}
+		h.Write([]byte(args[i] + "\n"))
+	}
+	h.Write(fileContent)
+	if configContent != nil {
+		h.Write([]byte("\nconfig\n"))
+		h.Write(configContent)
+	}
+	return fmt.Sprintf("v%d:%x", signatureVersion, h.Sum(nil))
+}
+
+// ReadSignature reads signature stored in previously generated file. Signatures written before signature
+// format was versioned (plain hash without "v<version>:" prefix) are reported with version 0.
+func ReadSignature(filename string) (version int, hash string, found bool) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return 0, "", false
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "package ") {
+			break
+		}
+		if !strings.HasPrefix(line, signaturePrefix) {
+			continue
+		}
+		signature := strings.TrimPrefix(line, signaturePrefix)
+		prefix, rest, ok := strings.Cut(signature, ":")
+		if !ok || !strings.HasPrefix(prefix, "v") {
+			return 0, signature, true
+		}
+		version, err := strconv.Atoi(strings.TrimPrefix(prefix, "v"))
+		if err != nil {
+			return 0, signature, true
+		}
+		return version, rest, true
+	}
+	return 0, "", false
+}
+
+// IsUpToDate reports whether generated file has the same signature, so it does not need to be regenerated
+func IsUpToDate(out io.Writer, filename string, signature string) bool {
+	version, hash, found := ReadSignature(filename)
+	if !found {
+		return false
+	}
+	if version != signatureVersion {
+		_, _ = fmt.Fprintf(out, "Output file has signature of version %d, it will be regenerated\n", version)
+		return false
+	}
+	return fmt.Sprintf("v%d:%s", version, hash) == signature
+}
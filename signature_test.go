@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestComputeSignatureIgnoresFlagsNotAffectingGeneratedCode(t *testing.T) {
+	content := []byte("package p\n")
+	expected := ComputeSignature(content, nil, []string{"-input", "p.go"})
+	for _, args := range [][]string{
+		{"-input", "p.go", "-tags", "linux,integration"},
+		{"-tags=integration", "-input", "p.go"},
+		{"-input", "p.go", "-jobs", "4", "-diagnostics", "json", "-cache-dir", "off", "-force"},
+	} {
+		if signature := ComputeSignature(content, nil, args); signature != expected {
+			t.Errorf("signature of arguments %q differs from signature without them", args)
+		}
+	}
+	if ComputeSignature(content, nil, []string{"-input", "p.go", "-getter-style", "get"}) == expected {
+		t.Errorf("signature does not depend on flags affecting generated code")
+	}
+}
+
+func TestUpToDateFilesAreRegeneratedOnlyWhenForced(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod":    testModule,
+		"person.go": "package main\n\ntype Person struct { //+gob:Constructor\n\tName string\n}\n",
+	}, "person.go")
+	outFilename := filepath.Join(dir, "person_gob.go")
+	generated := readTestFile(t, dir, "person_gob.go")
+	if version, _, found := ReadSignature(outFilename); !found || version != signatureVersion {
+		t.Fatalf("generated file lacks signature of version %d:\n%s", signatureVersion, generated)
+	}
+	edited := generated + "\n// edited\n"
+	if err := os.WriteFile(outFilename, []byte(edited), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	generateTestModuleFile(t, dir, "person.go")
+	if readTestFile(t, dir, "person_gob.go") != edited {
+		t.Errorf("up to date file is regenerated")
+	}
+	generateTestModuleFile(t, dir, "person.go", "-force")
+	if readTestFile(t, dir, "person_gob.go") != generated {
+		t.Errorf("file is not regenerated with -force")
+	}
+
+	// signature without version is written by older versions of gobetter
+	_, hash, _ := ReadSignature(outFilename)
+	unversioned := strings.Replace(generated, fmt.Sprintf("v%d:%s", signatureVersion, hash), hash, 1) + "\n// edited\n"
+	if err := os.WriteFile(outFilename, []byte(unversioned), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	generateTestModuleFile(t, dir, "person.go")
+	if readTestFile(t, dir, "person_gob.go") != generated {
+		t.Errorf("file with signature of older format is not regenerated")
+	}
+}
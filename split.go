@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sourcePrefix marks files generated in per-struct mode with the name of their input file, so files of
+// structures that no longer exist (or are no longer processed) can be found and removed
+const sourcePrefix = "// gobetter:source="
+
+// makeStructOutputFilename returns name of file generated for structure in per-struct mode, e.g. person_gob.go,
+// _GOOS and _GOARCH suffixes of input file are kept (e.g. config_gob_linux.go for config_linux.go)
+func makeStructOutputFilename(dir string, structName string, inFilename string) string {
+	_, platform := splitPlatformSuffix(fileNameWithoutExt(filepath.Base(inFilename)))
+	return filepath.Join(dir, strings.ToLower(structName)+"_gob"+platform+".go")
+}
+
+// ReadSource reads name of input file stored in file previously generated in per-struct mode
+func ReadSource(filename string) (source string, found bool) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return "", false
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "package ") {
+			break
+		}
+		if strings.HasPrefix(line, sourcePrefix) {
+			return strings.TrimPrefix(line, sourcePrefix), true
+		}
+	}
+	return "", false
+}
+
+// StructOutputFiles returns files in directory previously generated in per-struct mode from input file
+func StructOutputFiles(dir string, inFilename string) []string {
+	matches, err := filepath.Glob(filepath.Join(dir, "*_gob*.go"))
+	if err != nil {
+		return nil
+	}
+	files := make([]string, 0)
+	for _, match := range matches {
+		if source, found := ReadSource(match); found && source == filepath.Base(inFilename) {
+			files = append(files, match)
+		}
+	}
+	return files
+}
+
+// AreStructOutputsUpToDate reports whether all files previously generated from input file have the same
+// signature, so they do not need to be regenerated
+func AreStructOutputsUpToDate(out io.Writer, dir string, inFilename string, signature string) bool {
+	files := StructOutputFiles(dir, inFilename)
+	if len(files) == 0 {
+		return false
+	}
+	for _, file := range files {
+		if !IsUpToDate(out, file, signature) {
+			return false
+		}
+	}
+	return true
+}
+
+// RemoveStaleStructOutputs removes files previously generated from input file which were not generated
+// during current run, e.g. because structure was renamed, removed or filtered out
+func RemoveStaleStructOutputs(out io.Writer, dir string, inFilename string, generated []string) error {
+	keep := make(map[string]bool)
+	for _, file := range generated {
+		keep[filepath.Clean(file)] = true
+	}
+	for _, file := range StructOutputFiles(dir, inFilename) {
+		if keep[filepath.Clean(file)] {
+			continue
+		}
+		if err := removeGeneratedFile(out, file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
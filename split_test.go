@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSplitByStructWritesFilePerStruct(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod": testModule,
+		"model.go": `package main
+
+type Person struct { //+gob:Constructor
+	Name string
+}
+
+type Address struct { //+gob:Constructor
+	City string
+}
+`,
+		"main.go": `package main
+
+import "fmt"
+
+func main() {
+	fmt.Println(*NewPersonBuilder().Name("a").Build(), *NewAddressBuilder().City("c").Build())
+}
+`,
+	}, "model.go", "-split", "struct")
+
+	assertOutput(t, runTestModule(t, dir), "{a} {c}")
+	for _, name := range []string{"person_gob.go", "address_gob.go"} {
+		if source, found := ReadSource(filepath.Join(dir, name)); !found || source != "model.go" {
+			t.Errorf("%s lacks source header of model.go: %q", name, source)
+		}
+	}
+
+	// file of renamed structure is removed
+	if err := os.WriteFile(filepath.Join(dir, "model.go"),
+		[]byte("package main\n\ntype Person struct { //+gob:Constructor\n\tName string\n}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	generateTestModuleFile(t, dir, "model.go", "-split", "struct")
+	if _, err := os.Stat(filepath.Join(dir, "address_gob.go")); !os.IsNotExist(err) {
+		t.Errorf("file of removed structure is kept: %v", err)
+	}
+}
+
+func TestSplitByStructKeepsFilesNotGeneratedFromInputFile(t *testing.T) {
+	dir := writeTestModule(t, map[string]string{
+		"go.mod":        testModule,
+		"model.go":      "package main\n\ntype Person struct { //+gob:Constructor\n\tName string\n}\n",
+		"person_gob.go": "package main\n",
+	})
+	code, diagnostics := generateTestFile(t, dir, "model.go", "-split", "struct")
+	if code != ExitWrite || !strings.Contains(diagnostics, "was not generated from") {
+		t.Errorf("expected existing file to be kept, got exit code %d:\n%s", code, diagnostics)
+	}
+	if readTestFile(t, dir, "person_gob.go") != "package main\n" {
+		t.Errorf("existing file is overwritten")
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// RunStats are totals of gobetter run reported when run is finished, so it is visible what bulk runs did
+type RunStats struct {
+	// FilesScanned is number of input files considered, including files without +gob: annotations
+	FilesScanned int `json:"filesScanned"`
+	// FilesSkipped is number of files not regenerated, because signatures of their generated files are up to date
+	FilesSkipped int `json:"filesSkipped"`
+	Structs      int `json:"structs"`
+	Builders     int `json:"builders"`
+	Getters      int `json:"getters"`
+	// Aliases is number of deprecated type aliases generated by -deprecated-shims flag
+	Aliases  int `json:"aliases"`
+	Warnings int `json:"warnings"`
+	// Generated holds number of generated lines of code per structure, it is reported in JSON format only
+	Generated []StructLines `json:"generated,omitempty"`
+}
+
+// StructLines is number of non-blank lines of code generated for structure declared in file
+type StructLines struct {
+	Filename string `json:"file"`
+	Struct   string `json:"struct"`
+	Lines    int    `json:"lines"`
+}
+
+// summaryReport is JSON object that summary is reported as
+type summaryReport struct {
+	Summary RunStats `json:"summary"`
+}
+
+// Add adds totals of other run (e.g. of file processed as part of directory input)
+func (s *RunStats) Add(other RunStats) {
+	s.FilesScanned += other.FilesScanned
+	s.FilesSkipped += other.FilesSkipped
+	s.Structs += other.Structs
+	s.Builders += other.Builders
+	s.Getters += other.Getters
+	s.Aliases += other.Aliases
+	s.Warnings += other.Warnings
+	s.Generated = append(s.Generated, other.Generated...)
+}
+
+func (s RunStats) String() string {
+	return fmt.Sprintf("Summary: %d file(s) scanned, %d skipped as up to date, %d struct(s) processed, "+
+		"%d builder(s), %d getter(s), %d alias(es) generated, %d warning(s)",
+		s.FilesScanned, s.FilesSkipped, s.Structs, s.Builders, s.Getters, s.Aliases, s.Warnings)
+}
+
+// GeneratedStats are counts of declarations generated for structure, or totals of package when Struct is empty
+type GeneratedStats struct {
+	Package string `json:"package"`
+	Struct  string `json:"struct,omitempty"`
+	Types   int    `json:"types"`
+	Funcs   int    `json:"funcs"`
+	Methods int    `json:"methods"`
+	// Lines is number of non-blank lines of generated declarations, including their doc comments
+	Lines int `json:"lines"`
+}
+
+func (s *GeneratedStats) add(other GeneratedStats) {
+	s.Types += other.Types
+	s.Funcs += other.Funcs
+	s.Methods += other.Methods
+	s.Lines += other.Lines
+}
+
+// RunStatsCommand runs "gobetter stats [-input <directory>|<directory>/...] [-json]" command reporting counts of
+// types, functions, methods and lines generated per package and per structure, so structures which builders
+// bloat compile times can be spotted. Returns exit code.
+func RunStatsCommand(args []string) int {
+	flags := flag.NewFlagSet("stats", flag.ContinueOnError)
+	input := flags.String("input", "./...", "directory (\"dir\" or recursive \"dir/...\") with generated files")
+	jsonOutput := flags.Bool("json", false, "print statistics as JSON array instead of text")
+	if err := flags.Parse(args); err != nil {
+		return ExitUsage
+	}
+	dir, recursive, ok := directoryInput(*input)
+	if !ok {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %s is not a directory\n", *input)
+		return ExitUsage
+	}
+	report := make([]GeneratedStats, 0)
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() {
+			return nil
+		}
+		if path != dir && (!recursive || isIgnoredDir(entry.Name())) {
+			return filepath.SkipDir
+		}
+		packageStats, err := packageGeneratedStats(path)
+		if err != nil {
+			return err
+		}
+		report = append(report, packageStats...)
+		return nil
+	})
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return ExitParse
+	}
+	if *jsonOutput {
+		content, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(content))
+		return 0
+	}
+	for _, s := range report {
+		name := s.Package
+		if s.Struct != "" {
+			name = "  " + s.Struct
+		}
+		fmt.Printf("%-40s %5d type(s) %5d func(s) %5d method(s) %7d line(s)\n", name, s.Types, s.Funcs, s.Methods,
+			s.Lines)
+	}
+	return 0
+}
+
+// packageGeneratedStats returns totals of files generated by gobetter in directory followed by statistics of
+// structures sorted by number of lines (the largest first), or nothing if there are no generated files.
+// Declarations are attributed to structures of package by their names (e.g. Person_Builder_Name and
+// NewPersonBuilder belong to Person) and methods to structures their receivers belong to.
+func packageGeneratedStats(dir string) ([]GeneratedStats, error) {
+	filenames, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	structs := make(map[string]bool)
+	generated := make([]string, 0)
+	for _, filename := range filenames {
+		if _, _, found := ReadSignature(filename); found {
+			generated = append(generated, filename)
+			continue
+		}
+		file, err := parser.ParseFile(token.NewFileSet(), filename, nil, 0)
+		if err != nil {
+			continue
+		}
+		for _, decl := range file.Decls {
+			if gen, ok := decl.(*ast.GenDecl); ok {
+				for _, spec := range gen.Specs {
+					if ts, ok := spec.(*ast.TypeSpec); ok {
+						if _, ok := ts.Type.(*ast.StructType); ok {
+							structs[ts.Name.Name] = true
+						}
+					}
+				}
+			}
+		}
+	}
+	if len(generated) == 0 {
+		return nil, nil
+	}
+
+	total := GeneratedStats{Package: filepath.ToSlash(dir)}
+	byStruct := make(map[string]*GeneratedStats)
+	owners := make(map[string]string)
+	for _, filename := range generated {
+		content, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, filename, content, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		// types are attributed first, so methods of generated types can be attributed to their structures
+		for _, decl := range file.Decls {
+			if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.TYPE {
+				for _, spec := range gen.Specs {
+					name := spec.(*ast.TypeSpec).Name.Name
+					owners[name] = generatedOwner(name, structs)
+				}
+			}
+		}
+		for _, decl := range file.Decls {
+			var s GeneratedStats
+			var owner string
+			start := decl.Pos()
+			switch d := decl.(type) {
+			case *ast.GenDecl:
+				if d.Tok != token.TYPE {
+					continue
+				}
+				s.Types = len(d.Specs)
+				owner = owners[d.Specs[0].(*ast.TypeSpec).Name.Name]
+				if d.Doc != nil {
+					start = d.Doc.Pos()
+				}
+			case *ast.FuncDecl:
+				if d.Recv != nil {
+					s.Methods = 1
+					receiver := receiverTypeName(d.Recv.List[0].Type)
+					if owner = owners[receiver]; structs[receiver] {
+						owner = receiver
+					}
+				} else {
+					s.Funcs = 1
+					owner = generatedOwner(d.Name.Name, structs)
+				}
+				if d.Doc != nil {
+					start = d.Doc.Pos()
+				}
+			}
+			s.Lines = nonBlankLines(string(content[fset.Position(start).Offset:fset.Position(decl.End()).Offset]))
+			total.add(s)
+			if owner == "" {
+				continue
+			}
+			if byStruct[owner] == nil {
+				byStruct[owner] = &GeneratedStats{Package: total.Package, Struct: owner}
+			}
+			byStruct[owner].add(s)
+		}
+	}
+	result := []GeneratedStats{total}
+	for _, s := range byStruct {
+		result = append(result, *s)
+	}
+	sort.SliceStable(result[1:], func(i, j int) bool {
+		if result[i+1].Lines != result[j+1].Lines {
+			return result[i+1].Lines > result[j+1].Lines
+		}
+		return result[i+1].Struct < result[j+1].Struct
+	})
+	return result, nil
+}
+
+// generatedOwner returns structure generated declaration belongs to, i.e. the longest name of structure that
+// name of declaration contains (e.g. Person for ImmutablePerson), or empty string if there is no such structure
+func generatedOwner(name string, structs map[string]bool) string {
+	owner := ""
+	for s := range structs {
+		if len(s) > len(owner) && (strings.Contains(name, s) || strings.Contains(name, strings.Title(s))) {
+			owner = s
+		}
+	}
+	return owner
+}
+
+// receiverTypeName returns name of type of method receiver without pointer and type arguments
+func receiverTypeName(expr ast.Expr) string {
+	for {
+		switch t := expr.(type) {
+		case *ast.StarExpr:
+			expr = t.X
+		case *ast.IndexExpr:
+			expr = t.X
+		case *ast.IndexListExpr:
+			expr = t.X
+		case *ast.Ident:
+			return t.Name
+		default:
+			return ""
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRunStatsCountGeneratedDeclarations(t *testing.T) {
+	dir := writeTestModule(t, map[string]string{
+		"go.mod": testModule,
+		"person.go": `package main
+
+type Person struct { //+gob:Constructor +gob:unknown
+	name string //+gob:getter
+	Age  int
+}
+`,
+	})
+	args := []string{"-input", filepath.Join(dir, "person.go"), "-cache-dir", "off", "-naming", "camel",
+		"-deprecated-shims"}
+	generate := func() RunStats {
+		var stdout, stderr bytes.Buffer
+		run := &fileRun{
+			opts:     parseCommandLineArgs(args),
+			args:     args,
+			packages: NewPackageCache(nil),
+			stdout:   &stdout,
+			stderr:   &stderr,
+		}
+		if code := run.generate(); code != 0 {
+			t.Fatalf("exit code %d:\n%s", code, stderr.String())
+		}
+		return run.stats
+	}
+
+	stats := generate()
+	stats.Generated = nil
+	// aliases are generated for types of builder chain of Person: name, age and finalizer
+	expected := RunStats{FilesScanned: 1, Structs: 1, Builders: 1, Getters: 1, Aliases: 3, Warnings: 1}
+	if !reflect.DeepEqual(stats, expected) {
+		t.Errorf("expected %+v, got %+v", expected, stats)
+	}
+	if skipped := generate(); skipped.FilesScanned != 1 || skipped.FilesSkipped != 1 || skipped.Structs != 0 {
+		t.Errorf("up to date file is not skipped: %+v", skipped)
+	}
+
+	var report bytes.Buffer
+	NewDiagnostics("json", false, &report).Summary(stats)
+	var decoded summaryReport
+	if err := json.Unmarshal(report.Bytes(), &decoded); err != nil || !reflect.DeepEqual(decoded.Summary, expected) {
+		t.Errorf("unexpected JSON summary %s (%v)", report.String(), err)
+	}
+}
+
+func TestLargeBuilderChainsAreReported(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		chunk    string
+		max      string
+		warnings int
+	}{
+		{name: "exceeded", max: "3", warnings: 1},
+		{name: "chunked", chunk: " +gob:chunk=2", max: "3", warnings: 0},
+		{name: "disabled", max: "0", warnings: 0},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			dir := writeTestModule(t, map[string]string{
+				"go.mod": testModule,
+				"person.go": "package main\n\ntype Person struct { //+gob:Constructor" + test.chunk +
+					"\n\tA string\n\tB string\n\tC string\n}\n",
+			})
+			args := []string{"-input", filepath.Join(dir, "person.go"), "-cache-dir", "off",
+				"-max-builder-types", test.max}
+			var stdout, stderr bytes.Buffer
+			run := &fileRun{
+				opts:     parseCommandLineArgs(args),
+				args:     args,
+				packages: NewPackageCache(nil),
+				stdout:   &stdout,
+				stderr:   &stderr,
+			}
+			if code := run.generate(); code != 0 {
+				t.Fatalf("exit code %d:\n%s", code, stderr.String())
+			}
+			if run.stats.Warnings != test.warnings || (test.warnings > 0) !=
+				strings.Contains(stderr.String(), "builder chain of struct Person has 4 types, more than 3 allowed") {
+				t.Errorf("expected %d warning(s):\n%s", test.warnings, stderr.String())
+			}
+			if generated := run.stats.Generated; len(generated) != 1 || generated[0].Struct != "Person" ||
+				generated[0].Lines == 0 {
+				t.Errorf("unexpected lines of generated code %+v", generated)
+			}
+		})
+	}
+}
+
+func TestGeneratedStatsOfPackage(t *testing.T) {
+	dir := generateTestModule(t, map[string]string{
+		"go.mod": testModule,
+		"person.go": `package main
+
+type Person struct { //+gob:Constructor
+	Name string
+	Age  int
+	nick string //+gob:_ +gob:getter
+}
+
+type Address struct { //+gob:Constructor
+	City string
+}
+`,
+	}, "person.go")
+
+	report, err := packageGeneratedStats(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := filepath.ToSlash(dir)
+	expected := []GeneratedStats{
+		{Package: pkg, Types: 5, Funcs: 2, Methods: 8},
+		// setters, Build(), Reset() and getter of nick
+		{Package: pkg, Struct: "Person", Types: 3, Funcs: 1, Methods: 5},
+		{Package: pkg, Struct: "Address", Types: 2, Funcs: 1, Methods: 3},
+	}
+	if len(report) != len(expected) {
+		t.Fatalf("expected %+v, got %+v", expected, report)
+	}
+	for i := range report {
+		lines := report[i].Lines
+		report[i].Lines = 0
+		if report[i] != expected[i] || lines == 0 {
+			t.Errorf("expected %+v, got %+v with %d line(s)", expected[i], report[i], lines)
+		}
+	}
+}
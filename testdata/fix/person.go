@@ -0,0 +1,15 @@
+package fix
+
+type Person struct { //+gob:Constructor
+	Name string
+	Age  int
+	//+gob:_
+	Nick string
+	//+gob:_
+	Friends []*Person
+}
+
+type Server struct { //+gob:Constructor
+	Host string
+	Port int //+gob:default=8080
+}
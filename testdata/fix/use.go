@@ -0,0 +1,24 @@
+package fix
+
+func people(v string) []*Person {
+	required := &Person{Name: "a", Age: 1}
+	unkeyed := Person{"b", 2, "bee", nil}
+	optional := &Person{
+		Nick: v,
+		Age:  3,
+		Name: "c",
+		Friends: []*Person{
+			&Person{Name: "d", Age: 4},
+		},
+	}
+	partial := Person{Name: "e"}
+	elided := []Person{{Name: "f", Age: 6}}
+	return []*Person{required, &unkeyed, optional, &partial, &elided[0]}
+}
+
+func servers() []*Server {
+	return []*Server{
+		&Server{Host: "a"},
+		&Server{Host: "b", Port: 0},
+	}
+}
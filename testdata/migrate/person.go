@@ -0,0 +1,6 @@
+package migrate
+
+type Person struct { //+gob:Constructor
+	GivenName string
+	Age       int
+}
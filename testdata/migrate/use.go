@@ -0,0 +1,9 @@
+package migrate
+
+func start() Person_Builder_FirstName {
+	return NewPersonBuilder()
+}
+
+func person() *Person {
+	return NewPersonBuilder().FirstName("a").Age(1).Build()
+}
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// vetConfig is a subset of configuration passed by "go vet -vettool" to analysis tool for every package
+type vetConfig struct {
+	Dir         string
+	ImportPath  string
+	GoFiles     []string
+	ImportMap   map[string]string
+	PackageVetx map[string]string
+	VetxOnly    bool
+	VetxOutput  string
+}
+
+// vetFlags returns flags accepted by gobetter when it is invoked as vet tool
+func vetFlags(enforce *bool) *flag.FlagSet {
+	flags := flag.NewFlagSet("gobetter", flag.ContinueOnError)
+	flags.BoolVar(enforce, "enforce", false,
+		"report construction of annotated structures without builder (composite literals, new(T) and zero values)")
+	return flags
+}
+
+// generateDirective is a "go:generate" directive invoking gobetter
+type generateDirective struct {
+	filename string
+	line     int
+	args     []string
+}
+
+// IsVetToolInvocation reports whether gobetter is invoked by "go vet -vettool=$(which gobetter)" rather than
+// by "go generate"
+func IsVetToolInvocation(args []string) bool {
+	if len(args) == 1 && (args[0] == "-V=full" || args[0] == "-flags") {
+		return true
+	}
+	return len(args) > 0 && strings.HasSuffix(args[len(args)-1], ".cfg")
+}
+
+// RunVetTool implements protocol of "go vet -vettool" and reports files generated by gobetter that are out of
+// date with their input files, returns exit code of the tool
+func RunVetTool(args []string) int {
+	switch args[0] {
+	case "-V=full":
+		// tool ID is used by go command to cache vet results, so it must change with gobetter executable
+		fmt.Printf("gobetter version devel buildID=%s\n", executableHash())
+		return 0
+	case "-flags":
+		type vetFlag struct {
+			Name  string
+			Bool  bool
+			Usage string
+		}
+		described := make([]vetFlag, 0)
+		vetFlags(new(bool)).VisitAll(func(f *flag.Flag) {
+			described = append(described, vetFlag{Name: f.Name, Bool: true, Usage: f.Usage})
+		})
+		content, _ := json.Marshal(described)
+		fmt.Println(string(content))
+		return 0
+	}
+	var enforce bool
+	flags := vetFlags(&enforce)
+	// standard flags of analysis tools passed by go command are accepted, but ignored
+	flags.Bool("json", false, "")
+	flags.Bool("fix", false, "")
+	flags.Bool("diff", false, "")
+	flags.Int("c", -1, "")
+	if err := flags.Parse(args[:len(args)-1]); err != nil {
+		return ExitUsage
+	}
+	content, err := os.ReadFile(args[len(args)-1])
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "gobetter: failed to read vet config: %v\n", err)
+		return ExitUsage
+	}
+	var cfg vetConfig
+	if err = json.Unmarshal(content, &cfg); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "gobetter: failed to parse vet config: %v\n", err)
+		return ExitUsage
+	}
+	// facts file lists annotated structures of package, so packages importing it can enforce use of builders
+	var facts []byte
+	var files []*parsedFile
+	if enforce {
+		files = parsePackageFiles(cfg.GoFiles)
+		facts, _ = json.Marshal(enforcementFacts{Package: packageName(files), Structs: annotatedStructs(files)})
+	}
+	if cfg.VetxOutput != "" {
+		if err = os.WriteFile(cfg.VetxOutput, facts, 0o666); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "gobetter: failed to write facts file: %v\n", err)
+			return ExitWrite
+		}
+	}
+	if cfg.VetxOnly {
+		return 0
+	}
+	diagnostics := 0
+	for _, filename := range cfg.GoFiles {
+		for _, directive := range readGenerateDirectives(filename) {
+			for _, msg := range staleOutputMessages(directive) {
+				_, _ = fmt.Fprintf(os.Stderr, "%s:%d:1: %s\n", directive.filename, directive.line, msg)
+				diagnostics++
+			}
+		}
+	}
+	if enforce {
+		for _, diagnostic := range EnforceBuilders(cfg, files) {
+			_, _ = fmt.Fprintln(os.Stderr, diagnostic)
+			diagnostics++
+		}
+	}
+	if diagnostics > 0 {
+		return ExitCheck
+	}
+	return 0
+}
+
+func executableHash() string {
+	h := sha256.New()
+	h.Write([]byte(version + "\n"))
+	if executable, err := os.Executable(); err == nil {
+		if file, err := os.Open(executable); err == nil {
+			_, _ = io.Copy(h, file)
+			_ = file.Close()
+		}
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// readGenerateDirectives reads "go:generate" directives invoking gobetter from source file, directive arguments
+// are expanded the same way as "go generate" does
+func readGenerateDirectives(filename string) []generateDirective {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	directives := make([]generateDirective, 0)
+	scanner := bufio.NewScanner(file)
+	packageName := ""
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if packageName == "" && strings.HasPrefix(text, "package ") {
+			packageName = strings.TrimSpace(strings.TrimPrefix(text, "package "))
+		}
+		if !strings.HasPrefix(text, "//go:generate ") {
+			continue
+		}
+		words := splitGenerateDirective(strings.TrimPrefix(text, "//go:generate "))
+		for i, word := range words {
+			words[i] = os.Expand(word, func(name string) string {
+				switch name {
+				case "GOFILE":
+					return filepath.Base(filename)
+				case "GOLINE":
+					return strconv.Itoa(line)
+				case "GOPACKAGE":
+					return packageName
+				case "DOLLAR":
+					return "$"
+				}
+				return os.Getenv(name)
+			})
+		}
+		for i, word := range words {
+			// gobetter can be invoked directly or with "go run github.com/mobiletoly/gobetter@<version>"
+			name, _, _ := strings.Cut(path.Base(filepath.ToSlash(word)), "@")
+			if name == "gobetter" {
+				directives = append(directives, generateDirective{
+					filename: filename,
+					line:     line,
+					args:     words[i+1:],
+				})
+				break
+			}
+		}
+	}
+	return directives
+}
+
+// splitGenerateDirective splits "go:generate" directive into words, double-quoted words are unquoted
+func splitGenerateDirective(text string) []string {
+	words := make([]string, 0)
+	for text = strings.TrimSpace(text); text != ""; text = strings.TrimSpace(text) {
+		if text[0] == '"' {
+			if quoted, err := strconv.QuotedPrefix(text); err == nil {
+				word, _ := strconv.Unquote(quoted)
+				words = append(words, word)
+				text = text[len(quoted):]
+				continue
+			}
+		}
+		end := strings.IndexAny(text, " \t")
+		if end < 0 {
+			end = len(text)
+		}
+		words = append(words, text[:end])
+		text = text[end:]
+	}
+	return words
+}
+
+// generateArgValue returns value of string flag passed to gobetter in "go:generate" directive
+func generateArgValue(args []string, name string) string {
+	for i, arg := range args {
+		flagName, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || flagName != name {
+			continue
+		}
+		if hasValue {
+			return value
+		}
+		if i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+// staleOutputMessages returns diagnostic messages if files generated by "go:generate" directive are missing or
+// out of date. Directory input is walked the same way as generation walks it and every file is checked.
+func staleOutputMessages(directive generateDirective) []string {
+	// "go generate" runs commands in directory of source file
+	dir := filepath.Dir(directive.filename)
+	input := generateArgValue(directive.args, "input")
+	if input == "" {
+		return nil
+	}
+	if !filepath.IsAbs(input) {
+		input = filepath.Join(dir, input)
+	}
+	var configContent []byte
+	if configFilename := generateArgValue(directive.args, "config"); configFilename != "" {
+		if !filepath.IsAbs(configFilename) {
+			configFilename = filepath.Join(dir, configFilename)
+		}
+		var err error
+		if configContent, err = os.ReadFile(configFilename); err != nil {
+			return []string{fmt.Sprintf("gobetter configuration file %s cannot be read: %v", configFilename, err)}
+		}
+	}
+
+	inDir, recursive, ok := directoryInput(input)
+	if !ok {
+		fileContent, err := os.ReadFile(input)
+		if err != nil {
+			return []string{fmt.Sprintf("gobetter input file %s cannot be read: %v", input, err)}
+		}
+		outFilename := generateArgValue(directive.args, "output")
+		if outFilename == "" {
+			outFilename = makeOutputFilename(input)
+		} else if !filepath.IsAbs(outFilename) {
+			outFilename = filepath.Join(dir, outFilename)
+		}
+		if msg := staleFileMessage(dir, input, outFilename, fileContent, configContent, directive.args); msg != "" {
+			return []string{msg}
+		}
+		return nil
+	}
+
+	roots, workspace, err := directoryRoots(inDir, recursive)
+	if err != nil {
+		return []string{fmt.Sprintf("gobetter input directory %s cannot be walked: %v", inDir, err)}
+	}
+	config, _ := ParseConfig(configContent)
+	opts := CommandLineOptions{Split: configArgValue(directive.args, config, "split")}
+	if value := configArgValue(directive.args, config, "generate-for"); value != "" && value != "annotated" {
+		opts.GenerateFor = &value
+	}
+	if tags := configArgValue(directive.args, config, "tags"); tags != "" {
+		opts.Tags = strings.Split(tags, ",")
+	}
+	messages := make([]string, 0)
+	_, err = walkDirectoryInput(opts, roots, workspace, recursive, func(file workspaceFile) {
+		fileConfigContent := configContent
+		if file.config != "" {
+			if fileConfigContent, err = os.ReadFile(file.config); err != nil {
+				messages = append(messages, fmt.Sprintf("gobetter configuration file %s cannot be read: %v",
+					file.config, err))
+				return
+			}
+		}
+		msg := staleFileMessage(dir, file.path, makeOutputFilename(file.path), file.content, fileConfigContent,
+			directoryFileArgs(directive.args, file))
+		if msg != "" {
+			messages = append(messages, msg)
+		}
+	})
+	if err != nil {
+		messages = append(messages, fmt.Sprintf("gobetter input directory %s cannot be walked: %v", inDir, err))
+	}
+	return messages
+}
+
+// configArgValue returns value of flag passed in "go:generate" directive or set by configuration file, flags
+// passed in command line take precedence over configuration file
+func configArgValue(args []string, config Config, name string) string {
+	if value := generateArgValue(args, name); value != "" {
+		return value
+	}
+	return config[name]
+}
+
+// staleFileMessage returns diagnostic message if files generated from input file are missing or out of date,
+// or empty string if they are up to date. Names of files in message are relative to directory of directive.
+func staleFileMessage(dir string, inFilename string, outFilename string, fileContent []byte, configContent []byte,
+	args []string) string {
+	relative := func(filename string) string {
+		if rel, err := filepath.Rel(dir, filename); err == nil {
+			return rel
+		}
+		return filename
+	}
+	config, _ := ParseConfig(configContent)
+	var generateFor *string
+	if value := configArgValue(args, config, "generate-for"); value != "" && value != "annotated" {
+		generateFor = &value
+	}
+	if !mayHaveAnnotatedStructs(generateFor, fileContent) {
+		// no files are generated from input file without annotations
+		return ""
+	}
+	signature := ComputeSignature(fileContent, configContent, args)
+	outFiles := []string{outFilename}
+	if configArgValue(args, config, "split") == "struct" {
+		outFiles = StructOutputFiles(filepath.Dir(outFilename), inFilename)
+		if len(outFiles) == 0 {
+			return fmt.Sprintf("files generated by gobetter from %s are missing, run go generate",
+				relative(inFilename))
+		}
+	}
+	for _, outFile := range outFiles {
+		version, hash, found := ReadSignature(outFile)
+		if !found {
+			return fmt.Sprintf("%s is missing or was not generated by gobetter, run go generate",
+				relative(outFile))
+		}
+		if fmt.Sprintf("v%d:%s", version, hash) != signature {
+			return fmt.Sprintf("%s is out of date with %s, run go generate",
+				relative(outFile), relative(inFilename))
+		}
+	}
+	return ""
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStaleOutputMessagesOfDirectoryInput(t *testing.T) {
+	for _, input := range []string{".", "./..."} {
+		t.Run(input, func(t *testing.T) {
+			dir := writeTestModule(t, map[string]string{
+				"go.mod":        "module t9\n\ngo 1.18\n",
+				"doc.go":        "package app\n\n//go:generate gobetter -input " + input + "\n",
+				"person.go":     "package app\n\ntype Person struct { //+gob:Constructor\n\tName string\n}\n",
+				"sub/status.go": "package sub\n\ntype Status struct { //+gob:Constructor\n\tCode int\n}\n",
+			})
+			directives := readGenerateDirectives(filepath.Join(dir, "doc.go"))
+			if len(directives) != 1 {
+				t.Fatalf("expected 1 directive, got %d", len(directives))
+			}
+
+			messages := staleOutputMessages(directives[0])
+			expected := 1
+			if input == "./..." {
+				expected = 2
+			}
+			if len(messages) != expected {
+				t.Fatalf("expected %d messages, got %q", expected, messages)
+			}
+			for _, msg := range messages {
+				if !strings.Contains(msg, "is missing or was not generated by gobetter") {
+					t.Errorf("unexpected message %q", msg)
+				}
+			}
+
+			args := []string{"-input", filepath.Join(dir, input), "-cache-dir", "off"}
+			if code := RunDirectory(parseCommandLineArgs(args), args, 1); code != 0 {
+				t.Fatalf("expected exit code 0, got %d", code)
+			}
+			if messages = staleOutputMessages(directives[0]); len(messages) != 0 {
+				t.Errorf("expected no messages after generation, got %q", messages)
+			}
+
+			if err := os.WriteFile(filepath.Join(dir, "person.go"),
+				[]byte("package app\n\ntype Person struct { //+gob:Constructor\n\tName string\n\tAge  int\n}\n"),
+				0o644); err != nil {
+				t.Fatal(err)
+			}
+			messages = staleOutputMessages(directives[0])
+			if len(messages) != 1 || messages[0] != "person_gob.go is out of date with person.go, run go generate" {
+				t.Errorf("expected person_gob.go to be out of date, got %q", messages)
+			}
+		})
+	}
+}
+
+func TestVetToolReportsStaleGeneratedFiles(t *testing.T) {
+	dir := writeTestModule(t, map[string]string{"go.mod": testModule})
+	person := filepath.Join(dir, "person.go")
+	writePerson := func(fields string) {
+		t.Helper()
+		// input path of directive is absolute, so that it matches arguments of generateTestModuleFile
+		content := "package main\n\n//go:generate gobetter -input " + filepath.ToSlash(person) + "\n\n" +
+			"type Person struct { //+gob:Constructor\n" + fields + "}\n"
+		if err := os.WriteFile(person, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writePerson("\tName string\n")
+	cfg := filepath.Join(dir, "vet.cfg")
+	if err := os.WriteFile(cfg, []byte(`{"GoFiles": ["`+filepath.ToSlash(person)+`"]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if !IsVetToolInvocation([]string{cfg}) {
+		t.Fatalf("%s is not recognized as vet configuration", cfg)
+	}
+
+	if code := RunVetTool([]string{cfg}); code != ExitCheck {
+		t.Errorf("missing generated file: expected exit code %d, got %d", ExitCheck, code)
+	}
+	generateTestModuleFile(t, dir, "person.go")
+	if code := RunVetTool([]string{cfg}); code != 0 {
+		t.Errorf("up to date generated file: expected exit code 0, got %d", code)
+	}
+	writePerson("\tName string\n\tAge  int\n")
+	if code := RunVetTool([]string{cfg}); code != ExitCheck {
+		t.Errorf("out of date generated file: expected exit code %d, got %d", ExitCheck, code)
+	}
+}
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/build"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// directoryInput reports whether input flag refers to directory, "dir/..." refers to directory and all its
+// subdirectories the same way as package patterns of go command do
+func directoryInput(input string) (dir string, recursive bool, ok bool) {
+	if input == "..." || strings.HasSuffix(input, "/...") {
+		dir = strings.TrimSuffix(strings.TrimSuffix(input, "..."), "/")
+		if dir == "" {
+			dir = "."
+		}
+		return dir, true, true
+	}
+	if info, err := os.Stat(input); err == nil && info.IsDir() {
+		return input, false, true
+	}
+	return "", false, false
+}
+
+// isIgnoredDir reports whether directory is ignored by go command (and therefore by gobetter) when walking
+// directory tree
+func isIgnoredDir(name string) bool {
+	return name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")
+}
+
+// RunDirectory runs gobetter for every Go file in directory (and its subdirectories if recursive) with at most
+// jobs files processed at a time. Files are streamed from directory walk to workers, so memory usage doesn't
+// grow with the size of directory tree. Files are processed in-process sharing package cache, so packages
+// imported by many files are loaded once. Signatures of generated files are computed from the same arguments
+// (with input flag set to name of the file) as if files were generated by "go generate" in their directories.
+// Returns exit code of the first failed file in walk order, so class of failure is preserved and doesn't depend
+// on order files are processed in.
+func RunDirectory(opts CommandLineOptions, args []string, jobs int) int {
+	dir, recursive, _ := directoryInput(opts.InFilename)
+
+	// files are generated as if in their directories, so configuration file is passed with absolute path
+	if config := generateArgValue(args, "config"); config != "" {
+		if abs, err := filepath.Abs(config); err == nil {
+			args = withFlagValue(args, "config", abs)
+		}
+	}
+
+	roots, workspace, err := directoryRoots(dir, recursive)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return ExitUsage
+	}
+	// options are parsed once for every configuration file rather than for every file
+	configOpts := map[string]CommandLineOptions{"": opts}
+	for _, root := range roots {
+		if _, found := configOpts[root.config]; !found {
+			configOpts[root.config] = parseCommandLineArgs(withConfig(args, root.config))
+		}
+	}
+	packages := NewPackageCache(opts.Overlay)
+
+	files := make(chan workspaceFile, jobs)
+	// scanned is written by directory walk only and read after all files are processed
+	scanned := 0
+	go func() {
+		defer close(files)
+		var err error
+		scanned, err = walkDirectoryInput(opts, roots, workspace, recursive, func(file workspaceFile) {
+			files <- file
+		})
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+	}()
+
+	var mu sync.Mutex
+	processed, failed := 0, 0
+	// codes holds exit codes of failed files by their indices in walk order
+	codes := make(map[int]int)
+	var stats RunStats
+	var wg sync.WaitGroup
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// output of every file is collected into buffers reused by worker and printed at once, so outputs
+			// of files processed concurrently are not interleaved
+			var output, errOutput bytes.Buffer
+			for file := range files {
+				output.Reset()
+				errOutput.Reset()
+				fileOpts := configOpts[file.config]
+				fileOpts.InFilename = file.path
+				fileOpts.OutFilename = makeOutputFilename(file.path)
+				run := &fileRun{
+					opts:     fileOpts,
+					args:     directoryFileArgs(args, file),
+					packages: packages,
+					content:  file.content,
+					stdout:   &output,
+					stderr:   &errOutput,
+				}
+				code := run.generate()
+
+				mu.Lock()
+				processed++
+				if code != 0 {
+					failed++
+					codes[file.index] = code
+				} else {
+					stats.Add(run.stats)
+				}
+				fmt.Printf("[%d] %s\n", processed, file.path)
+				_, _ = os.Stdout.Write(output.Bytes())
+				_, _ = os.Stderr.Write(errOutput.Bytes())
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	fmt.Printf("Processed %d file(s), %d failed\n", processed, failed)
+	stats.FilesScanned = scanned
+	NewDiagnostics(opts.Diagnostics, opts.Strict, os.Stderr).Summary(stats)
+	exitCode, first := 0, -1
+	for index, code := range codes {
+		if first < 0 || index < first {
+			exitCode, first = code, index
+		}
+	}
+	return exitCode
+}
+
+// directoryRoots returns roots of directory walk: directory with go.work file is walked module by module, so
+// every module uses its own configuration, other directories are walked as single root
+func directoryRoots(dir string, recursive bool) (roots []workspaceModule, workspace bool, err error) {
+	if recursive {
+		modules, err := workspaceModules(dir)
+		if err != nil {
+			return nil, false, err
+		}
+		if modules != nil {
+			return modules, true, nil
+		}
+	}
+	return []workspaceModule{{dir: dir}}, false, nil
+}
+
+// walkDirectoryInput walks roots of directory input and calls visit for every file that has to be processed
+// (see isDirectoryInputFile) in walk order. Returns number of scanned Go source files and the first error of
+// walk, roots are walked even if walk of previous root failed.
+func walkDirectoryInput(opts CommandLineOptions, roots []workspaceModule, workspace bool, recursive bool,
+	visit func(file workspaceFile)) (scanned int, err error) {
+	index := 0
+	for _, root := range roots {
+		walkErr := filepath.WalkDir(root.dir, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if entry.IsDir() {
+				// nested modules are either members of workspace walked on their own or not part of it
+				if path != root.dir && (!recursive || isIgnoredDir(entry.Name()) || workspace && isModuleDir(path)) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !isGoSourceFile(path) {
+				return nil
+			}
+			scanned++
+			if content, ok := isDirectoryInputFile(opts, path); ok {
+				visit(workspaceFile{path: path, config: root.config, index: index, content: content})
+				index++
+			}
+			return nil
+		})
+		if walkErr != nil && err == nil {
+			err = walkErr
+		}
+	}
+	return scanned, err
+}
+
+// directoryFileArgs returns command-line arguments signatures of files generated from file of directory input
+// are computed from, i.e. arguments of "go:generate" directive processing the file alone
+func directoryFileArgs(args []string, file workspaceFile) []string {
+	if file.config != "" {
+		args = withConfig(args, file.config)
+	}
+	return withInput(args, filepath.Base(file.path))
+}
+
+// isDirectoryInputFile reports whether Go source file found by directory walk has to be processed and returns
+// its content, so it is not read again when file is processed. Files without +gob: annotations are skipped
+// without parsing them, unless there is previously generated file that has to be removed.
+func isDirectoryInputFile(opts CommandLineOptions, path string) ([]byte, bool) {
+	if !matchesBuildTags(opts, path) {
+		return nil, false
+	}
+	content, err := opts.Overlay.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	if mayHaveAnnotatedStructs(opts.GenerateFor, content) {
+		return content, true
+	}
+	if opts.Split == "struct" {
+		return content, len(StructOutputFiles(filepath.Dir(path), path)) > 0
+	}
+	_, err = os.Stat(makeOutputFilename(path))
+	return content, err == nil
+}
+
+// isGoSourceFile reports whether file is Go source file that can be input of gobetter, i.e. neither test nor
+// generated file
+func isGoSourceFile(path string) bool {
+	if !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+		return false
+	}
+	_, _, generated := ReadSignature(path)
+	return !generated
+}
+
+// withInput replaces value of input flag in command-line arguments keeping position of the flag, so
+// signature of generated file doesn't depend on whether file was processed alone or as part of directory
+func withInput(args []string, input string) []string {
+	return withFlagValue(args, "input", input)
+}
+
+// matchesBuildTags reports whether build constraints of file are satisfied by GOOS, GOARCH and tags passed
+// with tags flag, the same way as they are satisfied when package is built
+func matchesBuildTags(opts CommandLineOptions, path string) bool {
+	ctx := build.Default
+	ctx.BuildTags = opts.Tags
+	match, err := ctx.MatchFile(filepath.Dir(path), filepath.Base(path))
+	return err == nil && match
+}
+
+// withFlagValue replaces value of flag in command-line arguments keeping position of the flag
+func withFlagValue(args []string, flagName string, value string) []string {
+	result := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		name, _, hasValue := strings.Cut(strings.TrimLeft(args[i], "-"), "=")
+		if !strings.HasPrefix(args[i], "-") || name != flagName {
+			result = append(result, args[i])
+			continue
+		}
+		if hasValue {
+			result = append(result, args[i][:strings.Index(args[i], "=")+1]+value)
+			continue
+		}
+		result = append(result, args[i])
+		if i+1 < len(args) {
+			result = append(result, value)
+			i++
+		}
+	}
+	return result
+}
+
+// workspaceModule is module of Go workspace walked by RunDirectory, config is absolute path of gobetter.json file
+// of module or empty string if module has no configuration file
+type workspaceModule struct {
+	dir    string
+	config string
+}
+
+// workspaceFile is file found by directory walk with configuration file of its module, index is position of
+// file in walk order
+type workspaceFile struct {
+	path    string
+	config  string
+	index   int
+	content []byte
+}
+
+// workspaceModules returns member modules of Go workspace listed by "use" directives of go.work file in dir, or
+// nil if dir has no go.work file. Modules with gobetter.json file in their root directory are processed with
+// this configuration file instead of the one passed with config flag.
+func workspaceModules(dir string) ([]workspaceModule, error) {
+	content, err := os.ReadFile(filepath.Join(dir, "go.work"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	modules := make([]workspaceModule, 0)
+	for _, use := range workspaceUses(string(content)) {
+		module := workspaceModule{dir: filepath.Join(dir, filepath.FromSlash(use))}
+		if !isModuleDir(module.dir) {
+			return nil, fmt.Errorf("%s: module %s does not exist", filepath.Join(dir, "go.work"), use)
+		}
+		config := filepath.Join(module.dir, defaultConfigFilename)
+		if _, err := os.Stat(config); err == nil {
+			if module.config, err = filepath.Abs(config); err != nil {
+				return nil, err
+			}
+		}
+		modules = append(modules, module)
+	}
+	return modules, nil
+}
+
+// workspaceUses returns directories of "use" directives of go.work file, both single-line ("use ./api") and
+// block ("use ( ./api ./web )") forms are supported
+func workspaceUses(content string) []string {
+	uses := make([]string, 0)
+	inBlock := false
+	for _, line := range strings.Split(content, "\n") {
+		if comment := strings.Index(line, "//"); comment >= 0 {
+			line = line[:comment]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch {
+		case inBlock && fields[0] == ")":
+			inBlock = false
+		case inBlock:
+			uses = append(uses, strings.Trim(fields[0], "\"`"))
+		case fields[0] == "use" && len(fields) > 1 && fields[1] == "(":
+			inBlock = true
+		case fields[0] == "use" && len(fields) > 1:
+			uses = append(uses, strings.Trim(fields[1], "\"`"))
+		}
+	}
+	return uses
+}
+
+// isModuleDir reports whether directory is root directory of Go module
+func isModuleDir(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, "go.mod"))
+	return err == nil
+}
+
+// withConfig sets value of config flag in command-line arguments, flag is added if it is not passed
+func withConfig(args []string, config string) []string {
+	if generateArgValue(args, "config") != "" {
+		return withFlagValue(args, "config", config)
+	}
+	return append([]string{"-config=" + config}, args...)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunDirectoryResolvesImportsOutsideWorkingDirectory(t *testing.T) {
+	dir := writeTestModule(t, map[string]string{
+		"go.mod":     "module t9\n\ngo 1.18\n",
+		"lib/lib.go": "package lib\n\ntype Conn struct{}\n",
+		"app/a.go":   "package app\n\nimport . \"t9/lib\"\n\n//+gob:Constructor\ntype A struct {\n\tConn *Conn\n}\n",
+		"app/b.go":   "package app\n\nimport . \"t9/lib\"\n\n//+gob:Constructor\ntype B struct {\n\tConn *Conn\n}\n",
+	})
+	// working directory of test is outside of module
+	args := []string{"-input", filepath.Join(dir, "..."), "-cache-dir", "off"}
+	opts := parseCommandLineArgs(args)
+
+	if code := RunDirectory(opts, args, 2); code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	for _, name := range []string{"a_gob.go", "b_gob.go"} {
+		if _, err := os.Stat(filepath.Join(dir, "app", name)); err != nil {
+			t.Errorf("file %s is not generated: %v", name, err)
+		}
+	}
+}
+
+func TestRunDirectoryReturnsExitCodeOfFirstFailedFileInWalkOrder(t *testing.T) {
+	dir := writeTestModule(t, map[string]string{
+		"go.mod": "module t9\n\ngo 1.18\n",
+		"a.go":   "package app\n\ntype A struct { //+gob:Constructor\n\tName string\n",
+		"b.go":   "package app\n\ntype B struct { //+gob:Constructor\n\t//+gob:group=x\n\tName string\n\tAge  int\n\t//+gob:group=x\n\tCity string\n}\n",
+	})
+	args := []string{"-input", dir, "-cache-dir", "off"}
+	opts := parseCommandLineArgs(args)
+
+	for i := 0; i < 5; i++ {
+		if code := RunDirectory(opts, args, 2); code != ExitParse {
+			t.Fatalf("expected exit code %d of a.go, got %d", ExitParse, code)
+		}
+	}
+}
+
+func TestRunDirectoryImportsPackagesOfModulesFromTheirDirectories(t *testing.T) {
+	// both modules import package example.com/lib, but replace it with different directories, members of Go
+	// workspace cannot do that, so modules are nested in input directory instead
+	dir := writeTestModule(t, map[string]string{
+		"a/go.mod":    "module example.com/a\n\ngo 1.18\n\nrequire example.com/lib v0.0.0\n\nreplace example.com/lib => ../liba\n",
+		"a/a.go":      "package a\n\nimport \"example.com/lib\"\n\ntype Order lib.Order //+gob:Constructor\n",
+		"b/go.mod":    "module example.com/b\n\ngo 1.18\n\nrequire example.com/lib v0.0.0\n\nreplace example.com/lib => ../libb\n",
+		"b/b.go":      "package b\n\nimport \"example.com/lib\"\n\ntype Order lib.Order //+gob:Constructor\n",
+		"liba/go.mod": "module example.com/lib\n\ngo 1.18\n",
+		"liba/lib.go": "package lib\n\ntype Order struct {\n\tAmount int\n}\n",
+		"libb/go.mod": "module example.com/lib\n\ngo 1.18\n",
+		"libb/lib.go": "package lib\n\ntype Order struct {\n\tCustomer string\n}\n",
+	})
+	args := []string{"-input", filepath.Join(dir, "..."), "-cache-dir", "off"}
+	if code := RunDirectory(parseCommandLineArgs(args), args, 1); code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	for module, setter := range map[string]string{"a": ") Amount(", "b": ") Customer("} {
+		if generated := readTestFile(t, dir, module+"/"+module+"_gob.go"); !strings.Contains(generated, setter) {
+			t.Errorf("builder of module %s lacks setter%s:\n%s", module, strings.TrimSuffix(setter, "("), generated)
+		}
+	}
+}